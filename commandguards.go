@@ -0,0 +1,112 @@
+// Discordgo - Discord bindings for Go
+// Available at https://github.com/bwmarrin/discordgo
+
+// Copyright 2015-2016 Bruce Marriner <bruce@sqls.net>.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file contains helpers which restrict who can run application commands.
+
+package discordgo
+
+// InsufficientPermissionsMessage is the content of the ephemeral response sent
+// by RequirePermissions and RequireRoles to users who may not run a command.
+var InsufficientPermissionsMessage = "You do not have permission to use this command."
+
+// RequirePermissions wraps an interaction handler so that it is only called
+// for members who have all of the given permissions in the channel of the
+// interaction. Other users, including users outside of guilds, get an
+// ephemeral InsufficientPermissionsMessage response. Owners and
+// administrators have all permissions.
+//
+// Permissions are computed from the state when it knows the guild, the
+// channel and its overwrites, otherwise the permissions sent with the
+// interaction are used.
+//
+//	s.AddHandler(discordgo.RequirePermissions(discordgo.PermissionManageGuild, onSettingsCommand))
+func RequirePermissions(permissions int64, handler func(*Session, *InteractionCreate)) func(*Session, *InteractionCreate) {
+	return func(s *Session, i *InteractionCreate) {
+		if i.Member == nil {
+			denyInteraction(s, i)
+			return
+		}
+
+		perms := interactionPermissions(s, i.Interaction)
+		if perms&PermissionAdministrator == 0 && perms&permissions != permissions {
+			denyInteraction(s, i)
+			return
+		}
+
+		handler(s, i)
+	}
+}
+
+// RequireRoles wraps an interaction handler so that it is only called for
+// members who have at least one of the given roles. Owners and administrators
+// may always run the command. Other users, including users outside of guilds,
+// get an ephemeral InsufficientPermissionsMessage response.
+func RequireRoles(roleIDs []string, handler func(*Session, *InteractionCreate)) func(*Session, *InteractionCreate) {
+	return func(s *Session, i *InteractionCreate) {
+		if i.Member == nil {
+			denyInteraction(s, i)
+			return
+		}
+
+		if interactionPermissions(s, i.Interaction)&PermissionAdministrator == 0 && !memberHasAnyRole(i.Member, roleIDs) {
+			denyInteraction(s, i)
+			return
+		}
+
+		handler(s, i)
+	}
+}
+
+func memberHasAnyRole(m *Member, roleIDs []string) bool {
+	for _, roleID := range roleIDs {
+		if memberHasRole(m, roleID) {
+			return true
+		}
+	}
+	return false
+}
+
+// interactionPermissions returns the permissions of the member who created
+// an interaction in its channel.
+func interactionPermissions(s *Session, i *Interaction) int64 {
+	if i.Member.User == nil {
+		return i.Member.Permissions
+	}
+
+	store := s.stateStore()
+	if store == nil {
+		return i.Member.Permissions
+	}
+
+	guild, err := store.Guild(i.GuildID)
+	if err != nil {
+		return i.Member.Permissions
+	}
+
+	channel, err := store.Channel(i.ChannelID)
+	if err == nil && channel.IsThread() {
+		channel, err = store.Channel(channel.ParentID)
+	}
+	if err != nil {
+		return i.Member.Permissions
+	}
+
+	return memberPermissions(guild, channel, i.Member.User.ID, i.Member.Roles)
+}
+
+func denyInteraction(s *Session, i *InteractionCreate) {
+	err := s.InteractionRespond(i.Interaction, &InteractionResponse{
+		Type: InteractionResponseChannelMessageWithSource,
+		Data: &InteractionResponseData{
+			Content: InsufficientPermissionsMessage,
+			Flags:   MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		s.log(LogError, "error responding to unauthorized interaction %s, %s", i.ID, err)
+	}
+}
@@ -0,0 +1,103 @@
+package discordgo
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestCommandGuards(t *testing.T) {
+	s, err := New("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	guild := &Guild{
+		ID:      "guild",
+		OwnerID: "owner",
+		Roles: []*Role{
+			{ID: "guild", Permissions: PermissionSendMessages},
+			{ID: "mod", Permissions: PermissionManageMessages},
+			{ID: "admin", Permissions: PermissionAdministrator},
+		},
+	}
+	if err := s.State.GuildAdd(guild); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.State.ChannelAdd(&Channel{ID: "channel", GuildID: "guild"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var responses []*InteractionResponse
+	s.Client.Transport = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		var resp InteractionResponse
+		if err := json.NewDecoder(r.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		responses = append(responses, &resp)
+		return nil, errors.New("test")
+	})
+
+	interaction := func(userID string, roles ...string) *InteractionCreate {
+		return &InteractionCreate{Interaction: &Interaction{
+			ID:        "interaction",
+			GuildID:   "guild",
+			ChannelID: "channel",
+			Member:    &Member{User: &User{ID: userID}, Roles: roles},
+		}}
+	}
+
+	type handler = func(*Session, *InteractionCreate)
+	tests := []struct {
+		name    string
+		guard   func(handler) handler
+		i       *InteractionCreate
+		allowed bool
+	}{
+		{"permissions denied", requirePerms(PermissionManageMessages), interaction("user"), false},
+		{"permissions allowed", requirePerms(PermissionManageMessages), interaction("user", "mod"), true},
+		{"permissions administrator", requirePerms(PermissionBanMembers), interaction("user", "admin"), true},
+		{"permissions owner", requirePerms(PermissionBanMembers), interaction("owner"), true},
+		{"permissions dm", requirePerms(0), &InteractionCreate{Interaction: &Interaction{User: &User{ID: "user"}}}, false},
+		{"roles denied", requireRoles("mod"), interaction("user"), false},
+		{"roles allowed", requireRoles("other", "mod"), interaction("user", "mod"), true},
+		{"roles administrator", requireRoles("mod"), interaction("user", "admin"), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			responses = nil
+			called := false
+			tt.guard(func(*Session, *InteractionCreate) { called = true })(s, tt.i)
+
+			if called != tt.allowed {
+				t.Errorf("handler called = %v, want %v", called, tt.allowed)
+			}
+			if tt.allowed {
+				if len(responses) != 0 {
+					t.Errorf("allowed interaction got a response")
+				}
+				return
+			}
+			if len(responses) != 1 {
+				t.Fatalf("got %d responses, want 1", len(responses))
+			}
+			if r := responses[0]; r.Data == nil || r.Data.Flags&MessageFlagsEphemeral == 0 || r.Data.Content != InsufficientPermissionsMessage {
+				t.Errorf("unexpected response %+v", r.Data)
+			}
+		})
+	}
+}
+
+func requirePerms(permissions int64) func(func(*Session, *InteractionCreate)) func(*Session, *InteractionCreate) {
+	return func(h func(*Session, *InteractionCreate)) func(*Session, *InteractionCreate) {
+		return RequirePermissions(permissions, h)
+	}
+}
+
+func requireRoles(roleIDs ...string) func(func(*Session, *InteractionCreate)) func(*Session, *InteractionCreate) {
+	return func(h func(*Session, *InteractionCreate)) func(*Session, *InteractionCreate) {
+		return RequireRoles(roleIDs, h)
+	}
+}
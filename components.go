@@ -18,6 +18,13 @@ const (
 	RoleSelectMenuComponent        ComponentType = 6
 	MentionableSelectMenuComponent ComponentType = 7
 	ChannelSelectMenuComponent     ComponentType = 8
+	SectionComponent               ComponentType = 9
+	TextDisplayComponent           ComponentType = 10
+	ThumbnailComponent             ComponentType = 11
+	MediaGalleryComponent          ComponentType = 12
+	FileComponentType              ComponentType = 13
+	SeparatorComponent             ComponentType = 14
+	ContainerComponent             ComponentType = 17
 )
 
 // MessageComponent is a base interface for all message components.
@@ -50,22 +57,61 @@ func (umc *unmarshalableMessageComponent) UnmarshalJSON(src []byte) error {
 		umc.MessageComponent = &SelectMenu{}
 	case TextInputComponent:
 		umc.MessageComponent = &TextInput{}
+	case SectionComponent:
+		umc.MessageComponent = &Section{}
+	case TextDisplayComponent:
+		umc.MessageComponent = &TextDisplay{}
+	case ThumbnailComponent:
+		umc.MessageComponent = &Thumbnail{}
+	case MediaGalleryComponent:
+		umc.MessageComponent = &MediaGallery{}
+	case FileComponentType:
+		umc.MessageComponent = &FileComponent{}
+	case SeparatorComponent:
+		umc.MessageComponent = &Separator{}
+	case ContainerComponent:
+		umc.MessageComponent = &Container{}
 	default:
-		return fmt.Errorf("unknown component type: %d", v.Type)
+		umc.MessageComponent = UnknownComponent{ComponentType: v.Type, Data: append(json.RawMessage(nil), src...)}
+		return nil
 	}
 	return json.Unmarshal(src, umc.MessageComponent)
 }
 
-// MessageComponentFromJSON is a helper function for unmarshaling message components
+// MessageComponentFromJSON is a helper function for unmarshaling message components.
+// It returns an error for component types which are not supported. Unsupported
+// components nested in messages, modals and other components are unmarshalled
+// into an UnknownComponent instead.
 func MessageComponentFromJSON(b []byte) (MessageComponent, error) {
 	var u unmarshalableMessageComponent
 	err := u.UnmarshalJSON(b)
 	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal into MessageComponent: %w", err)
 	}
+	if c, ok := u.MessageComponent.(UnknownComponent); ok {
+		return nil, fmt.Errorf("failed to unmarshal into MessageComponent: unknown component type: %d", c.ComponentType)
+	}
 	return u.MessageComponent, nil
 }
 
+// UnknownComponent is a component of a type which is not supported by this
+// version of the library. The raw JSON is kept, so that it can be inspected
+// and sent back unchanged.
+type UnknownComponent struct {
+	ComponentType ComponentType
+	Data          json.RawMessage
+}
+
+// Type is a method to get the type of a component.
+func (u UnknownComponent) Type() ComponentType {
+	return u.ComponentType
+}
+
+// MarshalJSON is a method for marshaling UnknownComponent to a JSON object.
+func (u UnknownComponent) MarshalJSON() ([]byte, error) {
+	return u.Data, nil
+}
+
 // ActionsRow is a container for components within one row.
 type ActionsRow struct {
 	Components []MessageComponent `json:"components"`
@@ -265,3 +311,257 @@ const (
 	TextInputShort     TextInputStyle = 1
 	TextInputParagraph TextInputStyle = 2
 )
+
+// NOTE: The components below are part of the "Components V2" layout system and
+// can only be sent in messages with the MessageFlagsIsComponentsV2 flag set.
+// Such messages cannot have content or embeds.
+
+// UnfurledMediaItem represents a piece of media referenced by a component.
+type UnfurledMediaItem struct {
+	// URL of the media, supports arbitrary urls and attachment://<filename> references.
+	URL string `json:"url"`
+}
+
+// Section is a top-level layout component which associates text with an accessory.
+type Section struct {
+	// Unique identifier for the component, assigned by Discord if not set.
+	ID int `json:"id,omitempty"`
+	// One to three TextDisplay components.
+	Components []MessageComponent `json:"components"`
+	// A Thumbnail or a Button shown alongside the text.
+	Accessory MessageComponent `json:"accessory"`
+}
+
+// Type is a method to get the type of a component.
+func (Section) Type() ComponentType {
+	return SectionComponent
+}
+
+// MarshalJSON is a method for marshaling Section to a JSON object.
+func (s Section) MarshalJSON() ([]byte, error) {
+	type section Section
+
+	return Marshal(struct {
+		section
+		Type ComponentType `json:"type"`
+	}{
+		section: section(s),
+		Type:    s.Type(),
+	})
+}
+
+// UnmarshalJSON is a helper function to unmarshal Section.
+func (s *Section) UnmarshalJSON(data []byte) error {
+	var v struct {
+		ID            int                             `json:"id"`
+		RawComponents []unmarshalableMessageComponent `json:"components"`
+		RawAccessory  unmarshalableMessageComponent   `json:"accessory"`
+	}
+	err := json.Unmarshal(data, &v)
+	if err != nil {
+		return err
+	}
+
+	s.ID = v.ID
+	s.Components = make([]MessageComponent, len(v.RawComponents))
+	for i, v := range v.RawComponents {
+		s.Components[i] = v.MessageComponent
+	}
+	s.Accessory = v.RawAccessory.MessageComponent
+	return nil
+}
+
+// TextDisplay is a component which displays markdown formatted text.
+type TextDisplay struct {
+	// Unique identifier for the component, assigned by Discord if not set.
+	ID      int    `json:"id,omitempty"`
+	Content string `json:"content"`
+}
+
+// Type is a method to get the type of a component.
+func (TextDisplay) Type() ComponentType {
+	return TextDisplayComponent
+}
+
+// MarshalJSON is a method for marshaling TextDisplay to a JSON object.
+func (t TextDisplay) MarshalJSON() ([]byte, error) {
+	type textDisplay TextDisplay
+
+	return Marshal(struct {
+		textDisplay
+		Type ComponentType `json:"type"`
+	}{
+		textDisplay: textDisplay(t),
+		Type:        t.Type(),
+	})
+}
+
+// Thumbnail is a small image, only usable as the accessory of a Section.
+type Thumbnail struct {
+	// Unique identifier for the component, assigned by Discord if not set.
+	ID          int               `json:"id,omitempty"`
+	Media       UnfurledMediaItem `json:"media"`
+	Description *string           `json:"description,omitempty"`
+	Spoiler     bool              `json:"spoiler,omitempty"`
+}
+
+// Type is a method to get the type of a component.
+func (Thumbnail) Type() ComponentType {
+	return ThumbnailComponent
+}
+
+// MarshalJSON is a method for marshaling Thumbnail to a JSON object.
+func (t Thumbnail) MarshalJSON() ([]byte, error) {
+	type thumbnail Thumbnail
+
+	return Marshal(struct {
+		thumbnail
+		Type ComponentType `json:"type"`
+	}{
+		thumbnail: thumbnail(t),
+		Type:      t.Type(),
+	})
+}
+
+// MediaGalleryItem represents a single item of a MediaGallery.
+type MediaGalleryItem struct {
+	Media       UnfurledMediaItem `json:"media"`
+	Description *string           `json:"description,omitempty"`
+	Spoiler     bool              `json:"spoiler,omitempty"`
+}
+
+// MediaGallery is a component which displays one to ten media items in a gallery.
+type MediaGallery struct {
+	// Unique identifier for the component, assigned by Discord if not set.
+	ID    int                `json:"id,omitempty"`
+	Items []MediaGalleryItem `json:"items"`
+}
+
+// Type is a method to get the type of a component.
+func (MediaGallery) Type() ComponentType {
+	return MediaGalleryComponent
+}
+
+// MarshalJSON is a method for marshaling MediaGallery to a JSON object.
+func (m MediaGallery) MarshalJSON() ([]byte, error) {
+	type mediaGallery MediaGallery
+
+	return Marshal(struct {
+		mediaGallery
+		Type ComponentType `json:"type"`
+	}{
+		mediaGallery: mediaGallery(m),
+		Type:         m.Type(),
+	})
+}
+
+// FileComponent is a component which displays an uploaded file.
+type FileComponent struct {
+	// Unique identifier for the component, assigned by Discord if not set.
+	ID int `json:"id,omitempty"`
+	// NOTE: only attachment://<filename> references are supported.
+	File    UnfurledMediaItem `json:"file"`
+	Spoiler bool              `json:"spoiler,omitempty"`
+}
+
+// Type is a method to get the type of a component.
+func (FileComponent) Type() ComponentType {
+	return FileComponentType
+}
+
+// MarshalJSON is a method for marshaling FileComponent to a JSON object.
+func (f FileComponent) MarshalJSON() ([]byte, error) {
+	type fileComponent FileComponent
+
+	return Marshal(struct {
+		fileComponent
+		Type ComponentType `json:"type"`
+	}{
+		fileComponent: fileComponent(f),
+		Type:          f.Type(),
+	})
+}
+
+// SeparatorSpacingSize is the amount of vertical padding of a Separator.
+type SeparatorSpacingSize uint
+
+// Separator spacing sizes.
+const (
+	SeparatorSpacingSizeSmall SeparatorSpacingSize = 1
+	SeparatorSpacingSizeLarge SeparatorSpacingSize = 2
+)
+
+// Separator is a component which adds vertical padding and an optional divider between other components.
+type Separator struct {
+	// Unique identifier for the component, assigned by Discord if not set.
+	ID int `json:"id,omitempty"`
+	// Whether a visual divider should be displayed, defaults to true.
+	Divider *bool                `json:"divider,omitempty"`
+	Spacing SeparatorSpacingSize `json:"spacing,omitempty"`
+}
+
+// Type is a method to get the type of a component.
+func (Separator) Type() ComponentType {
+	return SeparatorComponent
+}
+
+// MarshalJSON is a method for marshaling Separator to a JSON object.
+func (s Separator) MarshalJSON() ([]byte, error) {
+	type separator Separator
+
+	return Marshal(struct {
+		separator
+		Type ComponentType `json:"type"`
+	}{
+		separator: separator(s),
+		Type:      s.Type(),
+	})
+}
+
+// Container is a top-level layout component which visually groups other components,
+// similar to an embed.
+type Container struct {
+	// Unique identifier for the component, assigned by Discord if not set.
+	ID          int                `json:"id,omitempty"`
+	Components  []MessageComponent `json:"components"`
+	AccentColor *int               `json:"accent_color,omitempty"`
+	Spoiler     bool               `json:"spoiler,omitempty"`
+}
+
+// Type is a method to get the type of a component.
+func (Container) Type() ComponentType {
+	return ContainerComponent
+}
+
+// MarshalJSON is a method for marshaling Container to a JSON object.
+func (c Container) MarshalJSON() ([]byte, error) {
+	type container Container
+
+	return Marshal(struct {
+		container
+		Type ComponentType `json:"type"`
+	}{
+		container: container(c),
+		Type:      c.Type(),
+	})
+}
+
+// UnmarshalJSON is a helper function to unmarshal Container.
+func (c *Container) UnmarshalJSON(data []byte) error {
+	type container Container
+	var v struct {
+		container
+		RawComponents []unmarshalableMessageComponent `json:"components"`
+	}
+	err := json.Unmarshal(data, &v)
+	if err != nil {
+		return err
+	}
+
+	*c = Container(v.container)
+	c.Components = make([]MessageComponent, len(v.RawComponents))
+	for i, v := range v.RawComponents {
+		c.Components[i] = v.MessageComponent
+	}
+	return nil
+}
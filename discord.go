@@ -15,6 +15,7 @@ package discordgo
 
 import (
 	"net/http"
+	"net/url"
 	"runtime"
 	"time"
 
@@ -33,20 +34,24 @@ func New(token string) (s *Session, err error) {
 
 	// Create an empty Session interface.
 	s = &Session{
-		State:                  NewState(),
-		Ratelimiter:            NewRatelimiter(),
-		StateEnabled:           true,
-		Compress:               true,
-		ShouldReconnectOnError: true,
-		ShouldRetryOnRateLimit: true,
-		ShardID:                0,
-		ShardCount:             1,
-		MaxRestRetries:         3,
-		Client:                 &http.Client{Timeout: (20 * time.Second)},
-		Dialer:                 websocket.DefaultDialer,
-		UserAgent:              "DiscordBot (https://github.com/bwmarrin/discordgo, v" + VERSION + ")",
-		sequence:               new(int64),
-		LastHeartbeatAck:       time.Now().UTC(),
+		State:                        NewState(),
+		Ratelimiter:                  NewRatelimiter(),
+		StateEnabled:                 true,
+		Compress:                     true,
+		ShouldReconnectOnError:       true,
+		ShouldRetryOnRateLimit:       true,
+		ShardID:                      0,
+		ShardCount:                   1,
+		MaxRestRetries:               3,
+		GatewayCommandLimit:          115,
+		ShouldWaitOnGatewayRateLimit: true,
+		VoiceJoinTimeout:             10 * time.Second,
+		Client:                       &http.Client{Timeout: (20 * time.Second)},
+		Dialer:                       websocket.DefaultDialer,
+		UserAgent:                    "DiscordBot (https://github.com/bwmarrin/discordgo, v" + VERSION + ")",
+		APIVersion:                   APIVersion,
+		sequence:                     new(int64),
+		LastHeartbeatAck:             time.Now().UTC(),
 	}
 
 	// Initialize the Identify Package with defaults
@@ -61,3 +66,196 @@ func New(token string) (s *Session, err error) {
 
 	return
 }
+
+// SessionOption is a function which configures a Session.
+// It can be supplied to NewWithOptions.
+type SessionOption func(s *Session)
+
+// NewWithOptions creates a new Discord session with provided token, like New,
+// and applies the given options to it in order.
+//
+//	s, err := discordgo.NewWithOptions("Bot "+token,
+//		discordgo.WithIntents(discordgo.IntentsGuildMessages),
+//		discordgo.WithProxyFunc(http.ProxyFromEnvironment),
+//		discordgo.WithState(false),
+//	)
+func NewWithOptions(token string, options ...SessionOption) (s *Session, err error) {
+	s, err = New(token)
+	if err != nil {
+		return
+	}
+
+	for _, option := range options {
+		option(s)
+	}
+	return
+}
+
+// WithHTTPClient changes the HTTP client used for REST requests.
+func WithHTTPClient(client *http.Client) SessionOption {
+	return func(s *Session) {
+		if client != nil {
+			s.Client = client
+		}
+	}
+}
+
+// WithDialer changes the websocket dialer used for the gateway connection.
+func WithDialer(dialer *websocket.Dialer) SessionOption {
+	return func(s *Session) {
+		if dialer != nil {
+			s.Dialer = dialer
+		}
+	}
+}
+
+// WithProxyFunc routes both REST requests and the gateway connection through
+// the proxy returned by proxy, e.g. http.ProxyURL or http.ProxyFromEnvironment.
+// The HTTP client and dialer are copied, so they may be shared with other code.
+func WithProxyFunc(proxy func(*http.Request) (*url.URL, error)) SessionOption {
+	return func(s *Session) {
+		client := *s.Client
+		transport, ok := client.Transport.(*http.Transport)
+		if !ok || transport == nil {
+			transport = http.DefaultTransport.(*http.Transport)
+		}
+		transport = transport.Clone()
+		transport.Proxy = proxy
+		client.Transport = transport
+		s.Client = &client
+
+		dialer := *s.Dialer
+		dialer.Proxy = proxy
+		s.Dialer = &dialer
+	}
+}
+
+// WithLogger changes the function messages logged by the session are sent to.
+func WithLogger(logger func(msgL, caller int, format string, a ...interface{})) SessionOption {
+	return func(s *Session) {
+		s.Logger = logger
+	}
+}
+
+// WithLogLevel changes the level of messages logged by the session.
+func WithLogLevel(level int) SessionOption {
+	return func(s *Session) {
+		s.LogLevel = level
+	}
+}
+
+// WithIntents changes the gateway intents sent when identifying.
+func WithIntents(intents Intent) SessionOption {
+	return func(s *Session) {
+		s.Identify.Intents = intents
+	}
+}
+
+// WithState controls whether the session keeps the State up to date with
+// received events.
+func WithState(enabled bool) SessionOption {
+	return func(s *Session) {
+		s.StateEnabled = enabled
+	}
+}
+
+// Clone creates a new Session which shares the REST and state resources of s
+// but has its own, initially empty, set of event handlers. Events received
+// by s are dispatched to the handlers of the clone as well, with the clone
+// passed as the session, so separate modules can register and remove their
+// handlers without affecting each other.
+//
+// Shared with s: Token, Identify, Client, Dialer, UserAgent, Ratelimiter,
+// ResponseCache, State and Store. Sharing the Ratelimiter keeps REST rate
+// limits coordinated across all clones.
+//
+// Independent: event handlers and the remaining settings, which are copied
+// from s when cloning, such as LogLevel, SyncEvents, BufferEvents,
+// MaxRestRetries, RequestMiddleware and DefaultAllowedMentions. A clone with
+// MaxConcurrentHandlers set runs its handlers on a pool of its own.
+//
+// A clone has no gateway connection of its own and must not be opened;
+// gateway commands, such as UpdateGameStatus, must be sent through s.
+// Call Detach on the clone once it is no longer needed, so s stops
+// dispatching events to it and it can be garbage collected.
+func (s *Session) Clone() *Session {
+	s.RLock()
+	c := &Session{
+		Token:                        s.Token,
+		MFA:                          s.MFA,
+		Debug:                        s.Debug,
+		LogLevel:                     s.LogLevel,
+		Logger:                       s.Logger,
+		OnUnknownEnum:                s.OnUnknownEnum,
+		ShouldReconnectOnError:       s.ShouldReconnectOnError,
+		ShouldRetryOnRateLimit:       s.ShouldRetryOnRateLimit,
+		Identify:                     s.Identify,
+		Compress:                     s.Compress,
+		ShardID:                      s.ShardID,
+		ShardCount:                   s.ShardCount,
+		StateEnabled:                 s.StateEnabled,
+		PreferState:                  s.PreferState,
+		SyncEvents:                   s.SyncEvents,
+		BufferEvents:                 s.BufferEvents,
+		MaxConcurrentHandlers:        s.MaxConcurrentHandlers,
+		HandlerQueueSize:             s.HandlerQueueSize,
+		DropEventsWhenQueueFull:      s.DropEventsWhenQueueFull,
+		MaxRestRetries:               s.MaxRestRetries,
+		MaxRateLimitWait:             s.MaxRateLimitWait,
+		GatewayCommandLimit:          s.GatewayCommandLimit,
+		ShouldWaitOnGatewayRateLimit: s.ShouldWaitOnGatewayRateLimit,
+		HeartbeatInterval:            s.HeartbeatInterval,
+		CheckSessionStartLimit:       s.CheckSessionStartLimit,
+		CheckPermissions:             s.CheckPermissions,
+		VoiceJoinTimeout:             s.VoiceJoinTimeout,
+		DefaultAllowedMentions:       s.DefaultAllowedMentions,
+		State:                        s.State,
+		Store:                        s.Store,
+		Client:                       s.Client,
+		Dialer:                       s.Dialer,
+		UserAgent:                    s.UserAgent,
+		APIVersion:                   s.APIVersion,
+		GatewayURL:                   s.GatewayURL,
+		ResponseCache:                s.ResponseCache,
+		RequestMiddleware:            s.RequestMiddleware,
+		Ratelimiter:                  s.Ratelimiter,
+		sequence:                     new(int64),
+		LastHeartbeatAck:             time.Now().UTC(),
+		parent:                       s,
+	}
+	s.RUnlock()
+
+	s.clonesMu.Lock()
+	s.clones = append(s.clones, c)
+	s.clonesMu.Unlock()
+
+	return c
+}
+
+// Detach stops dispatching events to a Session created with Clone. Its
+// handlers are no longer called, but it can still be used for REST requests.
+// Detach does nothing on a Session which isn't a clone, or is already detached.
+func (s *Session) Detach() {
+	s.Lock()
+	parent := s.parent
+	s.parent = nil
+	s.Unlock()
+
+	if parent == nil {
+		return
+	}
+
+	parent.clonesMu.Lock()
+	defer s.handlerPool.stop()
+	defer parent.clonesMu.Unlock()
+
+	// dispatch iterates over a copy of the slice header, so build a new slice
+	// instead of removing the clone in place.
+	clones := make([]*Session, 0, len(parent.clones))
+	for _, c := range parent.clones {
+		if c != s {
+			clones = append(clones, c)
+		}
+	}
+	parent.clones = clones
+}
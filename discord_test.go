@@ -1,12 +1,25 @@
 package discordgo
 
 import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
+	"reflect"
 	"runtime"
+	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/gorilla/websocket"
 )
 
 //////////////////////////////////////////////////////////////////////////////
@@ -185,6 +198,883 @@ func TestRemoveHandler(t *testing.T) {
 	}
 }
 
+func TestMaxConcurrentHandlers(t *testing.T) {
+	d := Session{MaxConcurrentHandlers: 2, HandlerQueueSize: 1, DropEventsWhenQueueFull: true}
+
+	var running, max int32
+	release := make(chan struct{})
+	started := make(chan struct{}, 10)
+	d.AddHandler(func(s *Session, m *MessageCreate) {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			m := atomic.LoadInt32(&max)
+			if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+				break
+			}
+		}
+		started <- struct{}{}
+		<-release
+		atomic.AddInt32(&running, -1)
+	})
+
+	// Two calls are running and one is queued, the rest are dropped.
+	for n := 0; n < 2; n++ {
+		d.handleEvent(messageCreateEventType, &MessageCreate{})
+		<-started
+	}
+	for n := 0; n < 3; n++ {
+		d.handleEvent(messageCreateEventType, &MessageCreate{})
+	}
+
+	if dropped := d.DroppedHandlerCalls(); dropped != 2 {
+		t.Errorf("got %d dropped handler calls, want 2", dropped)
+	}
+
+	close(release)
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the queued handler call")
+	}
+	if m := atomic.LoadInt32(&max); m > 2 {
+		t.Errorf("got %d concurrent handler calls, want at most 2", m)
+	}
+
+	disconnected := make(chan struct{})
+	d.AddHandler(func(s *Session, e *Disconnect) { close(disconnected) })
+	d.Close()
+	select {
+	case <-disconnected:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the Disconnect handler queued by Close")
+	}
+	if d.handlerPool.calls != nil {
+		t.Error("handler pool still running after Close")
+	}
+}
+
+func TestHandlerPoolStoppedOnDisconnect(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"op":10,"d":{"heartbeat_interval":45000}}`))
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return
+		}
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"op":0,"t":"RESUMED","s":43,"d":{}}`))
+
+		// Drop the connection, so the session fails reading from it.
+		conn.Close()
+	}))
+	defer server.Close()
+
+	d, _ := New("Bot token")
+	d.GatewayURL = "ws" + strings.TrimPrefix(server.URL, "http")
+	d.ShouldReconnectOnError = false
+	d.MaxConcurrentHandlers = 1
+	d.sessionID = "session"
+
+	disconnected := make(chan *Disconnect, 1)
+	d.AddHandler(func(s *Session, e *Disconnect) { disconnected <- e })
+
+	if err := d.Open(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case e := <-disconnected:
+		if e.Reconnect {
+			t.Errorf("got Disconnect event %+v, want no reconnect", e)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the Disconnect event")
+	}
+
+	for start := time.Now(); ; time.Sleep(time.Millisecond) {
+		d.handlerPool.Lock()
+		stopped := d.handlerPool.calls == nil
+		d.handlerPool.Unlock()
+		if stopped {
+			break
+		}
+		if time.Since(start) > time.Second {
+			t.Fatal("handler pool still running after a disconnect without reconnect")
+		}
+	}
+}
+
+func TestBufferEvents(t *testing.T) {
+	d := Session{SyncEvents: true, BufferEvents: 3, StateEnabled: true, State: NewState()}
+
+	var got []string
+	d.handleEvent(guildCreateEventType, &GuildCreate{Guild: &Guild{ID: "guild"}})
+	d.handleEvent(messageCreateEventType, &MessageCreate{Message: &Message{ID: "1"}})
+
+	if _, err := d.State.Guild("guild"); err != nil {
+		t.Errorf("buffered event was not added to the state: %v", err)
+	}
+
+	d.AddHandler(func(s *Session, g *GuildCreate) { got = append(got, "guild") })
+	d.AddHandler(func(s *Session, m *MessageCreate) { got = append(got, m.ID) })
+	d.ReleaseEvents()
+	d.ReleaseEvents()
+	d.handleEvent(messageCreateEventType, &MessageCreate{Message: &Message{ID: "2"}})
+
+	if want := []string{"guild", "1", "2"}; strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("got events %v, want %v", got, want)
+	}
+
+	// A full buffer is released without calling ReleaseEvents.
+	d = Session{SyncEvents: true, BufferEvents: 2}
+	got = nil
+	d.AddHandler(func(s *Session, m *MessageCreate) { got = append(got, m.ID) })
+	for _, id := range []string{"1", "2", "3"} {
+		d.handleEvent(messageCreateEventType, &MessageCreate{Message: &Message{ID: id}})
+		if id == "1" && len(got) != 0 {
+			t.Errorf("event dispatched before the buffer was full")
+		}
+	}
+	if want := []string{"1", "2", "3"}; strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("got events %v, want %v", got, want)
+	}
+}
+
+func TestClone(t *testing.T) {
+	d := Session{SyncEvents: true, Ratelimiter: NewRatelimiter()}
+	c := d.Clone()
+
+	if c.Ratelimiter != d.Ratelimiter {
+		t.Fatalf("clone does not share the rate limiter")
+	}
+
+	var parentCalled, cloneCalled int
+	d.AddHandler(func(s *Session, m *MessageCreate) {
+		parentCalled++
+	})
+	remove := c.AddHandler(func(s *Session, m *MessageCreate) {
+		if s != c {
+			t.Errorf("clone handler was not passed the clone")
+		}
+		cloneCalled++
+	})
+
+	d.handleEvent(messageCreateEventType, &MessageCreate{})
+	if parentCalled != 1 || cloneCalled != 1 {
+		t.Fatalf("expected both handlers to be called once, got parent %d, clone %d", parentCalled, cloneCalled)
+	}
+
+	remove()
+	d.handleEvent(messageCreateEventType, &MessageCreate{})
+	if parentCalled != 2 || cloneCalled != 1 {
+		t.Fatalf("removing the clone handler affected dispatch, got parent %d, clone %d", parentCalled, cloneCalled)
+	}
+
+	c.AddHandler(func(s *Session, m *MessageCreate) {
+		cloneCalled++
+	})
+	c.Detach()
+	c.Detach()
+	d.handleEvent(messageCreateEventType, &MessageCreate{})
+	if parentCalled != 3 || cloneCalled != 1 || len(d.clones) != 0 {
+		t.Fatalf("detached clone still received events, got parent %d, clone %d, %d clones", parentCalled, cloneCalled, len(d.clones))
+	}
+}
+
+// setNonZero sets v to a non-zero value of its type.
+func setNonZero(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(1)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(1)
+	case reflect.String:
+		v.SetString("x")
+	case reflect.Ptr:
+		v.Set(reflect.New(v.Type().Elem()))
+	case reflect.Slice:
+		v.Set(reflect.MakeSlice(v.Type(), 1, 1))
+	case reflect.Map:
+		v.Set(reflect.MakeMap(v.Type()))
+	case reflect.Func:
+		v.Set(reflect.MakeFunc(v.Type(), func(args []reflect.Value) []reflect.Value {
+			results := make([]reflect.Value, v.Type().NumOut())
+			for i := range results {
+				results[i] = reflect.Zero(v.Type().Out(i))
+			}
+			return results
+		}))
+	case reflect.Interface:
+		v.Set(reflect.ValueOf(NewState()))
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Field(i).CanSet() {
+				setNonZero(v.Field(i))
+			}
+		}
+	}
+}
+
+func TestCloneCopiesExportedFields(t *testing.T) {
+	// Connection state belongs to the session holding the gateway
+	// connection, so it is not copied to a clone.
+	notCopied := map[string]bool{
+		"RWMutex":           true,
+		"DataReady":         true,
+		"VoiceReady":        true,
+		"UDPReady":          true,
+		"VoiceConnections":  true,
+		"LastHeartbeatAck":  true,
+		"LastHeartbeatSent": true,
+	}
+
+	d := &Session{}
+	dv := reflect.ValueOf(d).Elem()
+	for i := 0; i < dv.NumField(); i++ {
+		if dv.Field(i).CanSet() && !notCopied[dv.Type().Field(i).Name] {
+			setNonZero(dv.Field(i))
+		}
+	}
+
+	c := d.Clone()
+	cv := reflect.ValueOf(c).Elem()
+	for i := 0; i < dv.NumField(); i++ {
+		name := dv.Type().Field(i).Name
+		if !dv.Field(i).CanSet() || notCopied[name] {
+			continue
+		}
+
+		want, got := dv.Field(i), cv.Field(i)
+		switch want.Kind() {
+		case reflect.Func, reflect.Ptr, reflect.Map:
+			if want.Pointer() != got.Pointer() {
+				t.Errorf("%s was not copied to the clone", name)
+			}
+		default:
+			if !reflect.DeepEqual(want.Interface(), got.Interface()) {
+				t.Errorf("%s was not copied to the clone, got %v, want %v", name, got.Interface(), want.Interface())
+			}
+		}
+	}
+}
+
+func TestShardSessionsWaitReady(t *testing.T) {
+	shards := ShardSessions{{ShardID: 0, ShardCount: 2}, {ShardID: 1, ShardCount: 2}}
+	shards[0].setConnectionState(ConnectionStateReady)
+
+	if !shards.ShardReady(0) || shards.ShardReady(1) || shards.ShardReady(2) {
+		t.Errorf("ShardReady() = %v, %v, %v, want only shard 0 ready", shards.ShardReady(0), shards.ShardReady(1), shards.ShardReady(2))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := shards.WaitReady(ctx); !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "shard 1") {
+		t.Errorf("WaitReady() error = %v, want shard 1 to time out", err)
+	}
+
+	go shards[1].setConnectionState(ConnectionStateReady)
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := shards.WaitReady(ctx); err != nil {
+		t.Errorf("WaitReady() error = %v, want all shards ready", err)
+	}
+}
+
+func TestConnectionStateChange(t *testing.T) {
+	d := Session{}
+
+	changes := make(chan ConnectionState, 10)
+	d.OnConnectionStateChange(func(state ConnectionState) {
+		changes <- state
+	})
+
+	d.setConnectionState(ConnectionStateConnecting)
+	d.setConnectionState(ConnectionStateConnecting)
+	d.setConnectionState(ConnectionStateIdentifying)
+	d.setConnectionState(ConnectionStateReady)
+	d.setConnectionState(ConnectionStateDisconnected)
+
+	want := []ConnectionState{ConnectionStateConnecting, ConnectionStateIdentifying, ConnectionStateReady, ConnectionStateDisconnected}
+	for _, w := range want {
+		select {
+		case got := <-changes:
+			if got != w {
+				t.Fatalf("got state %s, want %s", got, w)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for state %s", w)
+		}
+	}
+
+	select {
+	case got := <-changes:
+		t.Fatalf("unexpected state change to %s", got)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestRawGatewayMessage(t *testing.T) {
+	d := Session{}
+
+	var (
+		opcode int
+		raw    []byte
+	)
+	remove := d.OnRawGatewayMessage(func(op int, eventType string, r []byte) {
+		opcode = op
+		raw = r
+	})
+
+	message := []byte(`{"op":11,"d":null}`)
+	if _, err := d.onEvent(websocket.TextMessage, message); err != nil {
+		t.Fatalf("onEvent returned error: %s", err)
+	}
+	if opcode != 11 || string(raw) != string(message) {
+		t.Fatalf("hook got op %d and %q, want op 11 and %q", opcode, raw, message)
+	}
+	message[0] = 'x'
+	if raw[0] != '{' {
+		t.Error("hook was passed the message buffer instead of a copy")
+	}
+
+	var compressed bytes.Buffer
+	z := zlib.NewWriter(&compressed)
+	z.Write([]byte(`{"op":11,"d":null}`))
+	z.Close()
+	raw = nil
+	if _, err := d.onEvent(websocket.BinaryMessage, compressed.Bytes()); err != nil {
+		t.Fatalf("onEvent returned error: %s", err)
+	}
+	if string(raw) != `{"op":11,"d":null}` {
+		t.Errorf("hook got %q, want the decompressed message", raw)
+	}
+
+	remove()
+	raw = nil
+	if _, err := d.onEvent(websocket.TextMessage, []byte(`{"op":11,"d":null}`)); err != nil {
+		t.Fatalf("onEvent returned error: %s", err)
+	}
+	if raw != nil || d.rawGatewayHooksEnabled() {
+		t.Error("hook was called after being removed")
+	}
+}
+
+func TestResumeState(t *testing.T) {
+	d := Session{sequence: new(int64)}
+	d.onReady(&Ready{SessionID: "session", ResumeGatewayURL: "wss://resume.discord.gg"})
+	atomic.StoreInt64(d.sequence, 42)
+
+	want := ResumeState{SessionID: "session", Sequence: 42, GatewayURL: "wss://resume.discord.gg"}
+	if got := d.ResumeState(); got != want {
+		t.Errorf("got resume state %+v, want %+v", got, want)
+	}
+}
+
+func TestInvalidSession(t *testing.T) {
+	wait := invalidSessionWait
+	invalidSessionWait = func() time.Duration { return 100 * time.Millisecond }
+	defer func() { invalidSessionWait = wait }()
+
+	type request struct {
+		path string
+		op   int
+	}
+	requests := make(chan request, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		if r.URL.Path == "/resume" {
+			conn.WriteMessage(websocket.TextMessage, []byte(`{"op":10,"d":{"heartbeat_interval":45000}}`))
+		}
+		var op struct {
+			Op int `json:"op"`
+		}
+		if err := conn.ReadJSON(&op); err != nil {
+			return
+		}
+		requests <- request{r.URL.Path, op.Op}
+		if r.URL.Path == "/resume" {
+			conn.WriteMessage(websocket.TextMessage, []byte(`{"op":0,"t":"RESUMED","s":43,"d":{}}`))
+		}
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	tests := []struct {
+		name     string
+		message  string
+		want     request
+		resumeID string
+	}{
+		// Resuming needs a new connection to the resume gateway.
+		{"resumable", `{"op":9,"d":true}`, request{"/resume", 6}, "session"},
+		{"not resumable", `{"op":9,"d":false}`, request{"/", 2}, ""},
+	}
+
+	for _, tt := range tests {
+		conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		d, _ := New("Bot token")
+		d.wsConn = conn
+		d.gateway = url
+		d.sessionID = "session"
+		d.resumeGatewayURL = url + "/resume"
+		atomic.StoreInt64(d.sequence, 42)
+
+		// The wait happens off the read loop.
+		start := time.Now()
+		if _, err := d.onEvent(websocket.TextMessage, []byte(tt.message)); err != nil {
+			t.Fatalf("%s: onEvent returned error: %s", tt.name, err)
+		}
+		if elapsed := time.Since(start); elapsed >= 100*time.Millisecond {
+			t.Errorf("%s: onEvent blocked for %v", tt.name, elapsed)
+		}
+
+		select {
+		case got := <-requests:
+			if got != tt.want {
+				t.Errorf("%s: got op %d on %s, want op %d on %s", tt.name, got.op, got.path, tt.want.op, tt.want.path)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: timed out waiting for the response", tt.name)
+		}
+		if id := d.ResumeState().SessionID; id != tt.resumeID {
+			t.Errorf("%s: got session ID %q, want %q", tt.name, id, tt.resumeID)
+		}
+		d.Close()
+		conn.Close()
+	}
+}
+
+// recordGateway connects a session to a test gateway which records the
+// payloads sent by the session.
+func recordGateway(t *testing.T, d *Session) (payloads chan []byte, closer func()) {
+	payloads = make(chan []byte, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			_, m, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			payloads <- m
+		}
+	}))
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		server.Close()
+		t.Fatal(err)
+	}
+	d.wsConn = conn
+
+	return payloads, func() {
+		conn.Close()
+		server.Close()
+	}
+}
+
+func TestUpdateCustomStatus(t *testing.T) {
+	d, _ := New("Bot token")
+	payloads, closer := recordGateway(t, d)
+	defer closer()
+
+	if err := d.UpdateCustomStatus(strings.Repeat("a", CustomStatusMaxLength+1), nil); err != ErrCustomStatusLength {
+		t.Errorf("UpdateCustomStatus() with long text error = %v, want %v", err, ErrCustomStatusLength)
+	}
+	if err := d.UpdateCustomStatus("Raiding", &Emoji{ID: "1", Name: "sword", Roles: []string{"role"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	var op struct {
+		Op   int `json:"op"`
+		Data struct {
+			Activities []map[string]interface{} `json:"activities"`
+		} `json:"d"`
+	}
+	select {
+	case m := <-payloads:
+		if err := json.Unmarshal(m, &op); err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the status update")
+	}
+
+	if op.Op != 3 || len(op.Data.Activities) != 1 {
+		t.Fatalf("got op %d with activities %v", op.Op, op.Data.Activities)
+	}
+	a := op.Data.Activities[0]
+	emoji, _ := a["emoji"].(map[string]interface{})
+	if a["type"] != float64(ActivityTypeCustom) || a["state"] != "Raiding" || a["name"] != "Custom Status" || emoji["id"] != "1" || emoji["name"] != "sword" || emoji["roles"] != nil {
+		t.Errorf("unexpected custom status activity %v", a)
+	}
+}
+
+func TestConnectDisconnectEvents(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"op":10,"d":{"heartbeat_interval":45000}}`))
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return
+		}
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"op":0,"t":"RESUMED","s":43,"d":{}}`))
+
+		// Wait for the client to close the connection.
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	d, _ := New("Bot token")
+	d.GatewayURL = "ws" + strings.TrimPrefix(server.URL, "http")
+	d.SyncEvents = true
+	d.sessionID = "session"
+	atomic.StoreInt64(d.sequence, 42)
+
+	var connect *Connect
+	var disconnect *Disconnect
+	d.AddHandler(func(s *Session, c *Connect) { connect = c })
+	d.AddHandler(func(s *Session, c *Disconnect) { disconnect = c })
+
+	if err := d.open(3); err != nil {
+		t.Fatal(err)
+	}
+	if connect == nil || connect.Attempt != 3 || !connect.Resumed || connect.Latency <= 0 {
+		t.Errorf("got Connect event %+v, want attempt 3 of a resumed session", connect)
+	}
+
+	d.Close()
+	if disconnect == nil || disconnect.Reconnect {
+		t.Errorf("got Disconnect event %+v, want no reconnect after Close", disconnect)
+	}
+}
+
+func TestHeartbeatInterval(t *testing.T) {
+	heartbeats := make(chan struct{}, 100)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"op":10,"d":{"heartbeat_interval":45000}}`))
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return
+		}
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"op":0,"t":"RESUMED","s":43,"d":{}}`))
+
+		// Never acknowledge heartbeats.
+		for {
+			_, m, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if strings.HasPrefix(string(m), `{"op":1,`) {
+				heartbeats <- struct{}{}
+			}
+		}
+	}))
+	defer server.Close()
+
+	d, _ := New("Bot token")
+	d.GatewayURL = "ws" + strings.TrimPrefix(server.URL, "http")
+	d.ShouldReconnectOnError = false
+	d.HeartbeatInterval = 10 * time.Millisecond
+	d.sessionID = "session"
+
+	disconnected := make(chan *Disconnect, 1)
+	d.AddHandler(func(s *Session, e *Disconnect) { disconnected <- e })
+
+	if err := d.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	select {
+	case e := <-disconnected:
+		if e.CloseReason != "no heartbeat ACK received" {
+			t.Errorf("got Disconnect event %+v, want a missing heartbeat ACK", e)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the zombied connection to be closed")
+	}
+	if n := len(heartbeats); n < int(FailedHeartbeatAcks/time.Millisecond) {
+		t.Errorf("got %d heartbeats before closing the connection, want at least %d", n, FailedHeartbeatAcks/time.Millisecond)
+	}
+}
+
+func TestHeartbeatTimeout(t *testing.T) {
+	for _, interval := range []time.Duration{41250 * time.Millisecond, time.Hour, 24 * time.Hour} {
+		if got, want := heartbeatTimeout(interval), 5*interval; got != want {
+			t.Errorf("heartbeatTimeout(%v) = %v, want %v", interval, got, want)
+		}
+	}
+}
+
+func TestSessionStartLimit(t *testing.T) {
+	d, _ := New("Bot token")
+	d.CheckSessionStartLimit = true
+
+	requests := 0
+	d.Client.Transport = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		requests++
+		body := `{"url":"wss://gateway.discord.gg","session_start_limit":{"total":1000,"remaining":1,"reset_after":60000,"max_concurrency":1}}`
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(body))}, nil
+	})
+
+	if _, ok := d.SessionStartLimit(); ok {
+		t.Error("session start limit known before calling GatewayBot")
+	}
+
+	if err := d.reserveSessionStart(); err != nil {
+		t.Fatalf("first identify: %v", err)
+	}
+	if limit, ok := d.SessionStartLimit(); !ok || limit.Remaining != 0 || limit.ResetAfter <= 0 || limit.ResetAfter > 60000 {
+		t.Errorf("got session start limit %+v, %v after identifying", limit, ok)
+	}
+
+	var limitErr *SessionStartLimitError
+	if err := d.reserveSessionStart(); !errors.As(err, &limitErr) || limitErr.ResetAt.Before(time.Now()) {
+		t.Errorf("got error %v for an exhausted limit, want *SessionStartLimitError", err)
+	}
+	if requests != 1 {
+		t.Errorf("made %d requests to GatewayBot, want 1", requests)
+	}
+}
+
+func TestOpenSessionStartLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"op":10,"d":{"heartbeat_interval":45000}}`))
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	d, _ := New("Bot token")
+	d.GatewayURL = "ws" + strings.TrimPrefix(server.URL, "http")
+	d.ShouldReconnectOnError = false
+	d.CheckSessionStartLimit = true
+	d.Client.Transport = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		body := `{"url":"wss://gateway.discord.gg","session_start_limit":{"total":1000,"remaining":0,"reset_after":60000,"max_concurrency":1}}`
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(body)), Header: http.Header{}}, nil
+	})
+
+	err := d.Open()
+	defer d.Close()
+
+	var limitErr *SessionStartLimitError
+	if !errors.As(err, &limitErr) {
+		t.Errorf("got error %v from Open with an exhausted limit, want *SessionStartLimitError", err)
+	}
+}
+
+func TestGuildUnavailableEvent(t *testing.T) {
+	d, _ := New("Bot token")
+	d.SyncEvents = true
+	d.State.GuildAdd(&Guild{ID: "guild"})
+
+	var unavailable []string
+	d.AddHandler(func(s *Session, g *GuildUnavailable) { unavailable = append(unavailable, g.GuildID) })
+
+	for _, data := range []string{`{"id":"guild","unavailable":true}`, `{"id":"guild"}`} {
+		if _, err := d.onEvent(websocket.TextMessage, []byte(`{"op":0,"t":"GUILD_DELETE","s":1,"d":`+data+`}`)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(unavailable) != 1 || unavailable[0] != "guild" {
+		t.Errorf("got GuildUnavailable events for %v, want one for the outage", unavailable)
+	}
+}
+
+func TestGuildJoinEvent(t *testing.T) {
+	d, _ := New("Bot token")
+	d.SyncEvents = true
+
+	var joined []string
+	d.AddHandler(func(s *Session, g *GuildJoin) { joined = append(joined, g.ID) })
+
+	for _, event := range []string{
+		`"t":"READY","s":1,"d":{"session_id":"session","user":{"id":"bot"},"guilds":[{"id":"listed","unavailable":true}]}`,
+		`"t":"GUILD_CREATE","s":2,"d":{"id":"listed"}`,
+		`"t":"GUILD_CREATE","s":3,"d":{"id":"new"}`,
+		`"t":"GUILD_DELETE","s":4,"d":{"id":"listed","unavailable":true}`,
+		`"t":"GUILD_CREATE","s":5,"d":{"id":"listed"}`,
+		`"t":"GUILD_DELETE","s":6,"d":{"id":"new"}`,
+		`"t":"GUILD_CREATE","s":7,"d":{"id":"new"}`,
+	} {
+		if _, err := d.onEvent(websocket.TextMessage, []byte(`{"op":0,`+event+`}`)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if strings.Join(joined, ",") != "new,new" {
+		t.Errorf("got GuildJoin events for %v, want two for the joined guild", joined)
+	}
+}
+
+func TestUpdateVoiceState(t *testing.T) {
+	d, _ := New("Bot token")
+	d.SyncEvents = true
+	payloads, closer := recordGateway(t, d)
+	defer closer()
+
+	if err := d.UpdateVoiceState("guild", "channel", false, true); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case m := <-payloads:
+		if string(m) != `{"op":4,"d":{"guild_id":"guild","channel_id":"channel","self_mute":false,"self_deaf":true}}`+"\n" {
+			t.Errorf("unexpected voice state update %s", m)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the voice state update")
+	}
+
+	var servers []*VoiceServerUpdate
+	d.AddHandler(func(s *Session, v *VoiceServerUpdate) { servers = append(servers, v) })
+	if _, err := d.onEvent(websocket.TextMessage, []byte(`{"op":0,"t":"VOICE_SERVER_UPDATE","s":1,"d":{"token":"token","guild_id":"guild","endpoint":"voice.discord.media"}}`)); err != nil {
+		t.Fatal(err)
+	}
+	if len(servers) != 1 || servers[0].Token != "token" || len(d.VoiceConnections) != 0 {
+		t.Errorf("got VoiceServerUpdate events %v and voice connections %v", servers, d.VoiceConnections)
+	}
+}
+
+func TestUnknownEnumWarnings(t *testing.T) {
+	d, _ := New("Bot token")
+	d.SyncEvents = true
+	d.LogLevel = LogWarning
+
+	var warnings []string
+	d.Logger = func(msgL, caller int, format string, a ...interface{}) {
+		if msgL == LogWarning {
+			warnings = append(warnings, fmt.Sprintf(format, a...))
+		}
+	}
+
+	for _, event := range []string{
+		`"t":"CHANNEL_CREATE","s":1,"d":{"id":"channel","type":0}`,
+		`"t":"CHANNEL_CREATE","s":2,"d":{"id":"channel","type":99}`,
+		`"t":"CHANNEL_UPDATE","s":3,"d":{"id":"channel","type":99}`,
+		`"t":"MESSAGE_CREATE","s":4,"d":{"id":"message","type":98}`,
+	} {
+		if _, err := d.onEvent(websocket.TextMessage, []byte(`{"op":0,`+event+`}`)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(warnings) != 2 || !strings.Contains(warnings[0], "ChannelType 99") || !strings.Contains(warnings[1], "MessageType 98") {
+		t.Errorf("got warnings %q, want one for each unknown type", warnings)
+	}
+	if !ChannelTypeGuildMedia.IsKnown() || ChannelType(99).IsKnown() || !MessageTypePollResult.IsKnown() || MessageType(98).IsKnown() {
+		t.Errorf("unexpected IsKnown results")
+	}
+
+	var unknown []string
+	d.OnUnknownEnum = func(name string, value int) {
+		unknown = append(unknown, fmt.Sprintf("%s %d", name, value))
+	}
+	for _, event := range []string{
+		`"t":"MESSAGE_UPDATE","s":5,"d":{"id":"message","channel_id":"channel","type":97}`,
+		`"t":"THREAD_LIST_SYNC","s":6,"d":{"guild_id":"guild","threads":[{"id":"thread","type":96}]}`,
+		`"t":"THREAD_LIST_SYNC","s":7,"d":{"guild_id":"guild","threads":[{"id":"thread","type":96}]}`,
+	} {
+		if _, err := d.onEvent(websocket.TextMessage, []byte(`{"op":0,`+event+`}`)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := []string{"MessageType 97", "ChannelType 96"}; strings.Join(unknown, ",") != strings.Join(want, ",") || len(warnings) != 2 {
+		t.Errorf("got OnUnknownEnum calls %q and warnings %q, want %q and no new warnings", unknown, warnings, want)
+	}
+}
+
+func TestGuildsLoadedEvent(t *testing.T) {
+	d, _ := New("Bot token")
+	d.SyncEvents = true
+
+	var loaded []*GuildsLoaded
+	d.AddHandler(func(s *Session, l *GuildsLoaded) { loaded = append(loaded, l) })
+
+	for i, event := range []string{
+		`"t":"READY","s":1,"d":{"session_id":"session","user":{"id":"bot"},"guilds":[{"id":"a","unavailable":true},{"id":"b","unavailable":true},{"id":"c","unavailable":true}]}`,
+		`"t":"GUILD_CREATE","s":2,"d":{"id":"a"}`,
+		`"t":"GUILD_DELETE","s":3,"d":{"id":"b","unavailable":true}`,
+		`"t":"GUILD_DELETE","s":4,"d":{"id":"c"}`,
+		`"t":"GUILD_CREATE","s":5,"d":{"id":"b"}`,
+		`"t":"GUILD_CREATE","s":6,"d":{"id":"a"}`,
+	} {
+		if _, err := d.onEvent(websocket.TextMessage, []byte(`{"op":0,`+event+`}`)); err != nil {
+			t.Fatal(err)
+		}
+		if want := i >= 4; (len(loaded) > 0) != want {
+			t.Fatalf("after event %d got %d GuildsLoaded events", i, len(loaded))
+		}
+	}
+
+	if len(loaded) != 1 || loaded[0].GuildCount != 2 || loaded[0].Duration < 0 {
+		t.Errorf("got GuildsLoaded events %+v, want one for two guilds", loaded)
+	}
+}
+
+func TestIntentWarnings(t *testing.T) {
+	d := Session{StateEnabled: true, State: NewState()}
+	d.Identify.Intents = IntentsAllWithoutPrivileged
+	if warnings := d.intentWarnings(); len(warnings) != 0 {
+		t.Errorf("unexpected warnings with default intents: %v", warnings)
+	}
+
+	d.Identify.Intents = IntentGuildMembers
+	d.State.MaxMessageCount = 10
+	if warnings := d.intentWarnings(); len(warnings) != 2 {
+		t.Errorf("expected 2 warnings without guild and message intents, got %v", warnings)
+	}
+
+	d.StateEnabled = false
+	if warnings := d.intentWarnings(); len(warnings) != 0 {
+		t.Errorf("unexpected warnings with state disabled: %v", warnings)
+	}
+}
+
 func TestScheduledEvents(t *testing.T) {
 	if dgBot == nil {
 		t.Skip("Skipping, dgBot not set.")
@@ -258,6 +1148,40 @@ func TestScheduledEvents(t *testing.T) {
 	}
 }
 
+func TestScheduledEventRecurrenceRule(t *testing.T) {
+	d, _ := New("Bot token")
+	var sent map[string]json.RawMessage
+	d.Client.Transport = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		json.NewDecoder(r.Body).Decode(&sent)
+		body := `{"id":"event","recurrence_rule":{"start":"2024-01-01T18:00:00+00:00","end":null,"frequency":1,"interval":1,"by_weekday":null,"by_n_weekday":[{"n":2,"day":4}],"by_month":null,"by_month_day":null,"by_year_day":null,"count":null}}`
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(body)), Header: http.Header{}}, nil
+	})
+
+	start := time.Date(2024, 1, 1, 18, 0, 0, 0, time.UTC)
+	event, err := d.GuildScheduledEventCreate("guild", &GuildScheduledEventParams{
+		Name:               "Game night",
+		ScheduledStartTime: &start,
+		RecurrenceRule: &GuildScheduledEventRecurrenceRule{
+			Start:     start,
+			Frequency: GuildScheduledEventRecurrenceFrequencyWeekly,
+			Interval:  1,
+			ByWeekday: []GuildScheduledEventRecurrenceWeekday{GuildScheduledEventRecurrenceWeekdayFriday},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := `{"start":"2024-01-01T18:00:00Z","frequency":2,"interval":1,"by_weekday":[4]}`; string(sent["recurrence_rule"]) != want {
+		t.Errorf("sent recurrence rule %s, want %s", sent["recurrence_rule"], want)
+	}
+
+	r := event.RecurrenceRule
+	if r == nil || !r.Start.Equal(start) || r.Frequency != GuildScheduledEventRecurrenceFrequencyMonthly || len(r.ByNWeekday) != 1 || r.ByNWeekday[0].N != 2 || r.ByNWeekday[0].Day != GuildScheduledEventRecurrenceWeekdayFriday {
+		t.Errorf("got recurrence rule %+v", r)
+	}
+}
+
 func TestComplexScheduledEvents(t *testing.T) {
 	if dgBot == nil {
 		t.Skip("Skipping, dgBot not set.")
@@ -300,3 +1224,45 @@ func TestComplexScheduledEvents(t *testing.T) {
 		t.Fatal("err on GuildScheduledEventEdit. Change of entity type to voice failed")
 	}
 }
+
+func TestNewWithOptions(t *testing.T) {
+	proxyURL, _ := url.Parse("http://proxy.example:8080")
+	var logged string
+	d, err := NewWithOptions("Bot token",
+		WithProxyFunc(http.ProxyURL(proxyURL)),
+		WithIntents(IntentsGuildMessages),
+		WithState(false),
+		WithLogLevel(LogInformational),
+		WithLogger(func(msgL, caller int, format string, a ...interface{}) {
+			logged = fmt.Sprintf(format, a...)
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d.Identify.Intents != IntentsGuildMessages {
+		t.Errorf("Identify.Intents = %d, want %d", d.Identify.Intents, IntentsGuildMessages)
+	}
+	if d.StateEnabled {
+		t.Error("StateEnabled should be false")
+	}
+	if d.Dialer == websocket.DefaultDialer {
+		t.Error("WithProxyFunc modified the default dialer")
+	}
+
+	req, _ := http.NewRequest("GET", EndpointAPI, nil)
+	for name, proxy := range map[string]func(*http.Request) (*url.URL, error){
+		"transport": d.Client.Transport.(*http.Transport).Proxy,
+		"dialer":    d.Dialer.Proxy,
+	} {
+		if got, err := proxy(req); err != nil || got.String() != proxyURL.String() {
+			t.Errorf("%s proxy = %v, %v, want %s", name, got, err, proxyURL)
+		}
+	}
+
+	d.log(LogInformational, "hello %s", "world")
+	if logged != "hello world" {
+		t.Errorf("session logger got %q", logged)
+	}
+}
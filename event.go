@@ -1,5 +1,10 @@
 package discordgo
 
+import (
+	"sync"
+	"sync/atomic"
+)
+
 // EventHandler is an interface for Discord events.
 type EventHandler interface {
 	// Type returns the type of event this handler belongs to.
@@ -162,42 +167,241 @@ func (s *Session) removeEventHandlerInstance(t string, ehi *eventHandlerInstance
 	}
 }
 
+// handlerCall is a call of an event handler, which is made once the
+// handlers lock is released so that handlers may add and remove handlers.
+type handlerCall struct {
+	s  *Session
+	eh EventHandler
+}
+
 // Handles calling permanent and once handlers for an event type.
-func (s *Session) handle(t string, i interface{}) {
+// The calls are appended to calls.
+func (s *Session) handle(t string, calls []handlerCall) []handlerCall {
 	for _, eh := range s.handlers[t] {
-		if s.SyncEvents {
-			eh.eventHandler.Handle(s, i)
-		} else {
-			go eh.eventHandler.Handle(s, i)
-		}
+		calls = append(calls, handlerCall{s, eh.eventHandler})
 	}
 
 	if len(s.onceHandlers[t]) > 0 {
 		for _, eh := range s.onceHandlers[t] {
-			if s.SyncEvents {
-				eh.eventHandler.Handle(s, i)
-			} else {
-				go eh.eventHandler.Handle(s, i)
-			}
+			calls = append(calls, handlerCall{s, eh.eventHandler})
 		}
 		s.onceHandlers[t] = nil
 	}
+	return calls
 }
 
 // Handles an event type by calling internal methods, firing handlers and firing the
 // interface{} event.
 func (s *Session) handleEvent(t string, i interface{}) {
+	if s.BufferEvents > 0 && atomic.LoadInt32(&s.eventBuffer.done) == 0 && s.bufferEvent(t, i) {
+		return
+	}
+
 	s.handlersMu.RLock()
-	defer s.handlersMu.RUnlock()
 
 	// All events are dispatched internally first.
 	s.onInterface(i)
 
-	// Then they are dispatched to anyone handling interface{} events.
-	s.handle(interfaceEventType, i)
+	calls := s.dispatch(t, nil)
+	s.handlersMu.RUnlock()
+
+	for _, c := range calls {
+		c.s.callHandler(c.eh, i)
+	}
+}
+
+// eventBuffer holds events back from handlers until ReleaseEvents is called.
+type eventBuffer struct {
+	sync.Mutex
+	events []bufferedEvent
+
+	// Set atomically once buffering should end and once the buffered events
+	// were dispatched, respectively.
+	released int32
+	done     int32
+}
+
+type bufferedEvent struct {
+	t string
+	i interface{}
+}
+
+// bufferEvent processes an event internally and adds it to the event buffer.
+// It returns false if buffering has ended, in which case the event must be
+// dispatched as usual.
+func (s *Session) bufferEvent(t string, i interface{}) bool {
+	b := &s.eventBuffer
+	b.Lock()
+	defer b.Unlock()
+
+	if atomic.LoadInt32(&b.released) != 0 {
+		s.flushEvents()
+		return false
+	}
+
+	s.handlersMu.RLock()
+	s.onInterface(i)
+	s.handlersMu.RUnlock()
+
+	b.events = append(b.events, bufferedEvent{t, i})
+	if len(b.events) >= s.BufferEvents {
+		atomic.StoreInt32(&b.released, 1)
+		s.flushEvents()
+	}
+	return true
+}
+
+// ReleaseEvents dispatches the events buffered because of BufferEvents, in
+// the order they were received, to the handlers added by now and ends
+// buffering. Events received while the buffered events are dispatched are
+// dispatched after them.
+func (s *Session) ReleaseEvents() {
+	b := &s.eventBuffer
+	if !atomic.CompareAndSwapInt32(&b.released, 0, 1) {
+		return
+	}
+
+	b.Lock()
+	defer b.Unlock()
+	s.flushEvents()
+}
+
+// flushEvents dispatches the buffered events.
+// The caller must hold s.eventBuffer.
+func (s *Session) flushEvents() {
+	b := &s.eventBuffer
+	events := b.events
+	b.events = nil
+
+	for _, e := range events {
+		s.handlersMu.RLock()
+		calls := s.dispatch(e.t, nil)
+		s.handlersMu.RUnlock()
+
+		for _, c := range calls {
+			c.s.callHandler(c.eh, e.i)
+		}
+	}
+	atomic.StoreInt32(&b.done, 1)
+}
+
+// dispatch collects the handlers of s and of any of its clones for an event.
+// The caller must hold s.handlersMu.
+func (s *Session) dispatch(t string, calls []handlerCall) []handlerCall {
+	// Events are dispatched to anyone handling interface{} events.
+	calls = s.handle(interfaceEventType, calls)
+
+	// Then they are dispatched to any typed handlers.
+	calls = s.handle(t, calls)
 
-	// Finally they are dispatched to any typed handlers.
-	s.handle(t, i)
+	// Finally they are dispatched to the handlers of clones.
+	s.clonesMu.RLock()
+	clones := s.clones
+	s.clonesMu.RUnlock()
+
+	for _, c := range clones {
+		c.handlersMu.RLock()
+		calls = c.dispatch(t, calls)
+		c.handlersMu.RUnlock()
+	}
+	return calls
+}
+
+// callHandler calls an event handler according to SyncEvents and
+// MaxConcurrentHandlers.
+func (s *Session) callHandler(eh EventHandler, i interface{}) {
+	if s.SyncEvents {
+		eh.Handle(s, i)
+		return
+	}
+
+	if s.MaxConcurrentHandlers <= 0 {
+		go eh.Handle(s, i)
+		return
+	}
+
+	calls, stopped := s.handlerPool.start(s.MaxConcurrentHandlers, s.HandlerQueueSize)
+
+	call := func() { eh.Handle(s, i) }
+	if !s.DropEventsWhenQueueFull {
+		select {
+		case calls <- call:
+		case <-stopped:
+			s.log(LogWarning, "event handler pool stopped, dropping %s handler call", eh.Type())
+		}
+		return
+	}
+
+	select {
+	case calls <- call:
+	default:
+		atomic.AddUint64(&s.handlerPool.dropped, 1)
+		s.log(LogWarning, "event handler queue is full, dropping %s handler call", eh.Type())
+	}
+}
+
+// handlerPool runs event handlers on a fixed number of goroutines,
+// see Session.MaxConcurrentHandlers.
+type handlerPool struct {
+	sync.Mutex
+	calls   chan func()
+	stopped chan struct{}
+	dropped uint64
+}
+
+// start starts the goroutines of the pool if they aren't running, and
+// returns the channel to queue calls on and the channel closed by stop.
+func (p *handlerPool) start(workers, queueSize int) (calls chan<- func(), stopped <-chan struct{}) {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.calls == nil {
+		p.calls = make(chan func(), queueSize)
+		p.stopped = make(chan struct{})
+		for n := 0; n < workers; n++ {
+			go p.work(p.calls, p.stopped)
+		}
+	}
+	return p.calls, p.stopped
+}
+
+// stop stops the goroutines of the pool once they have run the calls
+// already queued. The pool is started again by the next handler call.
+func (p *handlerPool) stop() {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.calls != nil {
+		close(p.stopped)
+		p.calls = nil
+		p.stopped = nil
+	}
+}
+
+// work calls queued handlers until the pool is stopped. The calls channel
+// is never closed, so callers holding on to it can't panic sending on it.
+func (p *handlerPool) work(calls <-chan func(), stopped <-chan struct{}) {
+	for {
+		select {
+		case call := <-calls:
+			call()
+		case <-stopped:
+			for {
+				select {
+				case call := <-calls:
+					call()
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// DroppedHandlerCalls returns the number of event handler calls which were
+// dropped since the handler queue was full, see DropEventsWhenQueueFull.
+func (s *Session) DroppedHandlerCalls() uint64 {
+	return atomic.LoadUint64(&s.handlerPool.dropped)
 }
 
 // setGuildIds will set the GuildID on all the members of a guild.
@@ -233,15 +437,27 @@ func (s *Session) onInterface(i interface{}) {
 	case *VoiceStateUpdate:
 		go s.onVoiceStateUpdate(t)
 	}
-	err := s.State.OnInterface(s, i)
-	if err != nil {
+	if err := s.State.OnInterface(s, i); err != nil {
 		s.log(LogDebug, "error dispatching internal event, %s", err)
 	}
 }
 
+// stateStore returns the state backend used for lookups: Store when it is
+// set, otherwise the built-in State.
+func (s *Session) stateStore() StateStore {
+	if s.Store != nil {
+		return s.Store
+	}
+	if s.State == nil {
+		return nil
+	}
+	return s.State
+}
+
 // onReady handles the ready event.
 func (s *Session) onReady(r *Ready) {
 
-	// Store the SessionID within the Session struct.
+	// Store the SessionID and the gateway to resume it on within the Session struct.
 	s.sessionID = r.SessionID
+	s.resumeGatewayURL = r.ResumeGatewayURL
 }
@@ -26,6 +26,7 @@ const (
 	guildDeleteEventType                         = "GUILD_DELETE"
 	guildEmojisUpdateEventType                   = "GUILD_EMOJIS_UPDATE"
 	guildIntegrationsUpdateEventType             = "GUILD_INTEGRATIONS_UPDATE"
+	guildJoinEventType                           = "__GUILD_JOIN__"
 	guildMemberAddEventType                      = "GUILD_MEMBER_ADD"
 	guildMemberRemoveEventType                   = "GUILD_MEMBER_REMOVE"
 	guildMemberUpdateEventType                   = "GUILD_MEMBER_UPDATE"
@@ -38,7 +39,9 @@ const (
 	guildScheduledEventUpdateEventType           = "GUILD_SCHEDULED_EVENT_UPDATE"
 	guildScheduledEventUserAddEventType          = "GUILD_SCHEDULED_EVENT_USER_ADD"
 	guildScheduledEventUserRemoveEventType       = "GUILD_SCHEDULED_EVENT_USER_REMOVE"
+	guildUnavailableEventType                    = "__GUILD_UNAVAILABLE__"
 	guildUpdateEventType                         = "GUILD_UPDATE"
+	guildsLoadedEventType                        = "__GUILDS_LOADED__"
 	interactionCreateEventType                   = "INTERACTION_CREATE"
 	inviteCreateEventType                        = "INVITE_CREATE"
 	inviteDeleteEventType                        = "INVITE_DELETE"
@@ -435,6 +438,21 @@ func (eh guildIntegrationsUpdateEventHandler) Handle(s *Session, i interface{})
 	}
 }
 
+// guildJoinEventHandler is an event handler for GuildJoin events.
+type guildJoinEventHandler func(*Session, *GuildJoin)
+
+// Type returns the event type for GuildJoin events.
+func (eh guildJoinEventHandler) Type() string {
+	return guildJoinEventType
+}
+
+// Handle is the handler for GuildJoin events.
+func (eh guildJoinEventHandler) Handle(s *Session, i interface{}) {
+	if t, ok := i.(*GuildJoin); ok {
+		eh(s, t)
+	}
+}
+
 // guildMemberAddEventHandler is an event handler for GuildMemberAdd events.
 type guildMemberAddEventHandler func(*Session, *GuildMemberAdd)
 
@@ -675,6 +693,21 @@ func (eh guildScheduledEventUserRemoveEventHandler) Handle(s *Session, i interfa
 	}
 }
 
+// guildUnavailableEventHandler is an event handler for GuildUnavailable events.
+type guildUnavailableEventHandler func(*Session, *GuildUnavailable)
+
+// Type returns the event type for GuildUnavailable events.
+func (eh guildUnavailableEventHandler) Type() string {
+	return guildUnavailableEventType
+}
+
+// Handle is the handler for GuildUnavailable events.
+func (eh guildUnavailableEventHandler) Handle(s *Session, i interface{}) {
+	if t, ok := i.(*GuildUnavailable); ok {
+		eh(s, t)
+	}
+}
+
 // guildUpdateEventHandler is an event handler for GuildUpdate events.
 type guildUpdateEventHandler func(*Session, *GuildUpdate)
 
@@ -695,6 +728,21 @@ func (eh guildUpdateEventHandler) Handle(s *Session, i interface{}) {
 	}
 }
 
+// guildsLoadedEventHandler is an event handler for GuildsLoaded events.
+type guildsLoadedEventHandler func(*Session, *GuildsLoaded)
+
+// Type returns the event type for GuildsLoaded events.
+func (eh guildsLoadedEventHandler) Type() string {
+	return guildsLoadedEventType
+}
+
+// Handle is the handler for GuildsLoaded events.
+func (eh guildsLoadedEventHandler) Handle(s *Session, i interface{}) {
+	if t, ok := i.(*GuildsLoaded); ok {
+		eh(s, t)
+	}
+}
+
 // interactionCreateEventHandler is an event handler for InteractionCreate events.
 type interactionCreateEventHandler func(*Session, *InteractionCreate)
 
@@ -1312,6 +1360,8 @@ func handlerForInterface(handler interface{}) EventHandler {
 		return guildEmojisUpdateEventHandler(v)
 	case func(*Session, *GuildIntegrationsUpdate):
 		return guildIntegrationsUpdateEventHandler(v)
+	case func(*Session, *GuildJoin):
+		return guildJoinEventHandler(v)
 	case func(*Session, *GuildMemberAdd):
 		return guildMemberAddEventHandler(v)
 	case func(*Session, *GuildMemberRemove):
@@ -1336,8 +1386,12 @@ func handlerForInterface(handler interface{}) EventHandler {
 		return guildScheduledEventUserAddEventHandler(v)
 	case func(*Session, *GuildScheduledEventUserRemove):
 		return guildScheduledEventUserRemoveEventHandler(v)
+	case func(*Session, *GuildUnavailable):
+		return guildUnavailableEventHandler(v)
 	case func(*Session, *GuildUpdate):
 		return guildUpdateEventHandler(v)
+	case func(*Session, *GuildsLoaded):
+		return guildsLoadedEventHandler(v)
 	case func(*Session, *InteractionCreate):
 		return interactionCreateEventHandler(v)
 	case func(*Session, *InviteCreate):
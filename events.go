@@ -2,6 +2,7 @@ package discordgo
 
 import (
 	"encoding/json"
+	"time"
 )
 
 // This file contains all the possible structs that can be
@@ -11,11 +12,30 @@ import (
 
 // Connect is the data for a Connect event.
 // This is a synthetic event and is not dispatched by Discord.
-type Connect struct{}
+type Connect struct {
+	// The number of the attempt which succeeded, counting from 1 for
+	// each series of automatic reconnects.
+	Attempt int
+	// Whether the previous session was resumed rather than a new one identified.
+	Resumed bool
+	// The time from dialing the gateway to receiving READY or RESUMED.
+	Latency time.Duration
+}
 
 // Disconnect is the data for a Disconnect event.
 // This is a synthetic event and is not dispatched by Discord.
-type Disconnect struct{}
+type Disconnect struct {
+	// The close code and reason of the connection, if it was
+	// closed by Discord or failed. Both are empty for connections
+	// closed with Close or CloseWithCode. The reason is also set when
+	// Discord requests a reconnect, with an empty close code.
+	CloseCode   int
+	CloseReason string
+
+	// Whether the session will try to reconnect, which it does after
+	// failures if ShouldReconnectOnError is set, and in Reconnect.
+	Reconnect bool
+}
 
 // RateLimit is the data for a RateLimit event.
 // This is a synthetic event and is not dispatched by Discord.
@@ -36,13 +56,44 @@ type Event struct {
 
 // A Ready stores all data for the websocket READY event.
 type Ready struct {
-	Version         int          `json:"v"`
-	SessionID       string       `json:"session_id"`
-	User            *User        `json:"user"`
-	Shard           *[2]int      `json:"shard"`
-	Application     *Application `json:"application"`
-	Guilds          []*Guild     `json:"guilds"`
-	PrivateChannels []*Channel   `json:"private_channels"`
+	Version          int    `json:"v"`
+	SessionID        string `json:"session_id"`
+	ResumeGatewayURL string `json:"resume_gateway_url"`
+	// The current user.
+	User *User `json:"user"`
+	// The shard ID and shard count of the session, if it is sharded.
+	Shard *[2]int `json:"shard"`
+	// A partial application, only containing the ID and flags.
+	Application *Application `json:"application"`
+	// The guilds of the user, which are unavailable until
+	// their GuildCreate event is received.
+	Guilds          []*Guild   `json:"guilds"`
+	PrivateChannels []*Channel `json:"private_channels"`
+}
+
+// ApplicationID returns the ID of the application of the current user,
+// e.g. to register application commands right after connecting.
+func (r *Ready) ApplicationID() string {
+	if r.Application == nil {
+		return ""
+	}
+	return r.Application.ID
+}
+
+// ShardID returns the shard ID of the session, 0 if it is not sharded.
+func (r *Ready) ShardID() int {
+	if r.Shard == nil {
+		return 0
+	}
+	return r.Shard[0]
+}
+
+// ShardCount returns the number of shards, 1 if the session is not sharded.
+func (r *Ready) ShardCount() int {
+	if r.Shard == nil {
+		return 1
+	}
+	return r.Shard[1]
 }
 
 // ChannelCreate is the data for a ChannelCreate event.
@@ -79,6 +130,30 @@ type ThreadUpdate struct {
 	BeforeUpdate *Channel `json:"-"`
 }
 
+// Archived returns whether the thread is archived after the update.
+func (t *ThreadUpdate) Archived() bool {
+	return t.Channel != nil && t.ThreadMetadata != nil && t.ThreadMetadata.Archived
+}
+
+// Locked returns whether the thread is locked after the update.
+func (t *ThreadUpdate) Locked() bool {
+	return t.Channel != nil && t.ThreadMetadata != nil && t.ThreadMetadata.Locked
+}
+
+// BecameArchived returns whether the thread was archived by this update,
+// either manually or automatically after inactivity. It is always false when
+// the previous state of the thread is unknown, see BeforeUpdate.
+func (t *ThreadUpdate) BecameArchived() bool {
+	return t.BeforeUpdate != nil && !threadArchived(t.BeforeUpdate) && t.Archived()
+}
+
+// BecameUnarchived returns whether the thread was unarchived by this update.
+// It is always false when the previous state of the thread is unknown, see
+// BeforeUpdate.
+func (t *ThreadUpdate) BecameUnarchived() bool {
+	return t.BeforeUpdate != nil && threadArchived(t.BeforeUpdate) && !t.Archived()
+}
+
 // ThreadDelete is the data for a ThreadDelete event.
 type ThreadDelete struct {
 	*Channel
@@ -130,6 +205,37 @@ type GuildDelete struct {
 	BeforeDelete *Guild `json:"-"`
 }
 
+// GuildUnavailable is the data for a GuildUnavailable event, emitted after
+// the GuildDelete event of a guild which became unavailable because of an
+// outage, rather than the current user leaving it. The state keeps the guild,
+// with Unavailable set, until it is available again and a GuildCreate is received.
+// This is a synthetic event and is not dispatched by Discord.
+type GuildUnavailable struct {
+	GuildID string
+}
+
+// GuildJoin is the data for a GuildJoin event, emitted after the GuildCreate
+// event of a guild the current user joined. It is not emitted for the
+// GuildCreate events of guilds which become available after connecting or
+// after an outage, so it can be used for side effects such as greetings.
+// This is a synthetic event and is not dispatched by Discord.
+type GuildJoin struct {
+	*Guild
+}
+
+// GuildsLoaded is the data for a GuildsLoaded event, emitted once the
+// GuildCreate events of all guilds listed in READY have been received and
+// processed, which is when the State is fully populated after connecting.
+// Guilds which are unavailable due to an outage delay this event until they
+// become available.
+// This is a synthetic event and is not dispatched by Discord.
+type GuildsLoaded struct {
+	// The number of guilds loaded
+	GuildCount int
+	// The time between the READY event and the last GuildCreate event
+	Duration time.Duration
+}
+
 // GuildBanAdd is the data for a GuildBanAdd event.
 type GuildBanAdd struct {
 	User    *User  `json:"user"`
@@ -251,17 +357,43 @@ func (m *MessageCreate) UnmarshalJSON(b []byte) error {
 }
 
 // MessageUpdate is the data for a MessageUpdate event.
+// The message may be partial, e.g. when Discord only adds embeds to a
+// message it only sends the ID, channel and embeds. Fields which were not
+// sent are zero valued, use Has to tell them apart from empty fields.
 type MessageUpdate struct {
 	*Message
 	// BeforeUpdate will be nil if the Message was not previously cached in the state cache.
 	BeforeUpdate *Message `json:"-"`
+
+	// The JSON fields present in the event, nil if it was not unmarshaled.
+	fields map[string]json.RawMessage
 }
 
 // UnmarshalJSON is a helper function to unmarshal MessageUpdate object.
 func (m *MessageUpdate) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, &m.fields); err != nil {
+		return err
+	}
 	return json.Unmarshal(b, &m.Message)
 }
 
+// Has returns whether the update contains the message field with the given
+// JSON name, e.g. "content" or "embeds". All fields are reported as present
+// for a MessageUpdate which was not unmarshaled from an event.
+func (m *MessageUpdate) Has(field string) bool {
+	if m.fields == nil {
+		return true
+	}
+	_, ok := m.fields[field]
+	return ok
+}
+
+// IsPartial returns whether the update only contains some fields of the
+// message, see Has.
+func (m *MessageUpdate) IsPartial() bool {
+	return !m.Has("timestamp") || !m.Has("author")
+}
+
 // MessageDelete is the data for a MessageDelete event.
 type MessageDelete struct {
 	*Message
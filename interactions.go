@@ -5,17 +5,25 @@ import (
 	"crypto/ed25519"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"regexp"
 	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 // InteractionDeadline is the time allowed to respond to an interaction.
 const InteractionDeadline = time.Second * 3
 
+// InteractionTokenLifetime is the time for which the token of an interaction
+// can be used to send followup messages and edit responses.
+const InteractionTokenLifetime = time.Minute * 15
+
 // ApplicationCommandType represents the type of application command.
 type ApplicationCommandType uint8
 
@@ -49,8 +57,27 @@ type ApplicationCommand struct {
 	Description              string                      `json:"description,omitempty"`
 	DescriptionLocalizations *map[Locale]string          `json:"description_localizations,omitempty"`
 	Options                  []*ApplicationCommandOption `json:"options"`
+
+	// Installation contexts where the command is available. Defaults to guild installs only.
+	IntegrationTypes []ApplicationIntegrationType `json:"integration_types,omitempty"`
+	// Interaction contexts where the command can be used. Defaults to all contexts.
+	Contexts []InteractionContextType `json:"contexts,omitempty"`
 }
 
+// InteractionContextType is the context in which an interaction can be used or was triggered from.
+type InteractionContextType int
+
+// Interaction context types.
+const (
+	// InteractionContextGuild is a guild channel.
+	InteractionContextGuild InteractionContextType = 0
+	// InteractionContextBotDM is a DM with the bot.
+	InteractionContextBotDM InteractionContextType = 1
+	// InteractionContextPrivateChannel is a group DM or a DM other than the one with the bot,
+	// only available to user-installed applications.
+	InteractionContextPrivateChannel InteractionContextType = 2
+)
+
 // ApplicationCommandOptionType indicates the type of a slash command's option.
 type ApplicationCommandOptionType uint8
 
@@ -132,6 +159,243 @@ type ApplicationCommandOptionChoice struct {
 	Value             interface{}       `json:"value"`
 }
 
+// StringChoice returns a choice for an option of type ApplicationCommandOptionString.
+func StringChoice(name, value string) *ApplicationCommandOptionChoice {
+	return &ApplicationCommandOptionChoice{Name: name, Value: value}
+}
+
+// IntChoice returns a choice for an option of type ApplicationCommandOptionInteger.
+func IntChoice(name string, value int64) *ApplicationCommandOptionChoice {
+	return &ApplicationCommandOptionChoice{Name: name, Value: value}
+}
+
+// FloatChoice returns a choice for an option of type ApplicationCommandOptionNumber.
+func FloatChoice(name string, value float64) *ApplicationCommandOptionChoice {
+	return &ApplicationCommandOptionChoice{Name: name, Value: value}
+}
+
+// validChoiceValue returns whether a choice value has the type required by
+// options of type t. Integer options accept whole floats, as choices of
+// commands unmarshaled from JSON have float values.
+func validChoiceValue(t ApplicationCommandOptionType, value interface{}) bool {
+	switch t {
+	case ApplicationCommandOptionString:
+		_, ok := value.(string)
+		return ok
+	case ApplicationCommandOptionInteger:
+		switch v := value.(type) {
+		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+			return true
+		case float32:
+			return float32(int64(v)) == v
+		case float64:
+			return float64(int64(v)) == v
+		}
+	case ApplicationCommandOptionNumber:
+		switch value.(type) {
+		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+			return true
+		}
+	}
+	return false
+}
+
+// Limits on application commands enforced by Discord.
+const (
+	ApplicationCommandMaxOptions           = 25
+	ApplicationCommandMaxChoices           = 25
+	ApplicationCommandMaxNameLength        = 32
+	ApplicationCommandMaxDescriptionLength = 100
+)
+
+// ErrInvalidApplicationCommand is wrapped by the errors returned by
+// ApplicationCommand.Validate.
+var ErrInvalidApplicationCommand = errors.New("invalid application command")
+
+// applicationCommandNamePattern matches valid names of chat input commands and options.
+var applicationCommandNamePattern = regexp.MustCompile(`^[-_'\p{L}\p{N}\p{Devanagari}\p{Thai}]{1,32}$`)
+
+// Validate checks the command against the limits Discord enforces on
+// registration: the number of options and choices, the nesting of
+// subcommands and subcommand groups, and the format of names and descriptions.
+// The returned error wraps ErrInvalidApplicationCommand and names the
+// offending option, so mistakes can be found before making a request.
+// ApplicationCommandCreate and ApplicationCommandBulkOverwrite validate
+// commands before sending them.
+func (c *ApplicationCommand) Validate() error {
+	if c == nil {
+		return fmt.Errorf("%w: nil command", ErrInvalidApplicationCommand)
+	}
+	if c.Type != 0 && c.Type != ChatApplicationCommand {
+		// Context menu command names may contain spaces and upper case letters.
+		if n := utf8.RuneCountInString(c.Name); n < 1 || n > ApplicationCommandMaxNameLength {
+			return applicationCommandError(c.Name, "name must be between 1 and %d characters", ApplicationCommandMaxNameLength)
+		}
+		if c.Description != "" || len(c.Options) > 0 {
+			return applicationCommandError(c.Name, "%s commands cannot have a description or options", applicationCommandTypeName(c.Type))
+		}
+		return nil
+	}
+
+	if err := validateApplicationCommandName(c.Name, c.Name, c.Description); err != nil {
+		return err
+	}
+	return validateApplicationCommandOptions(c.Name, c.Options, 0)
+}
+
+// validateEdit checks an ApplicationCommandEdit body like Validate, but only
+// the name, description and options which are set, since an edit only
+// changes the fields it includes.
+func (c *ApplicationCommand) validateEdit() error {
+	if c.Type != 0 && c.Type != ChatApplicationCommand {
+		if n := utf8.RuneCountInString(c.Name); n > ApplicationCommandMaxNameLength {
+			return applicationCommandError(c.Name, "name must be between 1 and %d characters", ApplicationCommandMaxNameLength)
+		}
+		if c.Description != "" || len(c.Options) > 0 {
+			return applicationCommandError(c.Name, "%s commands cannot have a description or options", applicationCommandTypeName(c.Type))
+		}
+		return nil
+	}
+
+	// Without a type the command may be a context menu command, whose names
+	// are not restricted to the chat input format.
+	if c.Name != "" {
+		if c.Type == ChatApplicationCommand {
+			if !applicationCommandNamePattern.MatchString(c.Name) {
+				return applicationCommandError(c.Name, "name %q must be 1 to %d letters, numbers, dashes, underscores or apostrophes", c.Name, ApplicationCommandMaxNameLength)
+			}
+			if strings.ToLower(c.Name) != c.Name {
+				return applicationCommandError(c.Name, "name %q must be lowercase", c.Name)
+			}
+		} else if utf8.RuneCountInString(c.Name) > ApplicationCommandMaxNameLength {
+			return applicationCommandError(c.Name, "name must be between 1 and %d characters", ApplicationCommandMaxNameLength)
+		}
+	}
+	if utf8.RuneCountInString(c.Description) > ApplicationCommandMaxDescriptionLength {
+		return applicationCommandError(c.Name, "description must be between 1 and %d characters", ApplicationCommandMaxDescriptionLength)
+	}
+	if c.Options != nil {
+		return validateApplicationCommandOptions(c.Name, c.Options, 0)
+	}
+	return nil
+}
+
+func applicationCommandTypeName(t ApplicationCommandType) string {
+	switch t {
+	case UserApplicationCommand:
+		return "user"
+	case MessageApplicationCommand:
+		return "message"
+	}
+	return fmt.Sprintf("type %d", t)
+}
+
+func applicationCommandError(path, format string, args ...interface{}) error {
+	return fmt.Errorf("%w %q: %s", ErrInvalidApplicationCommand, path, fmt.Sprintf(format, args...))
+}
+
+func validateApplicationCommandName(path, name, description string) error {
+	if !applicationCommandNamePattern.MatchString(name) {
+		return applicationCommandError(path, "name %q must be 1 to %d letters, numbers, dashes, underscores or apostrophes", name, ApplicationCommandMaxNameLength)
+	}
+	if strings.ToLower(name) != name {
+		return applicationCommandError(path, "name %q must be lowercase", name)
+	}
+	if n := utf8.RuneCountInString(description); n < 1 || n > ApplicationCommandMaxDescriptionLength {
+		return applicationCommandError(path, "description must be between 1 and %d characters", ApplicationCommandMaxDescriptionLength)
+	}
+	return nil
+}
+
+// validateApplicationCommandOptions validates the options of a command (depth 0),
+// subcommand group (depth 1) or subcommand (depth 1 or 2) at path.
+func validateApplicationCommandOptions(path string, options []*ApplicationCommandOption, depth int) error {
+	if len(options) > ApplicationCommandMaxOptions {
+		return applicationCommandError(path, "has %d options, the maximum is %d", len(options), ApplicationCommandMaxOptions)
+	}
+
+	names := make(map[string]bool, len(options))
+	var subcommands, values, optional int
+	for _, o := range options {
+		if o == nil {
+			return applicationCommandError(path, "has a nil option")
+		}
+
+		optionPath := path + " " + o.Name
+		if err := validateApplicationCommandName(optionPath, o.Name, o.Description); err != nil {
+			return err
+		}
+		if names[o.Name] {
+			return applicationCommandError(path, "has more than one option named %q", o.Name)
+		}
+		names[o.Name] = true
+
+		switch o.Type {
+		case ApplicationCommandOptionSubCommandGroup:
+			if depth > 0 {
+				return applicationCommandError(optionPath, "subcommand groups can only be used at the top level")
+			}
+			subcommands++
+			for _, sub := range o.Options {
+				if sub != nil && sub.Type != ApplicationCommandOptionSubCommand {
+					return applicationCommandError(optionPath+" "+sub.Name, "subcommand groups can only contain subcommands")
+				}
+			}
+			if err := validateApplicationCommandOptions(optionPath, o.Options, depth+1); err != nil {
+				return err
+			}
+		case ApplicationCommandOptionSubCommand:
+			if depth > 1 {
+				return applicationCommandError(optionPath, "subcommands cannot be nested more than two levels deep")
+			}
+			subcommands++
+			for _, sub := range o.Options {
+				if sub != nil && (sub.Type == ApplicationCommandOptionSubCommand || sub.Type == ApplicationCommandOptionSubCommandGroup) {
+					return applicationCommandError(optionPath+" "+sub.Name, "subcommands cannot contain subcommands or subcommand groups")
+				}
+			}
+			if err := validateApplicationCommandOptions(optionPath, o.Options, 2); err != nil {
+				return err
+			}
+		default:
+			values++
+			if len(o.Options) > 0 {
+				return applicationCommandError(optionPath, "%s options cannot have options", o.Type)
+			}
+			if len(o.Choices) > ApplicationCommandMaxChoices {
+				return applicationCommandError(optionPath, "has %d choices, the maximum is %d", len(o.Choices), ApplicationCommandMaxChoices)
+			}
+			if o.Autocomplete && len(o.Choices) > 0 {
+				return applicationCommandError(optionPath, "autocomplete cannot be used with choices")
+			}
+			if len(o.Choices) > 0 && o.Type != ApplicationCommandOptionString && o.Type != ApplicationCommandOptionInteger && o.Type != ApplicationCommandOptionNumber {
+				return applicationCommandError(optionPath, "%s options cannot have choices", o.Type)
+			}
+			for _, choice := range o.Choices {
+				if choice == nil {
+					return applicationCommandError(optionPath, "has a nil choice")
+				}
+				if n := utf8.RuneCountInString(choice.Name); n < 1 || n > ApplicationCommandMaxDescriptionLength {
+					return applicationCommandError(optionPath, "choice name %q must be between 1 and %d characters", choice.Name, ApplicationCommandMaxDescriptionLength)
+				}
+				if !validChoiceValue(o.Type, choice.Value) {
+					return applicationCommandError(optionPath, "choice %q has a value of type %T, which is invalid for %s options", choice.Name, choice.Value, o.Type)
+				}
+			}
+			if !o.Required {
+				optional++
+			} else if optional > 0 {
+				return applicationCommandError(optionPath, "required options must be listed before optional options")
+			}
+		}
+	}
+
+	if subcommands > 0 && values > 0 {
+		return applicationCommandError(path, "cannot mix subcommands or subcommand groups with other options")
+	}
+	return nil
+}
+
 // ApplicationCommandPermissions represents a single user or role permission for a command.
 type ApplicationCommandPermissions struct {
 	ID         string                           `json:"id"`
@@ -233,6 +497,14 @@ type Interaction struct {
 	// NOTE: this field is only filled when the interaction was invoked in a guild.
 	GuildLocale *Locale `json:"guild_locale"`
 
+	// The installations of the application which authorized the interaction, mapped to
+	// the ID of the guild when installed to a guild or of the user when installed to a user.
+	// For example, a command of a user-installed application only has an
+	// ApplicationIntegrationUserInstall entry, unless it is also installed to the guild.
+	AuthorizingIntegrationOwners map[ApplicationIntegrationType]string `json:"authorizing_integration_owners"`
+	// The context the interaction was triggered from.
+	Context InteractionContextType `json:"context"`
+
 	Token   string `json:"token"`
 	Version int    `json:"version"`
 }
@@ -254,6 +526,11 @@ func (i *Interaction) UnmarshalJSON(raw []byte) error {
 
 	*i = Interaction(tmp.interaction)
 
+	// The member does not include the guild ID, which AvatarURL relies on.
+	if i.Member != nil && i.Member.GuildID == "" {
+		i.Member.GuildID = i.GuildID
+	}
+
 	switch tmp.Type {
 	case InteractionApplicationCommand, InteractionApplicationCommandAutocomplete:
 		v := ApplicationCommandInteractionData{}
@@ -280,6 +557,23 @@ func (i *Interaction) UnmarshalJSON(raw []byte) error {
 	return nil
 }
 
+// TokenExpiry returns the time at which the token of the interaction expires,
+// computed from the creation time of the interaction. Requests made with an
+// expired token, such as followup messages, fail with 404 Not Found.
+func (i Interaction) TokenExpiry() time.Time {
+	t, err := SnowflakeTimestamp(i.ID)
+	if err != nil {
+		return time.Time{}
+	}
+	return t.Add(InteractionTokenLifetime)
+}
+
+// IsExpired returns whether the token of the interaction has expired,
+// see TokenExpiry. It returns true if the interaction ID is invalid.
+func (i Interaction) IsExpired() bool {
+	return !time.Now().Before(i.TokenExpiry())
+}
+
 // MessageComponentData is helper function to assert the inner InteractionData to MessageComponentInteractionData.
 // Make sure to check that the Type of the interaction is InteractionMessageComponent before calling.
 func (i Interaction) MessageComponentData() (data MessageComponentInteractionData) {
@@ -343,6 +637,36 @@ func (ApplicationCommandInteractionData) Type() InteractionType {
 	return InteractionApplicationCommand
 }
 
+// AttachmentOption returns the resolved attachment of the attachment option
+// with the given name, looking into the options of the invoked subcommand.
+// It returns nil if there is no such option or attachment.
+func (d ApplicationCommandInteractionData) AttachmentOption(name string) *MessageAttachment {
+	o := findInteractionDataOption(d.Options, name)
+	if o == nil || o.Type != ApplicationCommandOptionAttachment || d.Resolved == nil {
+		return nil
+	}
+	id, _ := o.Value.(string)
+	return d.Resolved.Attachments[id]
+}
+
+// findInteractionDataOption returns the option with the given name, looking
+// into subcommands and subcommand groups.
+func findInteractionDataOption(options []*ApplicationCommandInteractionDataOption, name string) *ApplicationCommandInteractionDataOption {
+	for _, o := range options {
+		switch o.Type {
+		case ApplicationCommandOptionSubCommand, ApplicationCommandOptionSubCommandGroup:
+			if found := findInteractionDataOption(o.Options, name); found != nil {
+				return found
+			}
+		default:
+			if o.Name == name {
+				return o
+			}
+		}
+	}
+	return nil
+}
+
 // MessageComponentInteractionData contains the data of message component interaction.
 type MessageComponentInteractionData struct {
 	CustomID      string                                  `json:"custom_id"`
@@ -396,6 +720,67 @@ func (d *ModalSubmitInteractionData) UnmarshalJSON(data []byte) error {
 	return err
 }
 
+// Values returns the values of all text inputs in the modal, keyed by custom ID.
+// Nested components, such as action rows, are flattened.
+func (d ModalSubmitInteractionData) Values() map[string]string {
+	values := make(map[string]string)
+	modalComponentValues(d.Components, values)
+	return values
+}
+
+// Value returns the value of the text input with the given custom ID,
+// and whether such an input was submitted.
+func (d ModalSubmitInteractionData) Value(customID string) (string, bool) {
+	v, ok := d.Values()[customID]
+	return v, ok
+}
+
+// modalComponentValues collects the values of text inputs in components into values.
+func modalComponentValues(components []MessageComponent, values map[string]string) {
+	for _, c := range components {
+		switch c := c.(type) {
+		case *TextInput:
+			values[c.CustomID] = c.Value
+		case TextInput:
+			values[c.CustomID] = c.Value
+		case *ActionsRow:
+			modalComponentValues(c.Components, values)
+		case ActionsRow:
+			modalComponentValues(c.Components, values)
+		case *Section:
+			modalComponentValues(c.Components, values)
+		case *Container:
+			modalComponentValues(c.Components, values)
+		case UnknownComponent:
+			modalUnknownComponentValues(c, values)
+		}
+	}
+}
+
+// modalUnknownComponentValues collects values from component types which are
+// not supported yet, looking for a custom ID and a value, or for nested components.
+func modalUnknownComponentValues(c UnknownComponent, values map[string]string) {
+	var v struct {
+		CustomID   string                          `json:"custom_id"`
+		Value      *string                         `json:"value"`
+		Component  *unmarshalableMessageComponent  `json:"component"`
+		Components []unmarshalableMessageComponent `json:"components"`
+	}
+	if err := json.Unmarshal(c.Data, &v); err != nil {
+		return
+	}
+
+	if v.CustomID != "" && v.Value != nil {
+		values[v.CustomID] = *v.Value
+	}
+	if v.Component != nil {
+		modalComponentValues([]MessageComponent{v.Component.MessageComponent}, values)
+	}
+	for _, sub := range v.Components {
+		modalComponentValues([]MessageComponent{sub.MessageComponent}, values)
+	}
+}
+
 // ApplicationCommandInteractionDataOption represents an option of a slash command.
 type ApplicationCommandInteractionDataOption struct {
 	Name string                       `json:"name"`
@@ -460,14 +845,17 @@ func (o ApplicationCommandInteractionDataOption) ChannelValue(s *Session) *Chann
 		return &Channel{ID: chanID}
 	}
 
-	ch, err := s.State.Channel(chanID)
-	if err != nil {
-		ch, err = s.Channel(chanID)
-		if err != nil {
-			return &Channel{ID: chanID}
+	if store := s.stateStore(); store != nil {
+		if ch, err := store.Channel(chanID); err == nil {
+			return ch
 		}
 	}
 
+	ch, err := s.Channel(chanID)
+	if err != nil {
+		return &Channel{ID: chanID}
+	}
+
 	return ch
 }
 
@@ -483,20 +871,21 @@ func (o ApplicationCommandInteractionDataOption) RoleValue(s *Session, gID strin
 		return &Role{ID: roleID}
 	}
 
-	r, err := s.State.Role(gID, roleID)
-	if err != nil {
-		roles, err := s.GuildRoles(gID)
-		if err == nil {
-			for _, r = range roles {
-				if r.ID == roleID {
-					return r
-				}
-			}
+	if store := s.stateStore(); store != nil {
+		if r, err := store.Role(gID, roleID); err == nil {
+			return r
 		}
-		return &Role{ID: roleID}
 	}
 
-	return r
+	roles, err := s.GuildRoles(gID)
+	if err == nil {
+		for _, r := range roles {
+			if r.ID == roleID {
+				return r
+			}
+		}
+	}
+	return &Role{ID: roleID}
 }
 
 // UserValue is a utility function for casting option value to user object.
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"crypto/ed25519"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"net/http/httptest"
 	"strconv"
 	"strings"
@@ -66,3 +68,219 @@ func TestVerifyInteraction(t *testing.T) {
 		}
 	})
 }
+
+func TestModalSubmitValues(t *testing.T) {
+	data := `{
+		"custom_id": "modal",
+		"components": [
+			{"type": 1, "components": [{"type": 4, "custom_id": "name", "value": "Gopher"}]},
+			{"type": 1, "components": [{"type": 4, "custom_id": "bio", "value": ""}]},
+			{"type": 18, "label": "Age", "component": {"type": 4, "custom_id": "age", "value": "13"}}
+		]
+	}`
+
+	var d ModalSubmitInteractionData
+	if err := json.Unmarshal([]byte(data), &d); err != nil {
+		t.Fatalf("error unmarshalling modal submit data: %s", err)
+	}
+
+	values := d.Values()
+	expected := map[string]string{"name": "Gopher", "bio": "", "age": "13"}
+	if len(values) != len(expected) {
+		t.Errorf("expected %d values, got %d: %v", len(expected), len(values), values)
+	}
+	for id, want := range expected {
+		if got, ok := d.Value(id); !ok || got != want {
+			t.Errorf("Value(%q) = %q, %v; expected %q, true", id, got, ok, want)
+		}
+	}
+
+	if _, ok := d.Value("missing"); ok {
+		t.Error("Value returned ok for a missing custom ID")
+	}
+}
+
+func TestMessageComponentFromJSONUnknown(t *testing.T) {
+	if c, err := MessageComponentFromJSON([]byte(`{"type":99}`)); err == nil {
+		t.Errorf("MessageComponentFromJSON() = %v, want an error for an unknown type", c)
+	}
+
+	var m Message
+	if err := json.Unmarshal([]byte(`{"components":[{"type":99,"custom_id":"future"}]}`), &m); err != nil {
+		t.Fatalf("error unmarshalling message with an unknown component: %s", err)
+	}
+	if len(m.Components) != 1 {
+		t.Fatalf("got %d components, want 1", len(m.Components))
+	}
+	if c, ok := m.Components[0].(UnknownComponent); !ok || c.Type() != 99 {
+		t.Errorf("got component %#v, want an UnknownComponent of type 99", m.Components[0])
+	}
+}
+
+func TestAttachmentOption(t *testing.T) {
+	data := `{
+		"name": "upload",
+		"options": [{"type": 1, "name": "image", "options": [
+			{"type": 11, "name": "file", "value": "123"},
+			{"type": 3, "name": "caption", "value": "hello"}
+		]}],
+		"resolved": {"attachments": {"123": {"id": "123", "filename": "cat.png"}}}
+	}`
+
+	var d ApplicationCommandInteractionData
+	if err := json.Unmarshal([]byte(data), &d); err != nil {
+		t.Fatalf("error unmarshalling command data: %s", err)
+	}
+
+	if a := d.AttachmentOption("file"); a == nil || a.Filename != "cat.png" {
+		t.Errorf("AttachmentOption(\"file\") = %+v, want the resolved attachment", a)
+	}
+	if a := d.AttachmentOption("caption"); a != nil {
+		t.Errorf("AttachmentOption of a string option = %+v, want nil", a)
+	}
+	if a := d.AttachmentOption("missing"); a != nil {
+		t.Errorf("AttachmentOption of a missing option = %+v, want nil", a)
+	}
+}
+
+func TestInteractionIntegrationContext(t *testing.T) {
+	var i Interaction
+	err := json.Unmarshal([]byte(`{
+		"type": 2,
+		"data": {"id": "1", "name": "ping"},
+		"authorizing_integration_owners": {"1": "user"},
+		"context": 2
+	}`), &i)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if i.Context != InteractionContextPrivateChannel {
+		t.Errorf("got context %d, want %d", i.Context, InteractionContextPrivateChannel)
+	}
+	if owner := i.AuthorizingIntegrationOwners[ApplicationIntegrationUserInstall]; owner != "user" {
+		t.Errorf("got user install owner %q, want %q", owner, "user")
+	}
+	if _, ok := i.AuthorizingIntegrationOwners[ApplicationIntegrationGuildInstall]; ok {
+		t.Error("unexpected guild install owner")
+	}
+
+	cmd, err := json.Marshal(&ApplicationCommand{
+		Name:             "ping",
+		IntegrationTypes: []ApplicationIntegrationType{ApplicationIntegrationGuildInstall, ApplicationIntegrationUserInstall},
+		Contexts:         []InteractionContextType{InteractionContextGuild, InteractionContextBotDM, InteractionContextPrivateChannel},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		IntegrationTypes []int `json:"integration_types"`
+		Contexts         []int `json:"contexts"`
+	}
+	if err := json.Unmarshal(cmd, &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.IntegrationTypes) != 2 || len(got.Contexts) != 3 {
+		t.Errorf("got command %s, want integration types and contexts", cmd)
+	}
+}
+
+func TestApplicationCommandValidate(t *testing.T) {
+	opt := func(typ ApplicationCommandOptionType, name string, options ...*ApplicationCommandOption) *ApplicationCommandOption {
+		return &ApplicationCommandOption{Type: typ, Name: name, Description: "description", Options: options}
+	}
+	choices := func(typ ApplicationCommandOptionType, choices ...*ApplicationCommandOptionChoice) *ApplicationCommandOption {
+		o := opt(typ, "value")
+		o.Choices = choices
+		return o
+	}
+	manyOptions := make([]*ApplicationCommandOption, ApplicationCommandMaxOptions+1)
+	for i := range manyOptions {
+		manyOptions[i] = opt(ApplicationCommandOptionString, "option-"+strconv.Itoa(i))
+	}
+
+	tests := []struct {
+		name  string
+		cmd   ApplicationCommand
+		valid bool
+	}{
+		{"valid", ApplicationCommand{Name: "ping", Description: "Ping"}, true},
+		{"nested", ApplicationCommand{Name: "settings", Description: "Settings", Options: []*ApplicationCommandOption{
+			opt(ApplicationCommandOptionSubCommandGroup, "audio", opt(ApplicationCommandOptionSubCommand, "volume", opt(ApplicationCommandOptionInteger, "level"))),
+			opt(ApplicationCommandOptionSubCommand, "reset"),
+		}}, true},
+		{"context menu", ApplicationCommand{Type: UserApplicationCommand, Name: "View Profile"}, true},
+		{"apostrophe", ApplicationCommand{Name: "don't", Description: "Don't"}, true},
+		{"upper case name", ApplicationCommand{Name: "Ping", Description: "Ping"}, false},
+		{"long name", ApplicationCommand{Name: strings.Repeat("a", 33), Description: "Ping"}, false},
+		{"no description", ApplicationCommand{Name: "ping"}, false},
+		{"long description", ApplicationCommand{Name: "ping", Description: strings.Repeat("a", 101)}, false},
+		{"too many options", ApplicationCommand{Name: "ping", Description: "Ping", Options: manyOptions}, false},
+		{"mixed subcommands", ApplicationCommand{Name: "ping", Description: "Ping", Options: []*ApplicationCommandOption{
+			opt(ApplicationCommandOptionSubCommand, "sub"),
+			opt(ApplicationCommandOptionString, "value"),
+		}}, false},
+		{"group in subcommand", ApplicationCommand{Name: "ping", Description: "Ping", Options: []*ApplicationCommandOption{
+			opt(ApplicationCommandOptionSubCommand, "sub", opt(ApplicationCommandOptionSubCommandGroup, "group")),
+		}}, false},
+		{"nested group", ApplicationCommand{Name: "ping", Description: "Ping", Options: []*ApplicationCommandOption{
+			opt(ApplicationCommandOptionSubCommandGroup, "group", opt(ApplicationCommandOptionSubCommandGroup, "group")),
+		}}, false},
+		{"duplicate option", ApplicationCommand{Name: "ping", Description: "Ping", Options: []*ApplicationCommandOption{
+			opt(ApplicationCommandOptionString, "value"),
+			opt(ApplicationCommandOptionString, "value"),
+		}}, false},
+		{"context menu description", ApplicationCommand{Type: MessageApplicationCommand, Name: "Quote", Description: "Quote"}, false},
+		{"typed choices", ApplicationCommand{Name: "ping", Description: "Ping", Options: []*ApplicationCommandOption{
+			choices(ApplicationCommandOptionString, StringChoice("a", "a")),
+		}}, true},
+		{"integer choices", ApplicationCommand{Name: "ping", Description: "Ping", Options: []*ApplicationCommandOption{
+			choices(ApplicationCommandOptionInteger, IntChoice("one", 1), &ApplicationCommandOptionChoice{Name: "two", Value: float64(2)}),
+		}}, true},
+		{"number choices", ApplicationCommand{Name: "ping", Description: "Ping", Options: []*ApplicationCommandOption{
+			choices(ApplicationCommandOptionNumber, FloatChoice("half", 0.5), IntChoice("one", 1)),
+		}}, true},
+		{"string choice for integer", ApplicationCommand{Name: "ping", Description: "Ping", Options: []*ApplicationCommandOption{
+			choices(ApplicationCommandOptionInteger, StringChoice("one", "1")),
+		}}, false},
+		{"fractional choice for integer", ApplicationCommand{Name: "ping", Description: "Ping", Options: []*ApplicationCommandOption{
+			choices(ApplicationCommandOptionInteger, FloatChoice("half", 0.5)),
+		}}, false},
+		{"choices for boolean", ApplicationCommand{Name: "ping", Description: "Ping", Options: []*ApplicationCommandOption{
+			choices(ApplicationCommandOptionBoolean, &ApplicationCommandOptionChoice{Name: "yes", Value: true}),
+		}}, false},
+	}
+
+	for _, tt := range tests {
+		err := tt.cmd.Validate()
+		if tt.valid && err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		} else if !tt.valid && !errors.Is(err, ErrInvalidApplicationCommand) {
+			t.Errorf("%s: got error %v, want ErrInvalidApplicationCommand", tt.name, err)
+		}
+	}
+}
+
+func TestInteractionTokenExpiry(t *testing.T) {
+	snowflake := func(t time.Time) string {
+		return strconv.FormatInt((t.UnixNano()/int64(time.Millisecond)-1420070400000)<<22, 10)
+	}
+
+	created := time.Now().Add(-time.Minute).Truncate(time.Millisecond)
+	i := Interaction{ID: snowflake(created)}
+	if want := created.Add(InteractionTokenLifetime); !i.TokenExpiry().Equal(want) {
+		t.Errorf("TokenExpiry() = %v, want %v", i.TokenExpiry(), want)
+	}
+	if i.IsExpired() {
+		t.Error("IsExpired() = true for a recent interaction")
+	}
+
+	old := InteractionCreate{Interaction: &Interaction{ID: snowflake(time.Now().Add(-InteractionTokenLifetime - time.Second))}}
+	if !old.IsExpired() {
+		t.Error("IsExpired() = false for an old interaction")
+	}
+
+	if !(Interaction{ID: "invalid"}).IsExpired() {
+		t.Error("IsExpired() = false for an invalid ID")
+	}
+}
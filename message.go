@@ -10,9 +10,16 @@
 package discordgo
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -38,13 +45,59 @@ const (
 	MessageTypeChannelFollowAdd                      MessageType = 12
 	MessageTypeGuildDiscoveryDisqualified            MessageType = 14
 	MessageTypeGuildDiscoveryRequalified             MessageType = 15
+	MessageTypeGuildDiscoveryGracePeriodInitial      MessageType = 16
+	MessageTypeGuildDiscoveryGracePeriodFinal        MessageType = 17
 	MessageTypeThreadCreated                         MessageType = 18
 	MessageTypeReply                                 MessageType = 19
 	MessageTypeChatInputCommand                      MessageType = 20
 	MessageTypeThreadStarterMessage                  MessageType = 21
+	MessageTypeGuildInviteReminder                   MessageType = 22
 	MessageTypeContextMenuCommand                    MessageType = 23
+	MessageTypeAutoModerationAction                  MessageType = 24
+	MessageTypeRoleSubscriptionPurchase              MessageType = 25
+	MessageTypeInteractionPremiumUpsell              MessageType = 26
+	MessageTypeStageStart                            MessageType = 27
+	MessageTypeStageEnd                              MessageType = 28
+	MessageTypeStageSpeaker                          MessageType = 29
+	MessageTypeStageTopic                            MessageType = 31
+	MessageTypeGuildApplicationPremiumSubscription   MessageType = 32
+	MessageTypePollResult                            MessageType = 46
 )
 
+// IsKnown returns whether the type is one of the MessageType constants of
+// this package. Discord adds new message types from time to time, which code
+// switching over the type of a message may want to handle separately.
+func (t MessageType) IsKnown() bool {
+	switch t {
+	case MessageTypeDefault, MessageTypeRecipientAdd, MessageTypeRecipientRemove, MessageTypeCall,
+		MessageTypeChannelNameChange, MessageTypeChannelIconChange, MessageTypeChannelPinnedMessage,
+		MessageTypeGuildMemberJoin, MessageTypeUserPremiumGuildSubscription,
+		MessageTypeUserPremiumGuildSubscriptionTierOne, MessageTypeUserPremiumGuildSubscriptionTierTwo,
+		MessageTypeUserPremiumGuildSubscriptionTierThree, MessageTypeChannelFollowAdd,
+		MessageTypeGuildDiscoveryDisqualified, MessageTypeGuildDiscoveryRequalified,
+		MessageTypeGuildDiscoveryGracePeriodInitial, MessageTypeGuildDiscoveryGracePeriodFinal,
+		MessageTypeThreadCreated, MessageTypeReply, MessageTypeChatInputCommand,
+		MessageTypeThreadStarterMessage, MessageTypeGuildInviteReminder, MessageTypeContextMenuCommand,
+		MessageTypeAutoModerationAction, MessageTypeRoleSubscriptionPurchase,
+		MessageTypeInteractionPremiumUpsell, MessageTypeStageStart, MessageTypeStageEnd,
+		MessageTypeStageSpeaker, MessageTypeStageTopic, MessageTypeGuildApplicationPremiumSubscription,
+		MessageTypePollResult:
+		return true
+	}
+	return false
+}
+
+// IsSystem returns whether messages of the type are sent by Discord,
+// such as join or pin notifications, as opposed to regular messages,
+// replies and application command responses.
+func (t MessageType) IsSystem() bool {
+	switch t {
+	case MessageTypeDefault, MessageTypeReply, MessageTypeChatInputCommand, MessageTypeContextMenuCommand:
+		return false
+	}
+	return true
+}
+
 // A Message stores all data related to a specific Discord message.
 type Message struct {
 	// The ID of the message.
@@ -150,6 +203,10 @@ type Message struct {
 
 	// An array of Sticker objects, if any were sent.
 	StickerItems []*Sticker `json:"sticker_items"`
+
+	// Whether referenced_message was sent as null, meaning the
+	// referenced message was deleted.
+	referencedMessageDeleted bool
 }
 
 // UnmarshalJSON is a helper function to unmarshal the Message.
@@ -157,7 +214,8 @@ func (m *Message) UnmarshalJSON(data []byte) error {
 	type message Message
 	var v struct {
 		message
-		RawComponents []unmarshalableMessageComponent `json:"components"`
+		RawComponents        []unmarshalableMessageComponent `json:"components"`
+		RawReferencedMessage json.RawMessage                 `json:"referenced_message"`
 	}
 	err := json.Unmarshal(data, &v)
 	if err != nil {
@@ -168,6 +226,11 @@ func (m *Message) UnmarshalJSON(data []byte) error {
 	for i, v := range v.RawComponents {
 		m.Components[i] = v.MessageComponent
 	}
+	if string(v.RawReferencedMessage) == "null" {
+		m.referencedMessageDeleted = true
+	} else if len(v.RawReferencedMessage) > 0 {
+		err = json.Unmarshal(v.RawReferencedMessage, &m.ReferencedMessage)
+	}
 	return err
 }
 
@@ -215,25 +278,98 @@ const (
 	MessageFlagsLoading MessageFlags = 1 << 7
 	// MessageFlagsFailedToMentionSomeRolesInThread this message failed to mention some roles and add their members to the thread.
 	MessageFlagsFailedToMentionSomeRolesInThread MessageFlags = 1 << 8
+	// MessageFlagsIsComponentsV2 this message uses the components V2 layout system,
+	// it cannot contain content or embeds.
+	MessageFlagsIsComponentsV2 MessageFlags = 1 << 15
 )
 
 // File stores info about files you e.g. send in messages.
+// Files are read into the request body before it is sent, so a request is
+// retried with the same files. A Reader which is an io.Seeker is seeked back
+// after being read, so that the File can also be sent again by the caller,
+// for example after a RateLimitError; other readers can only be sent once.
 type File struct {
 	Name        string
 	ContentType string
 	Reader      io.Reader
 }
 
+// Maximum sizes of the files attached to a message, which apply to every
+// single file as well as to all files of a message combined.
+const (
+	MaxFileSize             int64 = 25 << 20
+	MaxFileSizePremiumTier2 int64 = 50 << 20
+	MaxFileSizePremiumTier3 int64 = 100 << 20
+)
+
+// maxFileSize returns the maximum size of the files attached to a message
+// in a guild with the given boost tier.
+func maxFileSize(tier PremiumTier) int64 {
+	switch tier {
+	case PremiumTier2:
+		return MaxFileSizePremiumTier2
+	case PremiumTier3:
+		return MaxFileSizePremiumTier3
+	}
+	return MaxFileSize
+}
+
+// readFiles reads the files to be attached to a message, returning copies
+// of them which can be read again. Readers which are an io.Seeker are seeked
+// back afterwards, so that the files can be sent again. It returns a
+// descriptive error if a file is empty, has an invalid content type, or if
+// the files exceed limit. Missing content types are detected from the file
+// contents.
+func readFiles(files []*File, limit int64) ([]*File, error) {
+	read := make([]*File, len(files))
+	var total int64
+	for i, file := range files {
+		if file == nil || file.Reader == nil {
+			return nil, fmt.Errorf("file %d has no reader", i)
+		}
+
+		rewind := rewinder(file.Reader)
+		data, err := ioutil.ReadAll(io.LimitReader(file.Reader, limit-total+1))
+		if err == nil {
+			err = rewind()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading file %q: %w", file.Name, err)
+		}
+		if len(data) == 0 {
+			if _, ok := file.Reader.(io.Seeker); !ok {
+				return nil, fmt.Errorf("file %q is empty, readers which are not an io.Seeker cannot be sent again", file.Name)
+			}
+			return nil, fmt.Errorf("file %q is empty", file.Name)
+		}
+		total += int64(len(data))
+		if total > limit {
+			return nil, fmt.Errorf("file %q exceeds the attachment size limit of %d bytes", file.Name, limit)
+		}
+
+		contentType := file.ContentType
+		if contentType == "" {
+			contentType = http.DetectContentType(data)
+		} else if _, _, err := mime.ParseMediaType(contentType); err != nil {
+			return nil, fmt.Errorf("file %q has an invalid content type %q: %w", file.Name, contentType, err)
+		}
+
+		read[i] = &File{Name: file.Name, ContentType: contentType, Reader: bytes.NewReader(data)}
+	}
+	return read, nil
+}
+
 // MessageSend stores all parameters you can send with ChannelMessageSendComplex.
 type MessageSend struct {
 	Content         string                  `json:"content,omitempty"`
-	Embeds          []*MessageEmbed         `json:"embeds"`
+	Embeds          []*MessageEmbed         `json:"embeds,omitempty"`
 	TTS             bool                    `json:"tts"`
 	Components      []MessageComponent      `json:"components"`
 	Files           []*File                 `json:"-"`
 	AllowedMentions *MessageAllowedMentions `json:"allowed_mentions,omitempty"`
 	Reference       *MessageReference       `json:"message_reference,omitempty"`
-	StickerIDs      []string                `json:"sticker_ids"`
+	StickerIDs      []string                `json:"sticker_ids,omitempty"`
+	Flags           MessageFlags            `json:"flags,omitempty"`
 
 	// TODO: Remove this when compatibility is not required.
 	File *File `json:"-"`
@@ -401,7 +537,7 @@ type MessageEmbed struct {
 	Type        EmbedType              `json:"type,omitempty"`
 	Title       string                 `json:"title,omitempty"`
 	Description string                 `json:"description,omitempty"`
-	Timestamp   string                 `json:"timestamp,omitempty"`
+	Timestamp   string                 `json:"timestamp,omitempty"` // ISO8601 timestamp, see SetTimestamp
 	Color       int                    `json:"color,omitempty"`
 	Footer      *MessageEmbedFooter    `json:"footer,omitempty"`
 	Image       *MessageEmbedImage     `json:"image,omitempty"`
@@ -412,6 +548,26 @@ type MessageEmbed struct {
 	Fields      []*MessageEmbedField   `json:"fields,omitempty"`
 }
 
+// SetTimestamp sets the timestamp of the embed, which is displayed in the
+// local time of the viewer, to t.
+func (e *MessageEmbed) SetTimestamp(t time.Time) {
+	e.Timestamp = t.Format(time.RFC3339)
+}
+
+// SetTimestampNow sets the timestamp of the embed to the current time.
+func (e *MessageEmbed) SetTimestampNow() {
+	e.SetTimestamp(time.Now())
+}
+
+// ParseTimestamp parses the timestamp of the embed. It returns the zero time
+// and no error if the embed has no timestamp.
+func (e *MessageEmbed) ParseTimestamp() (time.Time, error) {
+	if e.Timestamp == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, e.Timestamp)
+}
+
 // EmbedType is the type of embed
 // https://discord.com/developers/docs/resources/channel#embed-object-embed-types
 type EmbedType string
@@ -428,9 +584,42 @@ const (
 
 // MessageReactions holds a reactions object for a message.
 type MessageReactions struct {
-	Count int    `json:"count"`
-	Me    bool   `json:"me"`
-	Emoji *Emoji `json:"emoji"`
+	Count        int                          `json:"count"`
+	CountDetails *MessageReactionCountDetails `json:"count_details"`
+	Me           bool                         `json:"me"`
+	MeBurst      bool                         `json:"me_burst"`
+	Emoji        *Emoji                       `json:"emoji"`
+	// The colors used for super reactions, in hex format, e.g. "#ff0000".
+	BurstColors []string `json:"burst_colors"`
+}
+
+// MessageReactionCountDetails is the breakdown of the count of a reaction
+// into normal and super reactions.
+type MessageReactionCountDetails struct {
+	Burst  int `json:"burst"`
+	Normal int `json:"normal"`
+}
+
+// ReactionType is the type of a reaction.
+type ReactionType int
+
+// Reaction types.
+const (
+	ReactionTypeNormal ReactionType = 0
+	// ReactionTypeBurst is a super reaction. Bots cannot add super reactions.
+	ReactionTypeBurst ReactionType = 1
+)
+
+// MessagePin holds a pinned message along with the time it was pinned.
+type MessagePin struct {
+	PinnedAt time.Time `json:"pinned_at"`
+	Message  *Message  `json:"message"`
+}
+
+// MessagePinsList holds a page of pinned messages returned by ChannelMessagePins.
+type MessagePinsList struct {
+	Items   []*MessagePin `json:"items"`
+	HasMore bool          `json:"has_more"`
 }
 
 // MessageActivity is sent with Rich Presence-related chat embeds
@@ -475,6 +664,131 @@ func (m *Message) Reference() *MessageReference {
 	}
 }
 
+// ResolveReference returns the message referenced by m, such as the message
+// it replies to. ReferencedMessage is returned when it was sent with m,
+// otherwise the message is fetched using MessageReference.
+// ErrMessageNoReference is returned if m does not reference a message and
+// ErrReferencedMessageDeleted if the referenced message was deleted.
+func (m *Message) ResolveReference(s *Session, options ...RequestOption) (*Message, error) {
+	if m.ReferencedMessage != nil {
+		return m.ReferencedMessage, nil
+	}
+	if m.MessageReference == nil || m.MessageReference.MessageID == "" {
+		return nil, ErrMessageNoReference
+	}
+	if m.referencedMessageDeleted {
+		return nil, ErrReferencedMessageDeleted
+	}
+
+	channelID := m.MessageReference.ChannelID
+	if channelID == "" {
+		channelID = m.ChannelID
+	}
+
+	ref, err := s.ChannelMessage(channelID, m.MessageReference.MessageID, options...)
+	if err != nil {
+		var restErr *RESTError
+		if errors.As(err, &restErr) && restErr.Message != nil && restErr.Message.Code == ErrCodeUnknownMessage {
+			return nil, ErrReferencedMessageDeleted
+		}
+		return nil, err
+	}
+	return ref, nil
+}
+
+// IsWebhook returns whether the message was sent by a webhook.
+func (m *Message) IsWebhook() bool {
+	return m.WebhookID != ""
+}
+
+// IsBot returns whether the message was sent by a bot user.
+// Messages sent by webhooks are reported as sent by bots as well.
+func (m *Message) IsBot() bool {
+	return m.Author != nil && m.Author.Bot
+}
+
+// IsSystem returns whether the message is a system message, such as a member
+// join or a pin notification. See MessageType.IsSystem.
+func (m *Message) IsSystem() bool {
+	return m.Type.IsSystem()
+}
+
+// SystemContent returns the text shown by the Discord client for system
+// messages, e.g. "Name joined the server." for MessageTypeGuildMemberJoin.
+// For other messages and unknown system message types, Content is returned.
+func (m *Message) SystemContent() string {
+	author := systemMessageUserName(m.Author)
+	mentioned := "someone"
+	if len(m.Mentions) > 0 {
+		mentioned = systemMessageUserName(m.Mentions[0])
+	}
+
+	switch m.Type {
+	case MessageTypeRecipientAdd:
+		return author + " added " + mentioned + " to the group."
+	case MessageTypeRecipientRemove:
+		if len(m.Mentions) > 0 && m.Author != nil && m.Mentions[0].ID == m.Author.ID {
+			return author + " left the group."
+		}
+		return author + " removed " + mentioned + " from the group."
+	case MessageTypeCall:
+		return author + " started a call."
+	case MessageTypeChannelNameChange:
+		return author + " changed the channel name: " + m.Content
+	case MessageTypeChannelIconChange:
+		return author + " changed the channel icon."
+	case MessageTypeChannelPinnedMessage:
+		return author + " pinned a message to this channel."
+	case MessageTypeGuildMemberJoin:
+		return author + " joined the server."
+	case MessageTypeUserPremiumGuildSubscription:
+		if n, err := strconv.Atoi(m.Content); err == nil && n > 1 {
+			return author + " just boosted the server " + m.Content + " times!"
+		}
+		return author + " just boosted the server!"
+	case MessageTypeUserPremiumGuildSubscriptionTierOne:
+		return author + " just boosted the server! This server has achieved Level 1!"
+	case MessageTypeUserPremiumGuildSubscriptionTierTwo:
+		return author + " just boosted the server! This server has achieved Level 2!"
+	case MessageTypeUserPremiumGuildSubscriptionTierThree:
+		return author + " just boosted the server! This server has achieved Level 3!"
+	case MessageTypeChannelFollowAdd:
+		return author + " has added " + m.Content + " to this channel. Its most important updates will show up here."
+	case MessageTypeGuildDiscoveryDisqualified:
+		return "This server has been removed from Server Discovery because it no longer passes all the requirements."
+	case MessageTypeGuildDiscoveryRequalified:
+		return "This server is eligible for Server Discovery again and has been automatically relisted!"
+	case MessageTypeGuildDiscoveryGracePeriodInitial:
+		return "This server has failed Discovery activity requirements for 1 week."
+	case MessageTypeGuildDiscoveryGracePeriodFinal:
+		return "This server has failed Discovery activity requirements for 3 weeks in a row."
+	case MessageTypeThreadCreated:
+		return author + " started a thread: " + m.Content
+	case MessageTypeGuildInviteReminder:
+		return "Wondering who to invite? Start by inviting anyone who can help you build the server!"
+	case MessageTypeStageStart:
+		return author + " started " + m.Content
+	case MessageTypeStageEnd:
+		return author + " ended " + m.Content
+	case MessageTypeStageSpeaker:
+		return author + " is now a speaker."
+	case MessageTypeStageTopic:
+		return author + " changed the Stage topic: " + m.Content
+	}
+	return m.Content
+}
+
+// systemMessageUserName returns the name a user is shown with in system messages.
+func systemMessageUserName(u *User) string {
+	switch {
+	case u == nil:
+		return "Someone"
+	case u.GlobalName != "":
+		return u.GlobalName
+	}
+	return u.Username
+}
+
 // ContentWithMentionsReplaced will replace all @<id> mentions with the
 // username of the mention.
 func (m *Message) ContentWithMentionsReplaced() (content string) {
@@ -496,12 +810,13 @@ var patternChannels = regexp.MustCompile("<#[^>]*>")
 func (m *Message) ContentWithMoreMentionsReplaced(s *Session) (content string, err error) {
 	content = m.Content
 
-	if !s.StateEnabled {
+	store := s.stateStore()
+	if !s.StateEnabled || store == nil {
 		content = m.ContentWithMentionsReplaced()
 		return
 	}
 
-	channel, err := s.State.Channel(m.ChannelID)
+	channel, err := store.Channel(m.ChannelID)
 	if err != nil {
 		content = m.ContentWithMentionsReplaced()
 		return
@@ -510,7 +825,7 @@ func (m *Message) ContentWithMoreMentionsReplaced(s *Session) (content string, e
 	for _, user := range m.Mentions {
 		nick := user.Username
 
-		member, err := s.State.Member(channel.GuildID, user.ID)
+		member, err := store.Member(channel.GuildID, user.ID)
 		if err == nil && member.Nick != "" {
 			nick = member.Nick
 		}
@@ -521,7 +836,7 @@ func (m *Message) ContentWithMoreMentionsReplaced(s *Session) (content string, e
 		).Replace(content)
 	}
 	for _, roleID := range m.MentionRoles {
-		role, err := s.State.Role(channel.GuildID, roleID)
+		role, err := store.Role(channel.GuildID, roleID)
 		if err != nil || !role.Mentionable {
 			continue
 		}
@@ -530,7 +845,7 @@ func (m *Message) ContentWithMoreMentionsReplaced(s *Session) (content string, e
 	}
 
 	content = patternChannels.ReplaceAllStringFunc(content, func(mention string) string {
-		channel, err := s.State.Channel(mention[2 : len(mention)-1])
+		channel, err := store.Channel(mention[2 : len(mention)-1])
 		if err != nil || channel.Type == ChannelTypeGuildVoice {
 			return mention
 		}
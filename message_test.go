@@ -1,7 +1,12 @@
 package discordgo
 
 import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestContentWithMoreMentionsReplaced(t *testing.T) {
@@ -50,3 +55,243 @@ func TestGettingEmojisFromMessage(t *testing.T) {
 	}
 
 }
+
+func TestMessageComponentsV2Unmarshal(t *testing.T) {
+	data := []byte(`{"id":"1","flags":32768,"components":[{"type":17,"accent_color":255,"components":[
+		{"type":9,"components":[{"type":10,"content":"hello"}],"accessory":{"type":11,"media":{"url":"https://example.com/a.png"}}},
+		{"type":14,"spacing":2},
+		{"type":12,"items":[{"media":{"url":"https://example.com/b.png"}}]}
+	]}]}`)
+
+	var m Message
+	if err := m.UnmarshalJSON(data); err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Flags&MessageFlagsIsComponentsV2 == 0 {
+		t.Error("expected MessageFlagsIsComponentsV2 to be set")
+	}
+	container, ok := m.Components[0].(*Container)
+	if !ok || len(container.Components) != 3 || container.AccentColor == nil || *container.AccentColor != 255 {
+		t.Fatalf("unexpected container: %#v", m.Components[0])
+	}
+	section, ok := container.Components[0].(*Section)
+	if !ok {
+		t.Fatalf("expected section, got %#v", container.Components[0])
+	}
+	if text, ok := section.Components[0].(*TextDisplay); !ok || text.Content != "hello" {
+		t.Errorf("unexpected section text: %#v", section.Components[0])
+	}
+	if thumb, ok := section.Accessory.(*Thumbnail); !ok || thumb.Media.URL != "https://example.com/a.png" {
+		t.Errorf("unexpected section accessory: %#v", section.Accessory)
+	}
+	if _, ok := container.Components[2].(*MediaGallery); !ok {
+		t.Errorf("expected media gallery, got %#v", container.Components[2])
+	}
+}
+
+func TestMessageSendMarshal(t *testing.T) {
+	// Components V2 messages are rejected if embeds or sticker_ids are sent,
+	// even as null, so they are left out when empty.
+	data, err := json.Marshal(&MessageSend{Flags: MessageFlagsIsComponentsV2, Components: []MessageComponent{TextDisplay{Content: "hello"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]json.RawMessage
+	if err = json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"embeds", "sticker_ids", "content"} {
+		if _, ok := got[key]; ok {
+			t.Errorf("got %s, want %s to be omitted", data, key)
+		}
+	}
+
+	data, err = json.Marshal(&MessageSend{Embeds: []*MessageEmbed{{Title: "title"}}, StickerIDs: []string{"sticker"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `"embeds":[{"title":"title"}]`; !strings.Contains(string(data), want) {
+		t.Errorf("got %s, want it to contain %s", data, want)
+	}
+	if want := `"sticker_ids":["sticker"]`; !strings.Contains(string(data), want) {
+		t.Errorf("got %s, want it to contain %s", data, want)
+	}
+}
+
+func TestReadFiles(t *testing.T) {
+	files, err := readFiles([]*File{{Name: "a.txt", Reader: strings.NewReader("hello")}}, MaxFileSize)
+	if err != nil {
+		t.Fatalf("readFiles returned error: %s", err)
+	}
+	if files[0].ContentType != "text/plain; charset=utf-8" {
+		t.Errorf("content type not detected, got %q", files[0].ContentType)
+	}
+
+	tests := map[string][]*File{
+		"empty":        {{Name: "empty.txt", Reader: strings.NewReader("")}},
+		"content type": {{Name: "a.txt", ContentType: "text/", Reader: strings.NewReader("hello")}},
+		"too large":    {{Name: "a.txt", Reader: strings.NewReader("hello")}, {Name: "b.txt", Reader: strings.NewReader("world")}},
+	}
+	for name, files := range tests {
+		if _, err := readFiles(files, 8); err == nil {
+			t.Errorf("readFiles did not return an error for %s files", name)
+		}
+	}
+}
+
+func TestMessageAuthorType(t *testing.T) {
+	tests := []struct {
+		name                   string
+		message                *Message
+		webhook, bot, isSystem bool
+	}{
+		{"user", &Message{Author: &User{}}, false, false, false},
+		{"bot", &Message{Author: &User{Bot: true}, Type: MessageTypeReply}, false, true, false},
+		{"webhook", &Message{Author: &User{Bot: true}, WebhookID: "1"}, true, true, false},
+		{"join", &Message{Author: &User{}, Type: MessageTypeGuildMemberJoin}, false, false, true},
+		{"pin", &Message{Author: &User{}, Type: MessageTypeChannelPinnedMessage}, false, false, true},
+	}
+	for _, tt := range tests {
+		if tt.message.IsWebhook() != tt.webhook || tt.message.IsBot() != tt.bot || tt.message.IsSystem() != tt.isSystem {
+			t.Errorf("%s message: IsWebhook %v, IsBot %v, IsSystem %v", tt.name, tt.message.IsWebhook(), tt.message.IsBot(), tt.message.IsSystem())
+		}
+	}
+}
+
+func TestMessageReactionCountDetails(t *testing.T) {
+	var m Message
+	err := json.Unmarshal([]byte(`{"reactions":[{"count":3,"count_details":{"burst":1,"normal":2},"me":false,"me_burst":true,"emoji":{"name":"👍"},"burst_colors":["#ff0000"]}]}`), &m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := m.Reactions[0]
+	if r.CountDetails == nil || r.CountDetails.Burst != 1 || r.CountDetails.Normal != 2 || !r.MeBurst || len(r.BurstColors) != 1 {
+		t.Errorf("got reaction %+v, want the super reaction details", r)
+	}
+
+	var add MessageReactionAdd
+	if err := json.Unmarshal([]byte(`{"user_id":"user","emoji":{"name":"👍"},"burst":true,"type":1,"burst_colors":["#ff0000"]}`), &add); err != nil {
+		t.Fatal(err)
+	}
+	if add.Type != ReactionTypeBurst || !add.Burst || len(add.BurstColors) != 1 {
+		t.Errorf("got reaction add %+v, want a super reaction", add.MessageReaction)
+	}
+}
+
+func TestMessageResolveReference(t *testing.T) {
+	s, err := New("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.StateEnabled = false
+	s.Client.Transport = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		if strings.HasSuffix(r.URL.Path, "/channels/channel/messages/gone") {
+			return &http.Response{StatusCode: http.StatusNotFound, Status: "404 Not Found", Body: ioutil.NopCloser(strings.NewReader(`{"code": 10008}`)), Header: http.Header{}}, nil
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(`{"id": "fetched"}`)), Header: http.Header{}}, nil
+	})
+
+	tests := []struct {
+		name string
+		data string
+		want string
+		err  error
+	}{
+		{"embedded", `{"message_reference": {"message_id": "original"}, "referenced_message": {"id": "original"}}`, "original", nil},
+		{"fetched", `{"channel_id": "channel", "message_reference": {"message_id": "original"}}`, "fetched", nil},
+		{"deleted", `{"message_reference": {"message_id": "original"}, "referenced_message": null}`, "", ErrReferencedMessageDeleted},
+		{"unknown message", `{"channel_id": "channel", "message_reference": {"message_id": "gone"}}`, "", ErrReferencedMessageDeleted},
+		{"no reference", `{"id": "message"}`, "", ErrMessageNoReference},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var m Message
+			if err := json.Unmarshal([]byte(tt.data), &m); err != nil {
+				t.Fatal(err)
+			}
+			ref, err := m.ResolveReference(s)
+			if err != tt.err {
+				t.Fatalf("ResolveReference() error = %v, want %v", err, tt.err)
+			}
+			if tt.err == nil && ref.ID != tt.want {
+				t.Errorf("ResolveReference() = %q, want %q", ref.ID, tt.want)
+			}
+		})
+	}
+}
+
+func TestMessageSystemContent(t *testing.T) {
+	author := &User{ID: "author", Username: "author", GlobalName: "Author"}
+	other := &User{ID: "other", Username: "other"}
+
+	tests := []struct {
+		m    Message
+		want string
+	}{
+		{Message{Type: MessageTypeDefault, Content: "hello", Author: author}, "hello"},
+		{Message{Type: MessageTypeGuildMemberJoin, Author: author}, "Author joined the server."},
+		{Message{Type: MessageTypeChannelPinnedMessage, Author: other}, "other pinned a message to this channel."},
+		{Message{Type: MessageTypeUserPremiumGuildSubscription, Author: author}, "Author just boosted the server!"},
+		{Message{Type: MessageTypeUserPremiumGuildSubscription, Author: author, Content: "3"}, "Author just boosted the server 3 times!"},
+		{Message{Type: MessageTypeUserPremiumGuildSubscriptionTierTwo, Author: author}, "Author just boosted the server! This server has achieved Level 2!"},
+		{Message{Type: MessageTypeRecipientAdd, Author: author, Mentions: []*User{other}}, "Author added other to the group."},
+		{Message{Type: MessageTypeRecipientRemove, Author: author, Mentions: []*User{author}}, "Author left the group."},
+		{Message{Type: MessageTypeThreadCreated, Author: author, Content: "topic"}, "Author started a thread: topic"},
+		{Message{Type: MessageTypeAutoModerationAction, Content: "raw"}, "raw"},
+		{Message{Type: MessageType(1000), Content: "raw"}, "raw"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.m.SystemContent(); got != tt.want {
+			t.Errorf("SystemContent() of type %d = %q, want %q", tt.m.Type, got, tt.want)
+		}
+	}
+}
+
+func TestMessageUpdatePartial(t *testing.T) {
+	var full, partial MessageUpdate
+	if err := json.Unmarshal([]byte(`{"id": "1", "channel_id": "c", "content": "", "author": {"id": "u"}, "timestamp": "2023-01-01T00:00:00Z"}`), &full); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(`{"id": "1", "channel_id": "c", "embeds": []}`), &partial); err != nil {
+		t.Fatal(err)
+	}
+
+	if full.IsPartial() || !full.Has("content") {
+		t.Errorf("full update reported as partial or without content")
+	}
+	if !partial.IsPartial() || partial.Has("content") || !partial.Has("embeds") {
+		t.Errorf("partial update reported wrong fields")
+	}
+	if (&MessageUpdate{Message: &Message{}}).IsPartial() {
+		t.Errorf("constructed update reported as partial")
+	}
+}
+
+func TestMessageEmbedTimestamp(t *testing.T) {
+	var e MessageEmbed
+	if ts, err := e.ParseTimestamp(); err != nil || !ts.IsZero() {
+		t.Errorf("ParseTimestamp() without a timestamp = %v, %v", ts, err)
+	}
+
+	want := time.Date(2021, 3, 4, 5, 6, 7, 0, time.FixedZone("", 2*60*60))
+	e.SetTimestamp(want)
+	if e.Timestamp != "2021-03-04T05:06:07+02:00" {
+		t.Errorf("SetTimestamp() set %q", e.Timestamp)
+	}
+
+	var received MessageEmbed
+	if err := json.Unmarshal([]byte(`{"timestamp":"2021-03-04T03:06:07.123000+00:00"}`), &received); err != nil {
+		t.Fatal(err)
+	}
+	if ts, err := received.ParseTimestamp(); err != nil || !ts.Truncate(time.Second).Equal(want) {
+		t.Errorf("ParseTimestamp() = %v, %v, want %v", ts, err, want)
+	}
+
+	e.SetTimestampNow()
+	if ts, err := e.ParseTimestamp(); err != nil || time.Since(ts) > time.Minute {
+		t.Errorf("SetTimestampNow() set %q", e.Timestamp)
+	}
+}
@@ -41,6 +41,7 @@ type Team struct {
 }
 
 // Application returns an Application structure of a specific Application
+// Use "@me" as appID to get the application of the current bot.
 //   appID : The ID of an Application
 func (s *Session) Application(appID string) (st *Application, err error) {
 
@@ -53,6 +54,19 @@ func (s *Session) Application(appID string) (st *Application, err error) {
 	return
 }
 
+// ApplicationEditMe edits the application of the current bot.
+// data : The fields of the application to change.
+func (s *Session) ApplicationEditMe(data *ApplicationParams, options ...RequestOption) (st *Application, err error) {
+
+	body, err := s.RequestWithBucketID("PATCH", EndpointApplication("@me"), data, EndpointApplication("@me"), options...)
+	if err != nil {
+		return
+	}
+
+	err = unmarshal(body, &st)
+	return
+}
+
 // Applications returns all applications for the authenticated user
 func (s *Session) Applications() (st []*Application, err error) {
 
@@ -93,14 +93,26 @@ func (r *RateLimiter) LockBucket(bucketID string) *Bucket {
 
 // LockBucketObject Locks an already resolved bucket until a request can be made
 func (r *RateLimiter) LockBucketObject(b *Bucket) *Bucket {
+	r.lockBucketObject(b, 0)
+	return b
+}
+
+// lockBucketObject locks a bucket until a request can be made, unless that
+// requires waiting longer than maxWait, in which case the bucket is left
+// unlocked and ok is false. A maxWait of 0 waits for as long as necessary.
+func (r *RateLimiter) lockBucketObject(b *Bucket, maxWait time.Duration) (wait time.Duration, ok bool) {
 	b.Lock()
 
-	if wait := r.GetWaitTime(b, 1); wait > 0 {
+	if wait = r.GetWaitTime(b, 1); wait > 0 {
+		if maxWait > 0 && wait > maxWait {
+			b.Unlock()
+			return wait, false
+		}
 		time.Sleep(wait)
 	}
 
 	b.Remaining--
-	return b
+	return wait, true
 }
 
 // Bucket represents a ratelimit bucket, each bucket gets ratelimited individually (-global ratelimits)
@@ -111,3 +111,22 @@ func sendBenchReq(endpoint string, rl *RateLimiter) {
 
 	bucket.Release(headers)
 }
+
+func TestGatewayRateLimiter(t *testing.T) {
+	var l gatewayRateLimiter
+	now := time.Now()
+
+	for i := 0; i < 2; i++ {
+		if wait := l.reserve(2, now); wait != 0 {
+			t.Fatalf("command %d should not wait, got %v", i, wait)
+		}
+	}
+
+	if wait := l.reserve(2, now.Add(10*time.Second)); wait != 50*time.Second {
+		t.Errorf("expected to wait 50s, got %v", wait)
+	}
+
+	if wait := l.reserve(2, now.Add(gatewayRateLimitWindow)); wait != 0 {
+		t.Errorf("expected no wait once the window passed, got %v", wait)
+	}
+}
@@ -0,0 +1,187 @@
+// Discordgo - Discord bindings for Go
+// Available at https://github.com/bwmarrin/discordgo
+
+// Copyright 2015-2016 Bruce Marriner <bruce@sqls.net>.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file contains a small helper for assigning roles to members when they
+// react to a message, commonly known as "reaction roles".
+
+package discordgo
+
+import (
+	"errors"
+	"sync"
+)
+
+// ReactionRole maps a reaction with a given emoji on a message to a role.
+type ReactionRole struct {
+	MessageID string `json:"message_id"`
+	// Emoji is either a unicode emoji, a custom emoji ID, or a custom
+	// emoji in any of the "name:id", "<:name:id>" or "<a:name:id>" forms.
+	Emoji  string `json:"emoji"`
+	RoleID string `json:"role_id"`
+}
+
+// ReactionRoles assigns and removes roles when members add or remove
+// reactions on registered messages.
+// Use NewReactionRoles to create one and AddHandlers to attach it to a Session.
+type ReactionRoles struct {
+	sync.RWMutex
+
+	// roles maps message IDs to emoji keys to role IDs
+	roles map[string]map[string]string
+}
+
+// NewReactionRoles creates an empty ReactionRoles manager.
+func NewReactionRoles() *ReactionRoles {
+	return &ReactionRoles{
+		roles: make(map[string]map[string]string),
+	}
+}
+
+// Add registers a role to be given to members reacting with emoji on a message.
+// Registering the same message and emoji again replaces the role.
+func (r *ReactionRoles) Add(messageID, emoji, roleID string) {
+	r.Lock()
+	defer r.Unlock()
+
+	emojis, ok := r.roles[messageID]
+	if !ok {
+		emojis = make(map[string]string)
+		r.roles[messageID] = emojis
+	}
+	emojis[emojiKey(emoji)] = roleID
+}
+
+// Remove unregisters the role for an emoji on a message.
+func (r *ReactionRoles) Remove(messageID, emoji string) {
+	r.Lock()
+	defer r.Unlock()
+
+	emojis, ok := r.roles[messageID]
+	if !ok {
+		return
+	}
+	delete(emojis, emojiKey(emoji))
+	if len(emojis) == 0 {
+		delete(r.roles, messageID)
+	}
+}
+
+// Role returns the role registered for an emoji on a message, if any.
+func (r *ReactionRoles) Role(messageID, emoji string) (roleID string, ok bool) {
+	r.RLock()
+	defer r.RUnlock()
+
+	roleID, ok = r.roles[messageID][emojiKey(emoji)]
+	return
+}
+
+// ReactionRoles returns all registered mappings, for example to persist them.
+func (r *ReactionRoles) ReactionRoles() []ReactionRole {
+	r.RLock()
+	defer r.RUnlock()
+
+	var st []ReactionRole
+	for messageID, emojis := range r.roles {
+		for emoji, roleID := range emojis {
+			st = append(st, ReactionRole{MessageID: messageID, Emoji: emoji, RoleID: roleID})
+		}
+	}
+	return st
+}
+
+// Load registers all of the provided mappings, for example to restore
+// mappings previously returned by ReactionRoles.
+func (r *ReactionRoles) Load(roles []ReactionRole) {
+	for _, rr := range roles {
+		r.Add(rr.MessageID, rr.Emoji, rr.RoleID)
+	}
+}
+
+// AddHandlers registers the event handlers which assign and remove roles on s.
+// The return value is a function which removes the handlers again.
+func (r *ReactionRoles) AddHandlers(s *Session) func() {
+	removeAdd := s.AddHandler(r.onReactionAdd)
+	removeRemove := s.AddHandler(r.onReactionRemove)
+
+	return func() {
+		removeAdd()
+		removeRemove()
+	}
+}
+
+func (r *ReactionRoles) lookup(s *Session, mr *MessageReaction) (roleID string, ok bool) {
+	if mr == nil || mr.GuildID == "" {
+		return "", false
+	}
+
+	// Ignore our own reactions, such as the ones used to seed the message.
+	if s.State != nil && s.State.User != nil && s.State.User.ID == mr.UserID {
+		return "", false
+	}
+
+	return r.Role(mr.MessageID, mr.Emoji.APIName())
+}
+
+// memberHasRole reports whether a member is known to have a role.
+// If the member is not known, it is assumed they do not.
+func memberHasRole(m *Member, roleID string) bool {
+	if m == nil {
+		return false
+	}
+	for _, id := range m.Roles {
+		if id == roleID {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *ReactionRoles) onReactionAdd(s *Session, m *MessageReactionAdd) {
+	roleID, ok := r.lookup(s, m.MessageReaction)
+	if !ok {
+		return
+	}
+
+	if memberHasRole(m.Member, roleID) {
+		return
+	}
+
+	err := s.GuildMemberRoleAdd(m.GuildID, m.UserID, roleID)
+	if err != nil {
+		r.logError(s, "adding", roleID, m.MessageReaction, err)
+	}
+}
+
+func (r *ReactionRoles) onReactionRemove(s *Session, m *MessageReactionRemove) {
+	roleID, ok := r.lookup(s, m.MessageReaction)
+	if !ok {
+		return
+	}
+
+	// Removal events do not include the member, so only skip the request
+	// when the state knows the member doesn't have the role.
+	if store := s.stateStore(); store != nil {
+		if member, err := store.Member(m.GuildID, m.UserID); err == nil && !memberHasRole(member, roleID) {
+			return
+		}
+	}
+
+	err := s.GuildMemberRoleRemove(m.GuildID, m.UserID, roleID)
+	if err != nil {
+		r.logError(s, "removing", roleID, m.MessageReaction, err)
+	}
+}
+
+func (r *ReactionRoles) logError(s *Session, action, roleID string, mr *MessageReaction, err error) {
+	var restErr *RESTError
+	if errors.As(err, &restErr) && restErr.Message != nil && restErr.Message.Code == ErrCodeMissingPermissions {
+		s.log(LogWarning, "missing permissions %s reaction role %s for user %s in guild %s", action, roleID, mr.UserID, mr.GuildID)
+		return
+	}
+
+	s.log(LogError, "error %s reaction role %s for user %s in guild %s, %s", action, roleID, mr.UserID, mr.GuildID, err)
+}
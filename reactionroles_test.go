@@ -0,0 +1,88 @@
+package discordgo
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestReactionRoles(t *testing.T) {
+	rr := NewReactionRoles()
+	rr.Add("message", "<:kitty:811736565172011058>", "role1")
+	rr.Add("message", "👍", "role2")
+
+	custom := Emoji{ID: "811736565172011058", Name: "kitty"}
+	if roleID, ok := rr.Role("message", custom.APIName()); !ok || roleID != "role1" {
+		t.Errorf("Role() for custom emoji = %q, %v", roleID, ok)
+	}
+	if roleID, ok := rr.Role("message", "👍"); !ok || roleID != "role2" {
+		t.Errorf("Role() for unicode emoji = %q, %v", roleID, ok)
+	}
+
+	restored := NewReactionRoles()
+	restored.Load(rr.ReactionRoles())
+	if roleID, ok := restored.Role("message", "kitty:811736565172011058"); !ok || roleID != "role1" {
+		t.Errorf("Role() after Load = %q, %v", roleID, ok)
+	}
+
+	rr.Remove("message", "811736565172011058")
+	if _, ok := rr.Role("message", custom.APIName()); ok {
+		t.Error("Role() returned a removed mapping")
+	}
+}
+
+func TestReactionRolesHandlers(t *testing.T) {
+	s, err := New("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SyncEvents = true
+	s.State.User = &User{ID: "bot"}
+	if err := s.State.GuildAdd(&Guild{ID: "guild"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.State.MemberAdd(&Member{GuildID: "guild", User: &User{ID: "without"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	var requests []string
+	s.Client.Transport = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		requests = append(requests, r.Method+" "+r.URL.String())
+		return &http.Response{StatusCode: http.StatusNoContent, Body: ioutil.NopCloser(strings.NewReader("")), Header: http.Header{}}, nil
+	})
+
+	rr := NewReactionRoles()
+	rr.Add("message", "👍", "role")
+	remove := rr.AddHandlers(s)
+
+	reaction := func(userID, messageID, emoji string) *MessageReaction {
+		return &MessageReaction{UserID: userID, MessageID: messageID, GuildID: "guild", Emoji: Emoji{Name: emoji}}
+	}
+
+	s.handleEvent(messageReactionAddEventType, &MessageReactionAdd{MessageReaction: reaction("user", "message", "👍")})
+	s.handleEvent(messageReactionAddEventType, &MessageReactionAdd{MessageReaction: reaction("user", "message", "👎")})
+	s.handleEvent(messageReactionAddEventType, &MessageReactionAdd{MessageReaction: reaction("user", "other", "👍")})
+	s.handleEvent(messageReactionAddEventType, &MessageReactionAdd{MessageReaction: reaction("bot", "message", "👍")})
+	s.handleEvent(messageReactionAddEventType, &MessageReactionAdd{
+		MessageReaction: reaction("member", "message", "👍"),
+		Member:          &Member{Roles: []string{"role"}},
+	})
+	s.handleEvent(messageReactionRemoveEventType, &MessageReactionRemove{MessageReaction: reaction("user", "message", "👍")})
+	s.handleEvent(messageReactionRemoveEventType, &MessageReactionRemove{MessageReaction: reaction("without", "message", "👍")})
+
+	want := []string{
+		http.MethodPut + " " + EndpointGuildMemberRole("guild", "user", "role"),
+		http.MethodDelete + " " + EndpointGuildMemberRole("guild", "user", "role"),
+	}
+	if strings.Join(requests, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got requests %q, want %q", requests, want)
+	}
+
+	remove()
+	requests = nil
+	s.handleEvent(messageReactionAddEventType, &MessageReactionAdd{MessageReaction: reaction("user", "message", "👍")})
+	if len(requests) != 0 {
+		t.Errorf("got requests %q after removing the handlers", requests)
+	}
+}
@@ -23,6 +23,7 @@ import (
 	"log"
 	"net/http"
 	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -32,13 +33,22 @@ import (
 
 // All error constants
 var (
-	ErrJSONUnmarshal           = errors.New("json unmarshal")
-	ErrStatusOffline           = errors.New("You can't set your Status to offline")
-	ErrVerificationLevelBounds = errors.New("VerificationLevel out of bounds, should be between 0 and 3")
-	ErrPruneDaysBounds         = errors.New("the number of days should be more than or equal to 1")
-	ErrGuildNoIcon             = errors.New("guild does not have an icon set")
-	ErrGuildNoSplash           = errors.New("guild does not have a splash set")
-	ErrUnauthorized            = errors.New("HTTP request was unauthorized. This could be because the provided token was not a bot token. Please add \"Bot \" to the start of your token. https://discord.com/developers/docs/reference#authentication-example-bot-token-authorization-header")
+	ErrJSONUnmarshal               = errors.New("json unmarshal")
+	ErrStatusOffline               = errors.New("You can't set your Status to offline")
+	ErrVerificationLevelBounds     = errors.New("VerificationLevel out of bounds, should be between 0 and 4")
+	ErrMessageNotificationsBounds  = errors.New("DefaultMessageNotifications out of bounds, should be between 0 and 1")
+	ErrExplicitContentFilterBounds = errors.New("ExplicitContentFilter out of bounds, should be between 0 and 2")
+	ErrPruneDaysBounds             = errors.New("the number of days should be more than or equal to 1")
+	ErrGuildNoIcon                 = errors.New("guild does not have an icon set")
+	ErrGuildNoSplash               = errors.New("guild does not have a splash set")
+	ErrRoleIconAndEmoji            = errors.New("a role cannot have both an icon and a unicode emoji")
+	ErrChannelNoParent             = errors.New("channel does not have a parent category")
+	ErrInviteMaxAgeBounds          = errors.New("invite max age must be between 0 and 7 days")
+	ErrGroupDMBotToken             = errors.New("group DM operations are not available to bot tokens")
+	ErrBulkBanUserCount            = errors.New("bulk bans require between 1 and 200 user IDs")
+	ErrMessageNoReference          = errors.New("message does not reference another message")
+	ErrReferencedMessageDeleted    = errors.New("referenced message was deleted")
+	ErrUnauthorized                = errors.New("HTTP request was unauthorized. This could be because the provided token was not a bot token. Please add \"Bot \" to the start of your token. https://discord.com/developers/docs/reference#authentication-example-bot-token-authorization-header")
 )
 
 var (
@@ -83,7 +93,8 @@ func (r RESTError) Error() string {
 }
 
 // RateLimitError is returned when a request exceeds a rate limit
-// and ShouldRetryOnRateLimit is false. The request may be manually
+// and ShouldRetryOnRateLimit is false, or when waiting for a rate limit
+// would take longer than MaxRateLimitWait. The request may be manually
 // retried after waiting the duration specified by RetryAfter.
 type RateLimitError struct {
 	*RateLimit
@@ -94,6 +105,74 @@ func (e RateLimitError) Error() string {
 	return "Rate limit exceeded on " + e.URL + ", retry after " + e.RetryAfter.String()
 }
 
+// SlowmodeError is returned when a request, such as sending a message, is
+// refused because of the slowmode of a channel. It is not retried, even if
+// ShouldRetryOnRateLimit is set, as the slowmode may last for hours.
+// The request may be manually retried after waiting for RetryAfter.
+type SlowmodeError struct {
+	*RateLimit
+}
+
+// Error returns a slowmode error with the endpoint and retry time.
+func (e SlowmodeError) Error() string {
+	return "Slowmode active on " + e.URL + ", retry after " + e.RetryAfter.String()
+}
+
+// PermissionError is returned when the state shows that the current user
+// lacks permissions required for a request. See Session.CheckPermissions.
+type PermissionError struct {
+	ChannelID string
+	// The missing permissions.
+	Missing int64
+}
+
+// permissionNames holds the names of permissions checked before requests.
+var permissionNames = []struct {
+	permission int64
+	name       string
+}{
+	{PermissionViewChannel, "VIEW_CHANNEL"},
+	{PermissionSendMessages, "SEND_MESSAGES"},
+	{PermissionSendMessagesInThreads, "SEND_MESSAGES_IN_THREADS"},
+	{PermissionEmbedLinks, "EMBED_LINKS"},
+	{PermissionAttachFiles, "ATTACH_FILES"},
+}
+
+// Error returns the names of the missing permissions and the channel.
+func (e *PermissionError) Error() string {
+	var names []string
+	missing := e.Missing
+	for _, p := range permissionNames {
+		if missing&p.permission != 0 {
+			names = append(names, p.name)
+			missing &^= p.permission
+		}
+	}
+	if missing != 0 {
+		names = append(names, strconv.FormatInt(missing, 10))
+	}
+	return "missing permissions " + strings.Join(names, ", ") + " in channel " + e.ChannelID
+}
+
+// GuildErrors is returned by requests made for multiple guilds when some of
+// them failed. It maps the IDs of the guilds to their errors.
+type GuildErrors map[string]error
+
+// Error returns the number of failed guilds along with their errors.
+func (e GuildErrors) Error() string {
+	ids := make([]string, 0, len(e))
+	for id := range e {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	msgs := make([]string, len(ids))
+	for i, id := range ids {
+		msgs[i] = "guild " + id + ": " + e[id].Error()
+	}
+	return strconv.Itoa(len(e)) + " guild(s) failed: " + strings.Join(msgs, "; ")
+}
+
 // RequestConfig is an HTTP request configuration.
 type RequestConfig struct {
 	Request                *http.Request
@@ -112,6 +191,36 @@ func newRequestConfig(s *Session, req *http.Request) *RequestConfig {
 	}
 }
 
+// RequestMiddleware wraps the HTTP requests made by a Session, see
+// Session.RequestMiddleware. It is called with the request about to be made
+// and next, which makes the request through the remaining middleware.
+// It may change req in place, e.g. set headers or replace its context with
+// *req = *req.WithContext(ctx), before calling next. Returning without
+// calling next skips the request, in which case a response or an error must
+// be returned. Errors are returned to the caller of the REST method as is.
+type RequestMiddleware func(req *http.Request, next func() (*http.Response, error)) (*http.Response, error)
+
+// doRequest makes an HTTP request with client through the RequestMiddleware of the session.
+func (s *Session) doRequest(client *http.Client, req *http.Request) (*http.Response, error) {
+	call := func() (*http.Response, error) {
+		return client.Do(req)
+	}
+
+	// The first middleware is the outermost one.
+	for i := len(s.RequestMiddleware) - 1; i >= 0; i-- {
+		middleware, next := s.RequestMiddleware[i], call
+		call = func() (*http.Response, error) {
+			return middleware(req, next)
+		}
+	}
+
+	resp, err := call()
+	if err == nil && resp == nil {
+		err = errors.New("request middleware returned neither a response nor an error")
+	}
+	return resp, err
+}
+
 // RequestOption is a function which mutates request configuration.
 // It can be supplied as an argument to any REST method.
 type RequestOption func(cfg *RequestConfig)
@@ -188,11 +297,45 @@ func (s *Session) request(method, urlStr, contentType string, b []byte, bucketID
 	if bucketID == "" {
 		bucketID = strings.SplitN(urlStr, "?", 2)[0]
 	}
-	return s.RequestWithLockedBucket(method, urlStr, contentType, b, s.Ratelimiter.LockBucket(bucketID), sequence, options...)
+	bucket, err := s.lockBucket(s.Ratelimiter.GetBucket(bucketID), urlStr)
+	if err != nil {
+		return
+	}
+	return s.RequestWithLockedBucket(method, urlStr, contentType, b, bucket, sequence, options...)
+}
+
+// lockBucket locks a bucket until a request can be made, or returns a
+// RateLimitError if that requires waiting longer than MaxRateLimitWait.
+func (s *Session) lockBucket(bucket *Bucket, urlStr string) (*Bucket, error) {
+	wait, ok := s.Ratelimiter.lockBucketObject(bucket, s.MaxRateLimitWait)
+	if !ok {
+		s.log(LogWarning, "rate limit wait of %v for %s exceeds MaxRateLimitWait, the bucket reset may be wrong", wait, urlStr)
+		return nil, &RateLimitError{&RateLimit{TooManyRequests: &TooManyRequests{Bucket: bucket.Key, RetryAfter: wait}, URL: urlStr}}
+	}
+	return bucket, nil
+}
+
+// apiVersion returns the API version used by the session.
+func (s *Session) apiVersion() string {
+	if s.APIVersion != "" {
+		return s.APIVersion
+	}
+	return APIVersion
+}
+
+// apiURL rewrites a URL of the default API base path, EndpointAPI,
+// to the base path of the API version used by the session.
+func (s *Session) apiURL(urlStr string) string {
+	if s.APIVersion == "" || !strings.HasPrefix(urlStr, EndpointAPI) {
+		return urlStr
+	}
+	return EndpointDiscord + "api/v" + s.APIVersion + "/" + strings.TrimPrefix(urlStr, EndpointAPI)
 }
 
 // RequestWithLockedBucket makes a request using a bucket that's already been locked
 func (s *Session) RequestWithLockedBucket(method, urlStr, contentType string, b []byte, bucket *Bucket, sequence int, options ...RequestOption) (response []byte, err error) {
+	urlStr = s.apiURL(urlStr)
+
 	if s.Debug {
 		log.Printf("API REQUEST %8s :: %s\n", method, urlStr)
 		log.Printf("API REQUEST  PAYLOAD :: [%s]\n", string(b))
@@ -219,6 +362,14 @@ func (s *Session) RequestWithLockedBucket(method, urlStr, contentType string, b
 	// TODO: Make a configurable static variable.
 	req.Header.Set("User-Agent", s.UserAgent)
 
+	var cachedResponse []byte
+	if s.ResponseCache != nil && method == "GET" {
+		if etag, body, ok := s.ResponseCache.get(urlStr); ok {
+			req.Header.Set("If-None-Match", etag)
+			cachedResponse = body
+		}
+	}
+
 	cfg := newRequestConfig(s, req)
 	for _, opt := range options {
 		opt(cfg)
@@ -231,7 +382,7 @@ func (s *Session) RequestWithLockedBucket(method, urlStr, contentType string, b
 		}
 	}
 
-	resp, err := cfg.Client.Do(req)
+	resp, err := s.doRequest(cfg.Client, req)
 	if err != nil {
 		bucket.Release(nil)
 		return
@@ -266,12 +417,17 @@ func (s *Session) RequestWithLockedBucket(method, urlStr, contentType string, b
 	case http.StatusOK:
 	case http.StatusCreated:
 	case http.StatusNoContent:
+	case http.StatusNotModified:
+		// Only sent in response to If-None-Match, the cached body is still valid.
+		response = cachedResponse
 	case http.StatusBadGateway:
 		// Retry sending request if possible
 		if sequence < cfg.MaxRestRetries {
 
 			s.log(LogInformational, "%s Failed (%s), Retrying...", urlStr, resp.Status)
-			response, err = s.RequestWithLockedBucket(method, urlStr, contentType, b, s.Ratelimiter.LockBucketObject(bucket), sequence+1, options...)
+			if bucket, err = s.lockBucket(bucket, urlStr); err == nil {
+				response, err = s.RequestWithLockedBucket(method, urlStr, contentType, b, bucket, sequence+1, options...)
+			}
 		} else {
 			err = fmt.Errorf("Exceeded Max retries HTTP %s, %s", resp.Status, response)
 		}
@@ -283,7 +439,12 @@ func (s *Session) RequestWithLockedBucket(method, urlStr, contentType string, b
 			return
 		}
 
-		if cfg.ShouldRetryOnRateLimit {
+		if rl.Code == ErrCodeThisActionCannotBePerformedDueToSlowmodeRateLimit {
+			err = &SlowmodeError{&RateLimit{TooManyRequests: &rl, URL: urlStr}}
+		} else if cfg.ShouldRetryOnRateLimit && s.MaxRateLimitWait > 0 && rl.RetryAfter > s.MaxRateLimitWait {
+			s.log(LogWarning, "rate limit retry after %v for %s exceeds MaxRateLimitWait", rl.RetryAfter, urlStr)
+			err = &RateLimitError{&RateLimit{TooManyRequests: &rl, URL: urlStr}}
+		} else if cfg.ShouldRetryOnRateLimit {
 			s.log(LogInformational, "Rate Limiting %s, retry in %v", urlStr, rl.RetryAfter)
 			s.handleEvent(rateLimitEventType, &RateLimit{TooManyRequests: &rl, URL: urlStr})
 
@@ -291,7 +452,9 @@ func (s *Session) RequestWithLockedBucket(method, urlStr, contentType string, b
 			// we can make the above smarter
 			// this method can cause longer delays than required
 
-			response, err = s.RequestWithLockedBucket(method, urlStr, contentType, b, s.Ratelimiter.LockBucketObject(bucket), sequence, options...)
+			if bucket, err = s.lockBucket(bucket, urlStr); err == nil {
+				response, err = s.RequestWithLockedBucket(method, urlStr, contentType, b, bucket, sequence, options...)
+			}
 		} else {
 			err = &RateLimitError{&RateLimit{TooManyRequests: &rl, URL: urlStr}}
 		}
@@ -305,9 +468,22 @@ func (s *Session) RequestWithLockedBucket(method, urlStr, contentType string, b
 		err = newRestError(req, resp, response)
 	}
 
+	if err == nil && s.ResponseCache != nil {
+		if method != "GET" {
+			s.ResponseCache.Invalidate(urlStr)
+		} else if etag := resp.Header.Get("ETag"); etag != "" && resp.StatusCode == http.StatusOK {
+			s.ResponseCache.put(urlStr, etag, response)
+		}
+	}
+
 	return
 }
 
+// preferState reports whether REST getters should consult the state first.
+func (s *Session) preferState() bool {
+	return s.StateEnabled && s.PreferState && s.stateStore() != nil
+}
+
 func unmarshal(data []byte, v interface{}) error {
 	err := Unmarshal(data, v)
 	if err != nil {
@@ -322,8 +498,15 @@ func unmarshal(data []byte, v interface{}) error {
 // ------------------------------------------------------------------------------------------------
 
 // User returns the user details of the given userID
+// With PreferState the user is looked up in State first, even when a Store is
+// configured, as StateStore has no lookup for users.
 // userID    : A user ID or "@me" which is a shortcut of current user ID
 func (s *Session) User(userID string, options ...RequestOption) (st *User, err error) {
+	if s.preferState() {
+		if st, err = s.State.user(userID); err == nil {
+			return
+		}
+	}
 
 	body, err := s.RequestWithBucketID("GET", EndpointUser(userID), nil, EndpointUsers, options...)
 	if err != nil {
@@ -334,6 +517,46 @@ func (s *Session) User(userID string, options ...RequestOption) (st *User, err e
 	return
 }
 
+// Users returns the users with the given IDs, keyed by ID. Users known to
+// State are returned from it, the others are fetched one at a time, as there
+// is no endpoint to fetch multiple users, paced by the rate limiter.
+// Only State is consulted, even when a Store is configured, as StateStore
+// has no lookup for users.
+// Unknown users are left out. On any other error the users resolved so far
+// are returned along with the error.
+// userIDs   : The IDs of the users, duplicates are only fetched once.
+func (s *Session) Users(userIDs []string, options ...RequestOption) (st map[string]*User, err error) {
+	st = make(map[string]*User, len(userIDs))
+	for _, userID := range userIDs {
+		if _, ok := st[userID]; ok {
+			continue
+		}
+
+		if s.StateEnabled {
+			if u, serr := s.State.user(userID); serr == nil {
+				st[userID] = u
+				continue
+			}
+		}
+
+		body, rerr := s.RequestWithBucketID("GET", EndpointUser(userID), nil, EndpointUsers, options...)
+		if rerr != nil {
+			var restErr *RESTError
+			if errors.As(rerr, &restErr) && restErr.Message != nil && restErr.Message.Code == ErrCodeUnknownUser {
+				continue
+			}
+			return st, rerr
+		}
+
+		var u *User
+		if err = unmarshal(body, &u); err != nil {
+			return
+		}
+		st[userID] = u
+	}
+	return
+}
+
 // UserAvatar is deprecated. Please use UserAvatarDecode
 // userID    : A user ID or "@me" which is a shortcut of current user ID
 func (s *Session) UserAvatar(userID string, options ...RequestOption) (img image.Image, err error) {
@@ -411,6 +634,56 @@ func (s *Session) UserChannelCreate(recipientID string, options ...RequestOption
 	return
 }
 
+// UserGroupChannelCreate creates a new Group (Private) Channel with other Users.
+// Bots cannot create group DMs this way, so ErrGroupDMBotToken is returned
+// when the session uses a bot token.
+// userIDs : The IDs of the users to add to the group DM.
+func (s *Session) UserGroupChannelCreate(userIDs []string, options ...RequestOption) (st *Channel, err error) {
+	if strings.Index(s.Token, "Bot ") == 0 {
+		return nil, ErrGroupDMBotToken
+	}
+
+	data := struct {
+		Recipients []string `json:"recipients"`
+	}{userIDs}
+
+	body, err := s.RequestWithBucketID("POST", EndpointUserChannels("@me"), data, EndpointUserChannels(""), options...)
+	if err != nil {
+		return
+	}
+
+	err = unmarshal(body, &st)
+	return
+}
+
+// ChannelRecipientAdd adds a user to a group DM.
+// Bots cannot manage group DM recipients this way, so ErrGroupDMBotToken
+// is returned when the session uses a bot token.
+// channelID : The ID of the group DM channel.
+// userID    : The ID of the user to add.
+func (s *Session) ChannelRecipientAdd(channelID, userID string, options ...RequestOption) (err error) {
+	if strings.Index(s.Token, "Bot ") == 0 {
+		return ErrGroupDMBotToken
+	}
+
+	_, err = s.RequestWithBucketID("PUT", EndpointChannelRecipient(channelID, userID), nil, EndpointChannelRecipient(channelID, ""), options...)
+	return
+}
+
+// ChannelRecipientRemove removes a user from a group DM.
+// Bots cannot manage group DM recipients this way, so ErrGroupDMBotToken
+// is returned when the session uses a bot token.
+// channelID : The ID of the group DM channel.
+// userID    : The ID of the user to remove.
+func (s *Session) ChannelRecipientRemove(channelID, userID string, options ...RequestOption) (err error) {
+	if strings.Index(s.Token, "Bot ") == 0 {
+		return ErrGroupDMBotToken
+	}
+
+	_, err = s.RequestWithBucketID("DELETE", EndpointChannelRecipient(channelID, userID), nil, EndpointChannelRecipient(channelID, ""), options...)
+	return
+}
+
 // UserGuildMember returns a guild member object for the current user in the given Guild.
 // guildID : ID of the guild
 func (s *Session) UserGuildMember(guildID string, options ...RequestOption) (st *Member, err error) {
@@ -471,16 +744,38 @@ func (s *Session) UserChannelPermissions(userID, channelID string, fetchOptions
 	}
 
 	// Otherwise try get as much data from state as possible, falling back to the network.
-	channel, err := s.State.Channel(channelID)
-	if err != nil || channel == nil {
+	store := s.stateStore()
+	var channel *Channel
+	if store != nil {
+		channel, _ = store.Channel(channelID)
+	}
+	if channel == nil {
 		channel, err = s.Channel(channelID, fetchOptions...)
 		if err != nil {
 			return
 		}
 	}
 
-	guild, err := s.State.Guild(channel.GuildID)
-	if err != nil || guild == nil {
+	// Threads inherit the permission overwrites of their parent channel.
+	if channel.IsThread() {
+		var parent *Channel
+		if store != nil {
+			parent, _ = store.Channel(channel.ParentID)
+		}
+		if parent == nil {
+			parent, err = s.Channel(channel.ParentID, fetchOptions...)
+			if err != nil {
+				return
+			}
+		}
+		channel = parent
+	}
+
+	var guild *Guild
+	if store != nil {
+		guild, _ = store.Guild(channel.GuildID)
+	}
+	if guild == nil {
 		guild, err = s.Guild(channel.GuildID, fetchOptions...)
 		if err != nil {
 			return
@@ -492,8 +787,11 @@ func (s *Session) UserChannelPermissions(userID, channelID string, fetchOptions
 		return
 	}
 
-	member, err := s.State.Member(guild.ID, userID)
-	if err != nil || member == nil {
+	var member *Member
+	if store != nil {
+		member, _ = store.Member(guild.ID, userID)
+	}
+	if member == nil {
 		member, err = s.GuildMember(guild.ID, userID, fetchOptions...)
 		if err != nil {
 			return
@@ -527,10 +825,14 @@ func memberPermissions(guild *Guild, channel *Channel, userID string, roles []st
 		}
 	}
 
+	// Administrators have all permissions, regardless of overwrites.
 	if apermissions&PermissionAdministrator == PermissionAdministrator {
-		apermissions |= PermissionAll
+		apermissions = PermissionAll
+		return
 	}
 
+	// Overwrites are applied in order of precedence: @everyone, then
+	// the combined role overwrites, then the member's own overwrite.
 	// Apply @everyone overrides from the channel.
 	for _, overwrite := range channel.PermissionOverwrites {
 		if guild.ID == overwrite.ID {
@@ -563,10 +865,6 @@ func memberPermissions(guild *Guild, channel *Channel, userID string, roles []st
 		}
 	}
 
-	if apermissions&PermissionAdministrator == PermissionAdministrator {
-		apermissions |= PermissionAllChannel
-	}
-
 	return apermissions
 }
 
@@ -577,6 +875,12 @@ func memberPermissions(guild *Guild, channel *Channel, userID string, roles []st
 // Guild returns a Guild structure of a specific Guild.
 // guildID   : The ID of a Guild
 func (s *Session) Guild(guildID string, options ...RequestOption) (st *Guild, err error) {
+	if s.preferState() {
+		if st, err = s.stateStore().Guild(guildID); err == nil {
+			return
+		}
+	}
+
 	body, err := s.RequestWithBucketID("GET", EndpointGuild(guildID), nil, EndpointGuild(guildID), options...)
 	if err != nil {
 		return
@@ -600,6 +904,7 @@ func (s *Session) GuildWithCounts(guildID string, options ...RequestOption) (st
 }
 
 // GuildPreview returns a GuildPreview structure of a specific public Guild.
+// The bot does not need to be a member of the guild if it is discoverable.
 // guildID   : The ID of a Guild
 func (s *Session) GuildPreview(guildID string, options ...RequestOption) (st *GuildPreview, err error) {
 	body, err := s.RequestWithBucketID("GET", EndpointGuildPreview(guildID), nil, EndpointGuildPreview(guildID), options...)
@@ -611,6 +916,37 @@ func (s *Session) GuildPreview(guildID string, options ...RequestOption) (st *Gu
 	return
 }
 
+// GuildVanityURL returns the vanity invite of a guild.
+// Requires the guild to have the VANITY_URL feature and the MANAGE_GUILD permission.
+// guildID   : The ID of a Guild
+func (s *Session) GuildVanityURL(guildID string, options ...RequestOption) (st *GuildVanityURL, err error) {
+	body, err := s.RequestWithBucketID("GET", EndpointGuildVanityURL(guildID), nil, EndpointGuildVanityURL(guildID), options...)
+	if err != nil {
+		return
+	}
+
+	err = unmarshal(body, &st)
+	return
+}
+
+// GuildVanityURLEdit changes the vanity invite code of a guild.
+// Requires the guild to have the VANITY_URL feature.
+// guildID   : The ID of a Guild
+// code      : The new vanity invite code
+func (s *Session) GuildVanityURLEdit(guildID, code string, options ...RequestOption) (st *GuildVanityURL, err error) {
+	data := struct {
+		Code string `json:"code"`
+	}{code}
+
+	body, err := s.RequestWithBucketID("PATCH", EndpointGuildVanityURL(guildID), data, EndpointGuildVanityURL(guildID), options...)
+	if err != nil {
+		return
+	}
+
+	err = unmarshal(body, &st)
+	return
+}
+
 // GuildCreate creates a new Guild
 // name      : A name for the Guild (2-100 characters)
 func (s *Session) GuildCreate(name string, options ...RequestOption) (st *Guild, err error) {
@@ -642,6 +978,24 @@ func (s *Session) GuildEdit(guildID string, g *GuildParams, options ...RequestOp
 		}
 	}
 
+	// Bounds checking for DefaultMessageNotifications, interval: [0, 1]
+	if g.DefaultMessageNotifications != nil {
+		val := *g.DefaultMessageNotifications
+		if val < MessageNotificationsAllMessages || val > MessageNotificationsOnlyMentions {
+			err = ErrMessageNotificationsBounds
+			return
+		}
+	}
+
+	// Bounds checking for ExplicitContentFilter, interval: [0, 2]
+	if g.ExplicitContentFilter != nil {
+		val := *g.ExplicitContentFilter
+		if val < ExplicitContentFilterDisabled || val > ExplicitContentFilterAllMembers {
+			err = ErrExplicitContentFilterBounds
+			return
+		}
+	}
+
 	// Bounds checking for regions
 	if g.Region != "" {
 		isValid := false
@@ -724,6 +1078,27 @@ func (s *Session) GuildBans(guildID string, limit int, beforeID, afterID string,
 	return
 }
 
+// GuildBansAll returns every ban of the given guild, paging through GuildBans
+// in ascending order of user ID until all bans have been fetched.
+// guildID   : The ID of a Guild
+func (s *Session) GuildBansAll(guildID string, options ...RequestOption) (st []*GuildBan, err error) {
+	afterID := ""
+	for {
+		var bans []*GuildBan
+		bans, err = s.GuildBans(guildID, 1000, "", afterID, options...)
+		if err != nil {
+			return
+		}
+
+		st = append(st, bans...)
+		if len(bans) < 1000 {
+			return
+		}
+
+		afterID = bans[len(bans)-1].User.ID
+	}
+}
+
 // GuildBanCreate bans the given user from the given guild.
 // guildID   : The ID of a Guild.
 // userID    : The ID of a User
@@ -770,6 +1145,41 @@ func (s *Session) GuildBanCreateWithReason(guildID, userID, reason string, days
 	return
 }
 
+// GuildBulkBanMaxUsers is the maximum number of users banned by GuildBansBulk at once.
+const GuildBulkBanMaxUsers = 200
+
+// GuildBansBulk bans up to GuildBulkBanMaxUsers users from the given guild at
+// once and returns the IDs of the users which were banned and of those which
+// could not be banned, e.g. because they were already banned. It requires the
+// BAN_MEMBERS and MANAGE_GUILD permissions. If no user could be banned, a
+// RESTError with the code ErrCodeFailedToBanUsers is returned.
+// guildID              : The ID of a Guild.
+// userIDs              : The IDs of the users to ban.
+// deleteMessageSeconds : The number of seconds of previous messages to delete, up to 7 days.
+func (s *Session) GuildBansBulk(guildID string, userIDs []string, deleteMessageSeconds int, options ...RequestOption) (banned, failed []string, err error) {
+	if len(userIDs) == 0 || len(userIDs) > GuildBulkBanMaxUsers {
+		err = ErrBulkBanUserCount
+		return
+	}
+
+	data := struct {
+		UserIDs              []string `json:"user_ids"`
+		DeleteMessageSeconds int      `json:"delete_message_seconds,omitempty"`
+	}{userIDs, deleteMessageSeconds}
+
+	body, err := s.RequestWithBucketID("POST", EndpointGuildBulkBan(guildID), data, EndpointGuildBulkBan(guildID), options...)
+	if err != nil {
+		return
+	}
+
+	var st struct {
+		BannedUsers []string `json:"banned_users"`
+		FailedUsers []string `json:"failed_users"`
+	}
+	err = unmarshal(body, &st)
+	return st.BannedUsers, st.FailedUsers, err
+}
+
 // GuildBanDelete removes the given user from the guild bans
 // guildID   : The ID of a Guild.
 // userID    : The ID of a User
@@ -837,6 +1247,11 @@ func (s *Session) GuildMembersSearch(guildID, query string, limit int, options .
 // guildID   : The ID of a Guild.
 // userID    : The ID of a User
 func (s *Session) GuildMember(guildID, userID string, options ...RequestOption) (st *Member, err error) {
+	if s.preferState() {
+		if st, err = s.stateStore().Member(guildID, userID); err == nil {
+			return
+		}
+	}
 
 	body, err := s.RequestWithBucketID("GET", EndpointGuildMember(guildID, userID), nil, EndpointGuildMember(guildID, ""), options...)
 	if err != nil {
@@ -914,11 +1329,15 @@ func (s *Session) GuildMemberEditComplex(guildID, userID string, data *GuildMemb
 // GuildMemberMove moves a guild member from one voice channel to another/none
 // guildID   : The ID of a Guild.
 // userID    : The ID of a User.
-// channelID : The ID of a channel to move user to or nil to remove from voice channel
+// channelID : The ID of a channel to move user to or nil to remove from voice channel, "" also removes the user
 //
 // NOTE : I am not entirely set on the name of this function and it may change
 // prior to the final 1.0.0 release of Discordgo
 func (s *Session) GuildMemberMove(guildID string, userID string, channelID *string, options ...RequestOption) (err error) {
+	if channelID != nil && *channelID == "" {
+		channelID = nil
+	}
+
 	data := struct {
 		ChannelID *string `json:"channel_id"`
 	}{channelID}
@@ -1034,9 +1453,29 @@ type GuildChannelCreateData struct {
 	PermissionOverwrites []*PermissionOverwrite `json:"permission_overwrites,omitempty"`
 	ParentID             string                 `json:"parent_id,omitempty"`
 	NSFW                 bool                   `json:"nsfw,omitempty"`
+
+	// NOTE: voice and stage channels only
+
+	// The voice region ID of the channel, automatic when empty.
+	RTCRegion string `json:"rtc_region,omitempty"`
+
+	// NOTE: text, announcement, forum and media channels only
+
+	// The default auto archive duration of threads in minutes.
+	DefaultAutoArchiveDuration int `json:"default_auto_archive_duration,omitempty"`
+	// The initial rate limit per user of threads, in seconds.
+	DefaultThreadRateLimitPerUser int `json:"default_thread_rate_limit_per_user,omitempty"`
+
+	// NOTE: forum and media channels only
+
+	AvailableTags        []ForumTag            `json:"available_tags,omitempty"`
+	DefaultReactionEmoji *ForumDefaultReaction `json:"default_reaction_emoji,omitempty"`
+	DefaultSortOrder     *ForumSortOrderType   `json:"default_sort_order,omitempty"`
+	DefaultForumLayout   ForumLayout           `json:"default_forum_layout,omitempty"`
 }
 
-// GuildChannelCreateComplex creates a new channel in the given guild
+// GuildChannelCreateComplex creates a new channel in the given guild, including
+// its permission overwrites, so that it never exists without them.
 // guildID      : The ID of a Guild
 // data         : A data struct describing the new Channel, Name and Type are mandatory, other fields depending on the type
 func (s *Session) GuildChannelCreateComplex(guildID string, data GuildChannelCreateData, options ...RequestOption) (st *Channel, err error) {
@@ -1109,6 +1548,10 @@ func (s *Session) GuildRoles(guildID string, options ...RequestOption) (st []*Ro
 // guildID : The ID of a Guild.
 // data    : New Role parameters.
 func (s *Session) GuildRoleCreate(guildID string, data *RoleParams, options ...RequestOption) (st *Role, err error) {
+	if data.hasIconAndEmoji() {
+		return nil, ErrRoleIconAndEmoji
+	}
+
 	body, err := s.RequestWithBucketID("POST", EndpointGuildRoles(guildID), data, EndpointGuildRoles(guildID), options...)
 	if err != nil {
 		return
@@ -1130,6 +1573,10 @@ func (s *Session) GuildRoleEdit(guildID, roleID string, data *RoleParams, option
 		return nil, fmt.Errorf("color value cannot be larger than 0xFFFFFF")
 	}
 
+	if data.hasIconAndEmoji() {
+		return nil, ErrRoleIconAndEmoji
+	}
+
 	body, err := s.RequestWithBucketID("PATCH", EndpointGuildRole(guildID, roleID), data, EndpointGuildRole(guildID, ""), options...)
 	if err != nil {
 		return
@@ -1419,6 +1866,7 @@ func (s *Session) GuildEmoji(guildID, emojiID string, options ...RequestOption)
 }
 
 // GuildEmojiCreate creates a new Emoji.
+// Use MessageFormat on the returned Emoji to mention it in messages.
 // guildID : The ID of a Guild.
 // data    : New Emoji data.
 func (s *Session) GuildEmojiCreate(guildID string, data *EmojiParams, options ...RequestOption) (emoji *Emoji, err error) {
@@ -1553,6 +2001,12 @@ func (s *Session) GuildTemplateDelete(guildID, templateCode string, options ...R
 // Channel returns a Channel structure of a specific Channel.
 // channelID  : The ID of the Channel you want returned.
 func (s *Session) Channel(channelID string, options ...RequestOption) (st *Channel, err error) {
+	if s.preferState() {
+		if st, err = s.stateStore().Channel(channelID); err == nil {
+			return
+		}
+	}
+
 	body, err := s.RequestWithBucketID("GET", EndpointChannel(channelID), nil, EndpointChannel(channelID), options...)
 	if err != nil {
 		return
@@ -1643,10 +2097,51 @@ func (s *Session) ChannelMessages(channelID string, limit int, beforeID, afterID
 	return
 }
 
+// ChannelMessagesSince returns up to limit messages sent in a channel after
+// the message lastSeenID, oldest first, paging through ChannelMessages.
+// channelID  : The ID of a Channel.
+// lastSeenID : The ID of the last message which has been read, or empty for none.
+// limit      : The max number of messages to return, or 0 for all of them.
+func (s *Session) ChannelMessagesSince(channelID, lastSeenID string, limit int, options ...RequestOption) (st []*Message, err error) {
+	afterID := lastSeenID
+	if afterID == "" {
+		afterID = "0"
+	}
+	for limit <= 0 || len(st) < limit {
+		page := 100
+		if limit > 0 && limit-len(st) < page {
+			page = limit - len(st)
+		}
+
+		var messages []*Message
+		messages, err = s.ChannelMessages(channelID, page, "", afterID, "", options...)
+		if err != nil {
+			return
+		}
+
+		// Messages are returned newest first.
+		sort.Slice(messages, func(i, j int) bool { return SnowflakeCompare(messages[i].ID, messages[j].ID) < 0 })
+		st = append(st, messages...)
+		if len(messages) < page {
+			break
+		}
+
+		afterID = messages[len(messages)-1].ID
+	}
+	return
+}
+
 // ChannelMessage gets a single message by ID from a given channel.
+// With PreferState the message cache of the state is consulted first, which
+// only holds the last State.MaxMessageCount messages of each channel.
 // channeld  : The ID of a Channel
 // messageID : the ID of a Message
 func (s *Session) ChannelMessage(channelID, messageID string, options ...RequestOption) (st *Message, err error) {
+	if s.preferState() {
+		if st, err = s.stateStore().Message(channelID, messageID); err == nil {
+			return
+		}
+	}
 
 	response, err := s.RequestWithBucketID("GET", EndpointChannelMessage(channelID, messageID), nil, EndpointChannelMessage(channelID, ""), options...)
 	if err != nil {
@@ -1668,6 +2163,94 @@ func (s *Session) ChannelMessageSend(channelID string, content string, options .
 
 var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
 
+// currentUserID returns the ID of the user the session is connected as, or
+// an empty string if it is not known yet.
+func (s *Session) currentUserID() string {
+	if s.State == nil {
+		return ""
+	}
+
+	s.State.RLock()
+	defer s.State.RUnlock()
+
+	if s.State.User == nil {
+		return ""
+	}
+	return s.State.User.ID
+}
+
+// checkChannelPermissions returns a PermissionError if CheckPermissions is
+// set and the state shows that the current user lacks any of the required
+// permissions in a guild channel. Permissions are not checked if any of the
+// data needed is missing from the state, including the current user before
+// the session is ready.
+func (s *Session) checkChannelPermissions(channelID string, required int64) error {
+	if !s.CheckPermissions {
+		return nil
+	}
+
+	store := s.stateStore()
+	userID := s.currentUserID()
+	if store == nil || userID == "" {
+		return nil
+	}
+
+	channel, err := store.Channel(channelID)
+	if err != nil || channel.GuildID == "" {
+		return nil
+	}
+
+	// Threads use the permissions of their parent channel, except
+	// for sending messages which has a permission of its own.
+	permChannel := channel
+	if channel.IsThread() {
+		permChannel, err = store.Channel(channel.ParentID)
+		if err != nil {
+			return nil
+		}
+		if required&PermissionSendMessages != 0 {
+			required = required&^PermissionSendMessages | PermissionSendMessagesInThreads
+		}
+	}
+
+	guild, err := store.Guild(channel.GuildID)
+	if err != nil {
+		return nil
+	}
+
+	member, err := store.Member(guild.ID, userID)
+	if err != nil {
+		return nil
+	}
+
+	perms := memberPermissions(guild, permChannel, userID, member.Roles)
+	if missing := required &^ perms; missing != 0 {
+		return &PermissionError{ChannelID: channelID, Missing: missing}
+	}
+	return nil
+}
+
+// channelMaxFileSize returns the maximum size of the files attached to a
+// message in a channel, based on the boost tier of its guild if it is known
+// to the state.
+func (s *Session) channelMaxFileSize(channelID string) int64 {
+	store := s.stateStore()
+	if store == nil {
+		return MaxFileSize
+	}
+
+	channel, err := store.Channel(channelID)
+	if err != nil || channel.GuildID == "" {
+		return MaxFileSize
+	}
+
+	guild, err := store.Guild(channel.GuildID)
+	if err != nil {
+		return MaxFileSize
+	}
+	return maxFileSize(guild.PremiumTier)
+}
+
 // ChannelMessageSendComplex sends a message to the given channel.
 // channelID : The ID of a Channel.
 // data      : The message struct to send.
@@ -1687,6 +2270,12 @@ func (s *Session) ChannelMessageSendComplex(channelID string, data *MessageSend,
 			embed.Type = "rich"
 		}
 	}
+	if data.AllowedMentions == nil && s.DefaultAllowedMentions != nil {
+		// Apply the default to a copy, leaving the caller's data unchanged.
+		withDefault := *data
+		withDefault.AllowedMentions = s.DefaultAllowedMentions
+		data = &withDefault
+	}
 	endpoint := EndpointChannelMessages(channelID)
 
 	// TODO: Remove this when compatibility is not required.
@@ -1707,8 +2296,29 @@ func (s *Session) ChannelMessageSendComplex(channelID string, data *MessageSend,
 		}
 	}
 
+	if data.Flags&MessageFlagsIsComponentsV2 != 0 && (data.Content != "" || len(data.Embeds) > 0) {
+		err = errors.New("cannot specify Content or Embeds with MessageFlagsIsComponentsV2")
+		return
+	}
+
+	required := int64(PermissionViewChannel | PermissionSendMessages)
+	if len(data.Embeds) > 0 {
+		required |= PermissionEmbedLinks
+	}
+	if len(files) > 0 {
+		required |= PermissionAttachFiles
+	}
+	if err = s.checkChannelPermissions(channelID, required); err != nil {
+		return
+	}
+
 	var response []byte
 	if len(files) > 0 {
+		files, err = readFiles(files, s.channelMaxFileSize(channelID))
+		if err != nil {
+			return
+		}
+
 		contentType, body, encodeErr := MultipartBodyWithJSON(data, files)
 		if encodeErr != nil {
 			return st, encodeErr
@@ -1907,6 +2517,8 @@ func (s *Session) ChannelMessageUnpin(channelID, messageID string, options ...Re
 // ChannelMessagesPinned returns an array of Message structures for pinned messages
 // within a given channel
 // channelID : The ID of a Channel.
+// NOTE: this endpoint returns at most 50 messages and no pin timestamps,
+// use ChannelMessagePins or ChannelPinsAll instead.
 func (s *Session) ChannelMessagesPinned(channelID string, options ...RequestOption) (st []*Message, err error) {
 
 	body, err := s.RequestWithBucketID("GET", EndpointChannelMessagesPins(channelID), nil, EndpointChannelMessagesPins(channelID), options...)
@@ -1919,6 +2531,55 @@ func (s *Session) ChannelMessagesPinned(channelID string, options ...RequestOpti
 	return
 }
 
+// ChannelMessagePins returns a page of pinned messages, newest first, along with their pin timestamps.
+// channelID : The ID of a Channel.
+// before    : If provided only messages pinned before this time are returned.
+// limit     : Max number of pins to return (1-50), 0 for the default.
+func (s *Session) ChannelMessagePins(channelID string, before *time.Time, limit int, options ...RequestOption) (st *MessagePinsList, err error) {
+	uri := EndpointChannelMessagesPinsList(channelID)
+
+	v := url.Values{}
+	if before != nil {
+		v.Set("before", before.Format(time.RFC3339Nano))
+	}
+	if limit > 0 {
+		v.Set("limit", strconv.Itoa(limit))
+	}
+	if len(v) > 0 {
+		uri += "?" + v.Encode()
+	}
+
+	body, err := s.RequestWithBucketID("GET", uri, nil, EndpointChannelMessagesPinsList(channelID), options...)
+	if err != nil {
+		return
+	}
+
+	err = unmarshal(body, &st)
+	return
+}
+
+// ChannelPinsAll returns every pinned message of a channel, newest first,
+// following the pagination of ChannelMessagePins until it is exhausted.
+// channelID : The ID of a Channel.
+func (s *Session) ChannelPinsAll(channelID string, options ...RequestOption) (st []*MessagePin, err error) {
+	var before *time.Time
+	for {
+		var page *MessagePinsList
+		page, err = s.ChannelMessagePins(channelID, before, 50, options...)
+		if err != nil {
+			return
+		}
+
+		st = append(st, page.Items...)
+		if !page.HasMore || len(page.Items) == 0 {
+			return
+		}
+
+		last := page.Items[len(page.Items)-1].PinnedAt
+		before = &last
+	}
+}
+
 // ChannelFileSend sends a file to the given channel.
 // channelID : The ID of a Channel.
 // name: The name of the file.
@@ -1952,17 +2613,46 @@ func (s *Session) ChannelInvites(channelID string, options ...RequestOption) (st
 
 // ChannelInviteCreate creates a new invite for the given channel.
 // channelID   : The ID of a Channel
-// i           : An Invite struct with the values MaxAge, MaxUses and Temporary defined.
+// i           : An Invite struct with the values MaxAge (in seconds), MaxUses, Temporary and Unique defined,
+// and optionally TargetType with TargetUser or TargetApplication.
 func (s *Session) ChannelInviteCreate(channelID string, i Invite, options ...RequestOption) (st *Invite, err error) {
+	data := &InviteParams{
+		MaxAge:     time.Duration(i.MaxAge) * time.Second,
+		MaxUses:    i.MaxUses,
+		Temporary:  i.Temporary,
+		Unique:     i.Unique,
+		TargetType: i.TargetType,
+	}
+	if i.TargetUser != nil {
+		data.TargetUserID = i.TargetUser.ID
+	}
+	if i.TargetApplication != nil {
+		data.TargetApplicationID = i.TargetApplication.ID
+	}
 
-	data := struct {
-		MaxAge    int  `json:"max_age"`
-		MaxUses   int  `json:"max_uses"`
-		Temporary bool `json:"temporary"`
-		Unique    bool `json:"unique"`
-	}{i.MaxAge, i.MaxUses, i.Temporary, i.Unique}
+	return s.ChannelInviteCreateComplex(channelID, data, options...)
+}
 
-	body, err := s.RequestWithBucketID("POST", EndpointChannelInvites(channelID), data, EndpointChannelInvites(channelID), options...)
+// ChannelInviteCreateComplex creates a new invite for the given channel.
+// It returns ErrInviteMaxAgeBounds if data.MaxAge is negative or longer than InviteMaxAge.
+// channelID   : The ID of a Channel
+// data        : The parameters of the invite.
+func (s *Session) ChannelInviteCreateComplex(channelID string, data *InviteParams, options ...RequestOption) (st *Invite, err error) {
+	if data.MaxAge < 0 || data.MaxAge > InviteMaxAge {
+		return nil, ErrInviteMaxAgeBounds
+	}
+
+	payload := struct {
+		MaxAge              int              `json:"max_age"`
+		MaxUses             int              `json:"max_uses"`
+		Temporary           bool             `json:"temporary"`
+		Unique              bool             `json:"unique"`
+		TargetType          InviteTargetType `json:"target_type,omitempty"`
+		TargetUserID        string           `json:"target_user_id,omitempty"`
+		TargetApplicationID string           `json:"target_application_id,omitempty"`
+	}{int(data.MaxAge / time.Second), data.MaxUses, data.Temporary, data.Unique, data.TargetType, data.TargetUserID, data.TargetApplicationID}
+
+	body, err := s.RequestWithBucketID("POST", EndpointChannelInvites(channelID), payload, EndpointChannelInvites(channelID), options...)
 	if err != nil {
 		return
 	}
@@ -1987,6 +2677,43 @@ func (s *Session) ChannelPermissionSet(channelID, targetID string, targetType Pe
 	return
 }
 
+// ChannelPermissionsSyncToParent replaces the permission overwrites of a
+// channel with the ones of its parent category, which Discord shows as the
+// channel's permissions being synced. It returns the updated channel, or
+// ErrChannelNoParent if the channel is not in a category.
+// channelID : The ID of a Channel
+func (s *Session) ChannelPermissionsSyncToParent(channelID string, options ...RequestOption) (st *Channel, err error) {
+	ch, err := s.Channel(channelID, options...)
+	if err != nil {
+		return
+	}
+	if ch.ParentID == "" {
+		return nil, ErrChannelNoParent
+	}
+
+	parent, err := s.Channel(ch.ParentID, options...)
+	if err != nil {
+		return
+	}
+	if !parent.IsCategory() {
+		return nil, ErrChannelNoParent
+	}
+
+	// Not omitted when empty, so that a category without overwrites clears the ones of the channel.
+	data := struct {
+		PermissionOverwrites []*PermissionOverwrite `json:"permission_overwrites"`
+	}{[]*PermissionOverwrite{}}
+	data.PermissionOverwrites = append(data.PermissionOverwrites, parent.PermissionOverwrites...)
+
+	body, err := s.RequestWithBucketID("PATCH", EndpointChannel(channelID), data, EndpointChannel(channelID), options...)
+	if err != nil {
+		return
+	}
+
+	err = unmarshal(body, &st)
+	return
+}
+
 // ChannelPermissionDelete deletes a specific permission override for the given channel.
 // NOTE: Name of this func may change.
 func (s *Session) ChannelPermissionDelete(channelID, targetID string, options ...RequestOption) (err error) {
@@ -1996,7 +2723,10 @@ func (s *Session) ChannelPermissionDelete(channelID, targetID string, options ..
 }
 
 // ChannelMessageCrosspost cross posts a message in a news channel to followers
-// of the channel
+// of the channel and returns the updated message.
+// Crossposting messages of others requires the MANAGE_MESSAGES permission,
+// the bot's own messages only require SEND_MESSAGES. Failures are returned as
+// a *RESTError, e.g. with ErrCodeMessageAlreadyCrossposted.
 // channelID   : The ID of a Channel
 // messageID   : The ID of a Message
 func (s *Session) ChannelMessageCrosspost(channelID, messageID string, options ...RequestOption) (st *Message, err error) {
@@ -2186,6 +2916,8 @@ func (s *Session) GatewayBot(options ...RequestOption) (st *GatewayBotResponse,
 		st.URL += "/"
 	}
 
+	s.sessionStartLimit.set(st.SessionStartLimit)
+
 	return
 }
 
@@ -2194,7 +2926,8 @@ func (s *Session) GatewayBot(options ...RequestOption) (st *GatewayBotResponse,
 // WebhookCreate returns a new Webhook.
 // channelID: The ID of a Channel.
 // name     : The name of the webhook.
-// avatar   : The avatar of the webhook.
+// avatar   : The avatar of the webhook as a data URI, e.g. "data:image/png;base64,...".
+// Use WebhookCreateWithAvatar to create a webhook with an avatar from raw image data.
 func (s *Session) WebhookCreate(channelID, name, avatar string, options ...RequestOption) (st *Webhook, err error) {
 
 	data := struct {
@@ -2212,6 +2945,19 @@ func (s *Session) WebhookCreate(channelID, name, avatar string, options ...Reque
 	return
 }
 
+// WebhookCreateWithAvatar returns a new Webhook with an avatar given as raw
+// image data, which is encoded as a data URI of the detected image type.
+// channelID: The ID of a Channel.
+// name     : The name of the webhook.
+// avatar   : The PNG, JPEG or GIF image data of the avatar, nil for the default avatar.
+func (s *Session) WebhookCreateWithAvatar(channelID, name string, avatar []byte, options ...RequestOption) (st *Webhook, err error) {
+	var dataURI string
+	if len(avatar) > 0 {
+		dataURI = imageDataURI(avatar)
+	}
+	return s.WebhookCreate(channelID, name, dataURI, options...)
+}
+
 // ChannelWebhooks returns all webhooks for a given channel.
 // channelID: The ID of a channel.
 func (s *Session) ChannelWebhooks(channelID string, options ...RequestOption) (st []*Webhook, err error) {
@@ -2352,6 +3098,13 @@ func (s *Session) webhookExecute(webhookID, token string, wait bool, threadID st
 		uri += "?" + v.Encode()
 	}
 
+	if data.AllowedMentions == nil && s.DefaultAllowedMentions != nil {
+		// Apply the default to a copy, leaving the caller's data unchanged.
+		withDefault := *data
+		withDefault.AllowedMentions = s.DefaultAllowedMentions
+		data = &withDefault
+	}
+
 	var response []byte
 	if len(data.Files) > 0 {
 		contentType, body, encodeErr := MultipartBodyWithJSON(data, data.Files)
@@ -2388,12 +3141,30 @@ func (s *Session) WebhookThreadExecute(webhookID, token string, wait bool, threa
 	return s.webhookExecute(webhookID, token, wait, threadID, data, options...)
 }
 
+// webhookMessageURI returns the endpoint of a webhook message, in a thread if threadID is set.
+func webhookMessageURI(webhookID, token, messageID, threadID string) string {
+	uri := EndpointWebhookMessage(webhookID, token, messageID)
+	if threadID != "" {
+		uri += "?thread_id=" + url.QueryEscape(threadID)
+	}
+	return uri
+}
+
 // WebhookMessage gets a webhook message.
 // webhookID : The ID of a webhook
 // token     : The auth token for the webhook
 // messageID : The ID of message to get
 func (s *Session) WebhookMessage(webhookID, token, messageID string, options ...RequestOption) (message *Message, err error) {
-	uri := EndpointWebhookMessage(webhookID, token, messageID)
+	return s.WebhookThreadMessage(webhookID, token, messageID, "", options...)
+}
+
+// WebhookThreadMessage gets a webhook message in a thread.
+// webhookID : The ID of a webhook
+// token     : The auth token for the webhook
+// messageID : The ID of message to get
+// threadID  : The ID of the thread within the webhook's channel the message is in
+func (s *Session) WebhookThreadMessage(webhookID, token, messageID, threadID string, options ...RequestOption) (message *Message, err error) {
+	uri := webhookMessageURI(webhookID, token, messageID, threadID)
 
 	body, err := s.RequestWithBucketID("GET", uri, nil, EndpointWebhookToken("", ""), options...)
 	if err != nil {
@@ -2410,16 +3181,25 @@ func (s *Session) WebhookMessage(webhookID, token, messageID string, options ...
 // token     : The auth token for the webhook
 // messageID : The ID of message to edit
 func (s *Session) WebhookMessageEdit(webhookID, token, messageID string, data *WebhookEdit, options ...RequestOption) (st *Message, err error) {
-	uri := EndpointWebhookMessage(webhookID, token, messageID)
+	return s.WebhookThreadMessageEdit(webhookID, token, messageID, "", data, options...)
+}
+
+// WebhookThreadMessageEdit edits a webhook message in a thread and returns a new one.
+// webhookID : The ID of a webhook
+// token     : The auth token for the webhook
+// messageID : The ID of message to edit
+// threadID  : The ID of the thread within the webhook's channel the message is in
+func (s *Session) WebhookThreadMessageEdit(webhookID, token, messageID, threadID string, data *WebhookEdit, options ...RequestOption) (st *Message, err error) {
+	uri := webhookMessageURI(webhookID, token, messageID, threadID)
 
 	var response []byte
 	if len(data.Files) > 0 {
-		contentType, body, err := MultipartBodyWithJSON(data, data.Files)
+		contentType, body, err := MultipartBodyWithJSON(webhookEditPayload(data), data.Files)
 		if err != nil {
 			return nil, err
 		}
 
-		response, err = s.request("PATCH", uri, contentType, body, uri, 0, options...)
+		response, err = s.request("PATCH", uri, contentType, body, EndpointWebhookToken("", ""), 0, options...)
 		if err != nil {
 			return nil, err
 		}
@@ -2435,12 +3215,48 @@ func (s *Session) WebhookMessageEdit(webhookID, token, messageID string, data *W
 	return
 }
 
+// webhookEditPayload returns the JSON payload of a WebhookEdit uploading
+// files. If the attachments to retain are given, the uploaded files are
+// added to them by their index, as otherwise Discord would remove them.
+func webhookEditPayload(data *WebhookEdit) interface{} {
+	if data.Attachments == nil {
+		return data
+	}
+
+	type uploadedAttachment struct {
+		ID       int    `json:"id"`
+		Filename string `json:"filename"`
+	}
+
+	attachments := make([]interface{}, 0, len(*data.Attachments)+len(data.Files))
+	for _, a := range *data.Attachments {
+		attachments = append(attachments, a)
+	}
+	for i, file := range data.Files {
+		attachments = append(attachments, uploadedAttachment{ID: i, Filename: file.Name})
+	}
+
+	return struct {
+		*WebhookEdit
+		Attachments []interface{} `json:"attachments"`
+	}{data, attachments}
+}
+
 // WebhookMessageDelete deletes a webhook message.
 // webhookID : The ID of a webhook
 // token     : The auth token for the webhook
 // messageID : The ID of a message to edit
 func (s *Session) WebhookMessageDelete(webhookID, token, messageID string, options ...RequestOption) (err error) {
-	uri := EndpointWebhookMessage(webhookID, token, messageID)
+	return s.WebhookThreadMessageDelete(webhookID, token, messageID, "", options...)
+}
+
+// WebhookThreadMessageDelete deletes a webhook message in a thread.
+// webhookID : The ID of a webhook
+// token     : The auth token for the webhook
+// messageID : The ID of a message to delete
+// threadID  : The ID of the thread within the webhook's channel the message is in
+func (s *Session) WebhookThreadMessageDelete(webhookID, token, messageID, threadID string, options ...RequestOption) (err error) {
+	uri := webhookMessageURI(webhookID, token, messageID, threadID)
 
 	_, err = s.RequestWithBucketID("DELETE", uri, nil, EndpointWebhookToken("", ""), options...)
 	return
@@ -2504,12 +3320,29 @@ func (s *Session) MessageReactionsRemoveEmoji(channelID, messageID, emojiID stri
 // beforeID  : If provided all reactions returned will be before given ID.
 // afterID   : If provided all reactions returned will be after given ID.
 func (s *Session) MessageReactions(channelID, messageID, emojiID string, limit int, beforeID, afterID string, options ...RequestOption) (st []*User, err error) {
+	return s.MessageReactionsByType(channelID, messageID, emojiID, ReactionTypeNormal, limit, beforeID, afterID, options...)
+}
+
+// MessageReactionsByType gets the users reactions of a type for a specific emoji,
+// e.g. the users who super reacted with ReactionTypeBurst.
+// channelID    : The channel ID.
+// messageID    : The message ID.
+// emojiID      : Either the unicode emoji for the reaction, or a guild emoji identifier.
+// reactionType : The type of the reactions.
+// limit        : max number of users to return (max 100)
+// beforeID     : If provided all reactions returned will be before given ID.
+// afterID      : If provided all reactions returned will be after given ID.
+func (s *Session) MessageReactionsByType(channelID, messageID, emojiID string, reactionType ReactionType, limit int, beforeID, afterID string, options ...RequestOption) (st []*User, err error) {
 	// emoji such as  #⃣ need to have # escaped
 	emojiID = strings.Replace(emojiID, "#", "%23", -1)
 	uri := EndpointMessageReactions(channelID, messageID, emojiID)
 
 	v := url.Values{}
 
+	if reactionType != ReactionTypeNormal {
+		v.Set("type", strconv.Itoa(int(reactionType)))
+	}
+
 	if limit > 0 {
 		v.Set("limit", strconv.Itoa(limit))
 	}
@@ -2534,6 +3367,57 @@ func (s *Session) MessageReactions(channelID, messageID, emojiID string, limit i
 	return
 }
 
+// MessageReactedByMe returns whether the current user has reacted to a message with an emoji.
+// The message is always fetched from the API, as reactions are not tracked by the state.
+// channelID : The channel ID.
+// messageID : The message ID.
+// emojiID   : Either the unicode emoji for the reaction, or a guild emoji identifier in name:id format (e.g. "hello:1234567654321")
+func (s *Session) MessageReactedByMe(channelID, messageID, emojiID string, options ...RequestOption) (reacted bool, err error) {
+	body, err := s.RequestWithBucketID("GET", EndpointChannelMessage(channelID, messageID), nil, EndpointChannelMessage(channelID, ""), options...)
+	if err != nil {
+		return
+	}
+
+	var m Message
+	err = unmarshal(body, &m)
+	if err != nil {
+		return
+	}
+
+	key := emojiKey(emojiID)
+	for _, r := range m.Reactions {
+		if r.Me && r.Emoji != nil && emojiKey(r.Emoji.APIName()) == key {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// MessageReactionToggle adds the current user's reaction with an emoji to a
+// message, or removes it if the current user has already reacted.
+// It returns whether the reaction is present afterwards.
+// NOTE: The check and the change are separate requests, so concurrent
+// toggles of the same reaction may race with each other.
+// channelID : The channel ID.
+// messageID : The message ID.
+// emojiID   : Either the unicode emoji for the reaction, or a guild emoji identifier in name:id format (e.g. "hello:1234567654321")
+func (s *Session) MessageReactionToggle(channelID, messageID, emojiID string, options ...RequestOption) (reacted bool, err error) {
+	reacted, err = s.MessageReactedByMe(channelID, messageID, emojiID, options...)
+	if err != nil {
+		return
+	}
+
+	if reacted {
+		err = s.MessageReactionRemove(channelID, messageID, emojiID, "@me", options...)
+	} else {
+		err = s.MessageReactionAdd(channelID, messageID, emojiID, options...)
+	}
+	if err != nil {
+		return
+	}
+	return !reacted, nil
+}
+
 // ------------------------------------------------------------------------------------------------
 // Functions specific to threads
 // ------------------------------------------------------------------------------------------------
@@ -2615,6 +3499,12 @@ func (s *Session) ForumThreadStartComplex(channelID string, threadData *ThreadSt
 			embed.Type = "rich"
 		}
 	}
+	if messageData.AllowedMentions == nil && s.DefaultAllowedMentions != nil {
+		// Apply the default to a copy, leaving the caller's data unchanged.
+		withDefault := *messageData
+		withDefault.AllowedMentions = s.DefaultAllowedMentions
+		messageData = &withDefault
+	}
 
 	// TODO: Remove this when compatibility is not required.
 	files := messageData.Files
@@ -2717,9 +3607,23 @@ func (s *Session) ThreadMemberRemove(threadID, memberID string, options ...Reque
 
 // ThreadMember returns thread member object for the specified member of a thread
 func (s *Session) ThreadMember(threadID, memberID string, options ...RequestOption) (member *ThreadMember, err error) {
-	endpoint := EndpointThreadMember(threadID, memberID)
+	return s.threadMember(threadID, memberID, false, options...)
+}
+
+// ThreadMemberWithMember returns thread member object for the specified member
+// of a thread, including its guild member object.
+func (s *Session) ThreadMemberWithMember(threadID, memberID string, options ...RequestOption) (member *ThreadMember, err error) {
+	return s.threadMember(threadID, memberID, true, options...)
+}
+
+func (s *Session) threadMember(threadID, memberID string, withMember bool, options ...RequestOption) (member *ThreadMember, err error) {
+	uri := EndpointThreadMember(threadID, memberID)
+	if withMember {
+		uri += "?with_member=true"
+	}
+
 	var body []byte
-	body, err = s.RequestWithBucketID("GET", endpoint, nil, endpoint, options...)
+	body, err = s.RequestWithBucketID("GET", uri, nil, EndpointThreadMember(threadID, ""), options...)
 
 	if err != nil {
 		return
@@ -2729,10 +3633,31 @@ func (s *Session) ThreadMember(threadID, memberID string, options ...RequestOpti
 	return
 }
 
-// ThreadMembers returns all members of specified thread.
-func (s *Session) ThreadMembers(threadID string, options ...RequestOption) (members []*ThreadMember, err error) {
+// ThreadMembers returns members of specified thread.
+// limit      : Max number of thread members to return (1-100). Defaults to 100.
+// afterID    : Get thread members after this user ID.
+// withMember : Whether to include a guild member object for each thread member.
+// NOTE: Pagination is only supported when withMember is true.
+func (s *Session) ThreadMembers(threadID string, limit int, withMember bool, afterID string, options ...RequestOption) (members []*ThreadMember, err error) {
+	uri := EndpointThreadMembers(threadID)
+
+	queryParams := url.Values{}
+	if withMember {
+		queryParams.Set("with_member", "true")
+	}
+	if limit > 0 {
+		queryParams.Set("limit", strconv.Itoa(limit))
+	}
+	if afterID != "" {
+		queryParams.Set("after", afterID)
+	}
+
+	if len(queryParams) > 0 {
+		uri += "?" + queryParams.Encode()
+	}
+
 	var body []byte
-	body, err = s.RequestWithBucketID("GET", EndpointThreadMembers(threadID), nil, EndpointThreadMembers(threadID), options...)
+	body, err = s.RequestWithBucketID("GET", uri, nil, EndpointThreadMembers(threadID), options...)
 
 	if err != nil {
 		return
@@ -2754,7 +3679,10 @@ func (s *Session) ThreadsActive(channelID string, options ...RequestOption) (thr
 	return
 }
 
-// GuildThreadsActive returns all active threads for specified guild.
+// GuildThreadsActive returns all active threads for specified guild, along with
+// the thread members of the current user, which are also set as the Member of
+// their threads. Private threads are only included if the current user can view them.
+// NOTE: the list is never paginated, HasMore is always false.
 func (s *Session) GuildThreadsActive(guildID string, options ...RequestOption) (threads *ThreadsList, err error) {
 	var body []byte
 	body, err = s.RequestWithBucketID("GET", EndpointGuildActiveThreads(guildID), nil, EndpointGuildActiveThreads(guildID), options...)
@@ -2853,10 +3781,15 @@ func (s *Session) ThreadsPrivateJoinedArchived(channelID string, before *time.Ti
 // ------------------------------------------------------------------------------------------------
 
 // ApplicationCommandCreate creates a global application command and returns it.
+// The command is checked with Validate before making the request.
 // appID       : The application ID.
 // guildID     : Guild ID to create guild-specific application command. If empty - creates global application command.
 // cmd         : New application command data.
 func (s *Session) ApplicationCommandCreate(appID string, guildID string, cmd *ApplicationCommand, options ...RequestOption) (ccmd *ApplicationCommand, err error) {
+	if err = cmd.Validate(); err != nil {
+		return
+	}
+
 	endpoint := EndpointApplicationGlobalCommands(appID)
 	if guildID != "" {
 		endpoint = EndpointApplicationGuildCommands(appID, guildID)
@@ -2873,11 +3806,20 @@ func (s *Session) ApplicationCommandCreate(appID string, guildID string, cmd *Ap
 }
 
 // ApplicationCommandEdit edits application command and returns new command data.
+// The name, description and options which are set are checked like Validate
+// does before making the request; unset fields are left to Discord.
 // appID       : The application ID.
 // cmdID       : Application command ID to edit.
 // guildID     : Guild ID to edit guild-specific application command. If empty - edits global application command.
 // cmd         : Updated application command data.
 func (s *Session) ApplicationCommandEdit(appID, guildID, cmdID string, cmd *ApplicationCommand, options ...RequestOption) (updated *ApplicationCommand, err error) {
+	if cmd == nil {
+		return nil, fmt.Errorf("%w: nil command", ErrInvalidApplicationCommand)
+	}
+	if err = cmd.validateEdit(); err != nil {
+		return
+	}
+
 	endpoint := EndpointApplicationGlobalCommand(appID, cmdID)
 	if guildID != "" {
 		endpoint = EndpointApplicationGuildCommand(appID, guildID, cmdID)
@@ -2894,9 +3836,16 @@ func (s *Session) ApplicationCommandEdit(appID, guildID, cmdID string, cmd *Appl
 }
 
 // ApplicationCommandBulkOverwrite Creates commands overwriting existing commands. Returns a list of commands.
+// Each command is checked with Validate before making the request.
 // appID    : The application ID.
 // commands : The commands to create.
 func (s *Session) ApplicationCommandBulkOverwrite(appID string, guildID string, commands []*ApplicationCommand, options ...RequestOption) (createdCommands []*ApplicationCommand, err error) {
+	for _, cmd := range commands {
+		if err = cmd.Validate(); err != nil {
+			return
+		}
+	}
+
 	endpoint := EndpointApplicationGlobalCommands(appID)
 	if guildID != "" {
 		endpoint = EndpointApplicationGuildCommands(appID, guildID)
@@ -2912,6 +3861,135 @@ func (s *Session) ApplicationCommandBulkOverwrite(appID string, guildID string,
 	return
 }
 
+// ApplicationCommandBulkOverwriteGuilds overwrites the commands of each of the
+// given guilds, one guild after another so the requests are paced by the rate
+// limiter. It returns the created commands keyed by guild ID. If any guild
+// fails, the remaining guilds are still processed and a GuildErrors is returned.
+// appID    : The application ID.
+// guildIDs : The IDs of the guilds to overwrite the commands of.
+// commands : The commands to create.
+func (s *Session) ApplicationCommandBulkOverwriteGuilds(appID string, guildIDs []string, commands []*ApplicationCommand, options ...RequestOption) (createdCommands map[string][]*ApplicationCommand, err error) {
+	createdCommands = make(map[string][]*ApplicationCommand, len(guildIDs))
+	errs := GuildErrors{}
+	for _, guildID := range guildIDs {
+		// An empty guild ID would overwrite the global commands instead.
+		if guildID == "" {
+			errs[guildID] = errors.New("guild ID must not be empty")
+			continue
+		}
+
+		created, cerr := s.ApplicationCommandBulkOverwrite(appID, guildID, commands, options...)
+		if cerr != nil {
+			errs[guildID] = cerr
+			continue
+		}
+		createdCommands[guildID] = created
+	}
+
+	if len(errs) > 0 {
+		err = errs
+	}
+	return
+}
+
+// ApplicationCommandLayout is the desired set of commands of an application
+// in every scope, see ApplicationCommandsDeleteStale.
+type ApplicationCommandLayout struct {
+	// The desired global commands.
+	Global []*ApplicationCommand
+	// The desired commands keyed by guild ID. A guild without commands
+	// has all of its commands removed.
+	Guilds map[string][]*ApplicationCommand
+}
+
+// applicationCommandKey identifies a command within its scope, as commands
+// of different types may share a name.
+func applicationCommandKey(cmd *ApplicationCommand) string {
+	t := cmd.Type
+	if t == 0 {
+		t = ChatApplicationCommand
+	}
+	return strconv.Itoa(int(t)) + ":" + cmd.Name
+}
+
+// ApplicationCommandsDeleteStale fetches the current commands of each scope
+// of the layout, the global scope and each of its guilds, and deletes the
+// commands which are not desired in that scope anymore, such as a guild
+// command which has been made global or renamed. Commands are matched by
+// name and type. Register the desired commands with
+// ApplicationCommandBulkOverwrite or ApplicationCommandCreate.
+//
+// It returns the deleted commands keyed by guild ID, with the global
+// commands keyed by an empty ID. If the global commands fail the guilds are
+// not processed, if a guild fails the remaining guilds are still processed
+// and a GuildErrors is returned.
+// appID  : The application ID.
+// layout : The desired commands.
+func (s *Session) ApplicationCommandsDeleteStale(appID string, layout *ApplicationCommandLayout, options ...RequestOption) (deleted map[string][]*ApplicationCommand, err error) {
+	deleted = make(map[string][]*ApplicationCommand)
+
+	removed, err := s.applicationCommandsDeleteStale(appID, "", layout.Global, options...)
+	if len(removed) > 0 {
+		deleted[""] = removed
+	}
+	if err != nil {
+		return
+	}
+
+	guildIDs := make([]string, 0, len(layout.Guilds))
+	for guildID := range layout.Guilds {
+		guildIDs = append(guildIDs, guildID)
+	}
+	sort.Strings(guildIDs)
+
+	errs := GuildErrors{}
+	for _, guildID := range guildIDs {
+		// An empty guild ID would delete the global commands instead.
+		if guildID == "" {
+			errs[guildID] = errors.New("guild ID must not be empty")
+			continue
+		}
+
+		removed, gerr := s.applicationCommandsDeleteStale(appID, guildID, layout.Guilds[guildID], options...)
+		if len(removed) > 0 {
+			deleted[guildID] = removed
+		}
+		if gerr != nil {
+			errs[guildID] = gerr
+		}
+	}
+
+	if len(errs) > 0 {
+		err = errs
+	}
+	return
+}
+
+// applicationCommandsDeleteStale deletes the commands of a scope which are not in desired.
+func (s *Session) applicationCommandsDeleteStale(appID, guildID string, desired []*ApplicationCommand, options ...RequestOption) (deleted []*ApplicationCommand, err error) {
+	current, err := s.ApplicationCommands(appID, guildID, options...)
+	if err != nil {
+		return
+	}
+
+	keep := make(map[string]bool, len(desired))
+	for _, cmd := range desired {
+		keep[applicationCommandKey(cmd)] = true
+	}
+
+	for _, cmd := range current {
+		if keep[applicationCommandKey(cmd)] {
+			continue
+		}
+
+		if err = s.ApplicationCommandDelete(appID, guildID, cmd.ID, options...); err != nil {
+			return
+		}
+		deleted = append(deleted, cmd)
+	}
+	return
+}
+
 // ApplicationCommandDelete deletes application command by ID.
 // appID       : The application ID.
 // cmdID       : Application command ID to delete.
@@ -3032,6 +4110,15 @@ func (s *Session) ApplicationCommandPermissionsBatchEdit(appID, guildID string,
 func (s *Session) InteractionRespond(interaction *Interaction, resp *InteractionResponse, options ...RequestOption) error {
 	endpoint := EndpointInteractionResponse(interaction.ID, interaction.Token)
 
+	if resp.Data != nil && resp.Data.AllowedMentions == nil && s.DefaultAllowedMentions != nil {
+		// Apply the default to copies, leaving the caller's response unchanged.
+		data := *resp.Data
+		data.AllowedMentions = s.DefaultAllowedMentions
+		withDefault := *resp
+		withDefault.Data = &data
+		resp = &withDefault
+	}
+
 	if resp.Data != nil && len(resp.Data.Files) > 0 {
 		contentType, body, err := MultipartBodyWithJSON(resp, resp.Data.Files)
 		if err != nil {
@@ -3053,6 +4140,8 @@ func (s *Session) InteractionResponse(interaction *Interaction, options ...Reque
 }
 
 // InteractionResponseEdit edits the response to an interaction.
+// It is also used to send the response after deferring it, including files,
+// see WebhookEdit.Attachments to replace the files of an earlier response.
 // interaction : Interaction instance.
 // newresp     : Updated response message data.
 func (s *Session) InteractionResponseEdit(interaction *Interaction, newresp *WebhookEdit, options ...RequestOption) (*Message, error) {
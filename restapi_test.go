@@ -1,10 +1,16 @@
 package discordgo
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
+	"io/ioutil"
 	"net/http"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 //////////////////////////////////////////////////////////////////////////////
@@ -101,6 +107,47 @@ func TestUserChannelCreate(t *testing.T) {
 	// TODO make sure the channel was added
 }
 
+func TestUserGroupChannelCreate(t *testing.T) {
+	if dg == nil {
+		t.Skip("Cannot TestUserGroupChannelCreate, dg not set.")
+	}
+
+	if envAdmin == "" {
+		t.Skip("Skipped, DG_ADMIN not set.")
+	}
+
+	ch, err := dg.UserGroupChannelCreate([]string{envAdmin})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = dg.ChannelRecipientRemove(ch.ID, envAdmin); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGroupDMBotToken(t *testing.T) {
+	session, err := New("Bot token")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	session.Client.Transport = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		return nil, errors.New("unexpected request")
+	})
+
+	if _, err = session.UserGroupChannelCreate([]string{"user"}); err != ErrGroupDMBotToken {
+		t.Errorf("UserGroupChannelCreate error = %v, want %v", err, ErrGroupDMBotToken)
+	}
+	if err = session.ChannelRecipientAdd("channel", "user"); err != ErrGroupDMBotToken {
+		t.Errorf("ChannelRecipientAdd error = %v, want %v", err, ErrGroupDMBotToken)
+	}
+	if err = session.ChannelRecipientRemove("channel", "user"); err != ErrGroupDMBotToken {
+		t.Errorf("ChannelRecipientRemove error = %v, want %v", err, ErrGroupDMBotToken)
+	}
+}
+
 func TestUserGuilds(t *testing.T) {
 	if dg == nil {
 		t.Skip("Cannot TestUserGuilds, dg not set.")
@@ -275,3 +322,1086 @@ type roundTripperFunc func(*http.Request) (*http.Response, error)
 func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
 	return f(req)
 }
+
+func TestSessionAPIVersion(t *testing.T) {
+	session, err := New("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.APIVersion = "10"
+
+	testErr := errors.New("test")
+	var got string
+	session.Client.Transport = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		got = r.URL.String()
+		return nil, testErr
+	})
+
+	session.User("user")
+	if want := EndpointDiscord + "api/v10/users/user"; got != want {
+		t.Errorf("request made to %s, want %s", got, want)
+	}
+}
+
+func TestDefaultAllowedMentions(t *testing.T) {
+	session, err := New("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.DefaultAllowedMentions = &MessageAllowedMentions{Parse: []AllowedMentionType{AllowedMentionTypeUsers}}
+
+	testErr := errors.New("test")
+	var got *MessageAllowedMentions
+	session.Client.Transport = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		// Messages, forum posts and interaction responses nest the message.
+		var data struct {
+			AllowedMentions *MessageAllowedMentions `json:"allowed_mentions"`
+			Message         *MessageSend            `json:"message"`
+			Data            *MessageSend            `json:"data"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+			t.Fatal(err)
+		}
+		got = data.AllowedMentions
+		if data.Message != nil {
+			got = data.Message.AllowedMentions
+		} else if data.Data != nil {
+			got = data.Data.AllowedMentions
+		}
+		return nil, testErr
+	})
+
+	session.ChannelMessageSend("channel", "hello")
+	if got == nil || len(got.Parse) != 1 || got.Parse[0] != AllowedMentionTypeUsers {
+		t.Errorf("default allowed mentions not applied, got %+v", got)
+	}
+
+	// The default is only applied to the request, not to the caller's data.
+	message := &MessageSend{Content: "hello"}
+	webhook := &WebhookParams{Content: "hello"}
+	post := &MessageSend{Content: "hello"}
+	response := &InteractionResponse{Type: InteractionResponseChannelMessageWithSource, Data: &InteractionResponseData{Content: "hello"}}
+	requests := []func(){
+		func() { session.ChannelMessageSendComplex("channel", message) },
+		func() { session.WebhookExecute("webhook", "token", false, webhook) },
+		func() { session.ForumThreadStartComplex("channel", &ThreadStart{Name: "post"}, post) },
+		func() { session.InteractionRespond(&Interaction{ID: "interaction", Token: "token"}, response) },
+	}
+	for i, request := range requests {
+		got = nil
+		request()
+		if got == nil || len(got.Parse) != 1 || got.Parse[0] != AllowedMentionTypeUsers {
+			t.Errorf("request %d: default allowed mentions not applied, got %+v", i, got)
+		}
+	}
+	if message.AllowedMentions != nil || webhook.AllowedMentions != nil || post.AllowedMentions != nil || response.Data.AllowedMentions != nil {
+		t.Error("default allowed mentions were written to the caller's data")
+	}
+
+	override := &MessageAllowedMentions{Parse: []AllowedMentionType{AllowedMentionTypeEveryone}}
+	session.ChannelMessageSendComplex("channel", &MessageSend{Content: "hello", AllowedMentions: override})
+	if got == nil || len(got.Parse) != 1 || got.Parse[0] != AllowedMentionTypeEveryone {
+		t.Errorf("per-message allowed mentions should win, got %+v", got)
+	}
+}
+
+func TestPreferState(t *testing.T) {
+	session, err := New("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.PreferState = true
+	session.Client.Transport = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		t.Errorf("unexpected request to %s", r.URL)
+		return nil, errors.New("test")
+	})
+
+	session.State.GuildAdd(&Guild{ID: "guild"})
+	session.State.ChannelAdd(&Channel{ID: "channel", GuildID: "guild"})
+	session.State.MemberAdd(&Member{GuildID: "guild", User: &User{ID: "user"}})
+	session.State.MaxMessageCount = 10
+	session.State.MessageAdd(&Message{ID: "message", ChannelID: "channel"})
+
+	if g, err := session.Guild("guild"); err != nil || g.ID != "guild" {
+		t.Errorf("Guild() = %v, %v", g, err)
+	}
+	if c, err := session.Channel("channel"); err != nil || c.ID != "channel" {
+		t.Errorf("Channel() = %v, %v", c, err)
+	}
+	if m, err := session.ChannelMessage("channel", "message"); err != nil || m.ID != "message" {
+		t.Errorf("ChannelMessage() = %v, %v", m, err)
+	}
+	if m, err := session.GuildMember("guild", "user"); err != nil || m.User.ID != "user" {
+		t.Errorf("GuildMember() = %v, %v", m, err)
+	}
+	if u, err := session.User("user"); err != nil || u.ID != "user" {
+		t.Errorf("User() = %v, %v", u, err)
+	}
+}
+
+func TestResponseCache(t *testing.T) {
+	session, err := New("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.ResponseCache = NewResponseCache(10)
+
+	requests := 0
+	session.Client.Transport = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		requests++
+		resp := &http.Response{Header: http.Header{}, Body: ioutil.NopCloser(strings.NewReader(""))}
+		switch {
+		case r.Method != "GET":
+			resp.StatusCode = http.StatusNoContent
+		case r.Header.Get("If-None-Match") == `"v1"`:
+			resp.StatusCode = http.StatusNotModified
+		default:
+			resp.StatusCode = http.StatusOK
+			resp.Header.Set("ETag", `"v1"`)
+			resp.Body = ioutil.NopCloser(strings.NewReader(`[{"id":"role"}]`))
+		}
+		return resp, nil
+	})
+
+	for i := 0; i < 2; i++ {
+		roles, err := session.GuildRoles("guild")
+		if err != nil || len(roles) != 1 || roles[0].ID != "role" {
+			t.Fatalf("GuildRoles() #%d = %v, %v", i, roles, err)
+		}
+	}
+	if requests != 2 || session.ResponseCache.Len() != 1 {
+		t.Errorf("requests = %d, cache len = %d", requests, session.ResponseCache.Len())
+	}
+
+	if err := session.GuildRoleDelete("guild", "role"); err != nil {
+		t.Fatal(err)
+	}
+	if session.ResponseCache.Len() != 0 {
+		t.Error("mutating request did not invalidate the cache")
+	}
+}
+
+func TestMessageReactionToggle(t *testing.T) {
+	session, err := New("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reacted := true
+	session.Client.Transport = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		body := "{}"
+		switch r.Method {
+		case "GET":
+			b, _ := json.Marshal(&Message{Reactions: []*MessageReactions{{Count: 1, Me: reacted, Emoji: &Emoji{ID: "1", Name: "gopher"}}}})
+			body = string(b)
+		case "PUT":
+			reacted = true
+		case "DELETE":
+			reacted = false
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(body)), Header: http.Header{}}, nil
+	})
+
+	for _, want := range []bool{false, true} {
+		got, err := session.MessageReactionToggle("channel", "message", "gopher:1")
+		if err != nil {
+			t.Fatalf("MessageReactionToggle returned error: %s", err)
+		}
+		if got != want || reacted != want {
+			t.Errorf("MessageReactionToggle() = %v, reaction present %v, want %v", got, reacted, want)
+		}
+	}
+}
+
+func TestApplicationCommandBulkOverwriteGuilds(t *testing.T) {
+	session, err := New("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	session.Client.Transport = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		if strings.Contains(r.URL.Path, "/guilds/bad/") {
+			return &http.Response{StatusCode: http.StatusForbidden, Status: "403 Forbidden", Body: ioutil.NopCloser(strings.NewReader(`{"code": 50001}`)), Header: http.Header{}}, nil
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(`[{"name": "ping"}]`)), Header: http.Header{}}, nil
+	})
+
+	created, err := session.ApplicationCommandBulkOverwriteGuilds("app", []string{"good", "bad"}, []*ApplicationCommand{{Name: "ping", Description: "Ping"}})
+	if len(created["good"]) != 1 || created["good"][0].Name != "ping" {
+		t.Errorf("unexpected commands for guild good: %v", created["good"])
+	}
+
+	errs, ok := err.(GuildErrors)
+	if !ok || len(errs) != 1 || errs["bad"] == nil {
+		t.Errorf("expected GuildErrors for guild bad, got %v", err)
+	}
+}
+
+func TestApplicationCommandsDeleteStale(t *testing.T) {
+	session, err := New("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var deletes []string
+	session.Client.Transport = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		if r.Method == "DELETE" {
+			deletes = append(deletes, r.URL.Path)
+			return &http.Response{StatusCode: http.StatusNoContent, Body: ioutil.NopCloser(strings.NewReader("")), Header: http.Header{}}, nil
+		}
+		body := `[{"id": "1", "name": "ping", "type": 1}, {"id": "2", "name": "ping", "type": 3}]`
+		if strings.Contains(r.URL.Path, "/guilds/") {
+			body = `[{"id": "3", "name": "ping", "type": 1}, {"id": "4", "name": "guild-only", "type": 1}]`
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(body)), Header: http.Header{}}, nil
+	})
+
+	deleted, err := session.ApplicationCommandsDeleteStale("app", &ApplicationCommandLayout{
+		Global: []*ApplicationCommand{{Name: "ping"}},
+		Guilds: map[string][]*ApplicationCommand{"guild": {{Name: "guild-only"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(deleted[""]) != 1 || deleted[""][0].ID != "2" {
+		t.Errorf("expected the stale message command to be deleted globally, got %v", deleted[""])
+	}
+	if len(deleted["guild"]) != 1 || deleted["guild"][0].ID != "3" {
+		t.Errorf("expected the command made global to be deleted from the guild, got %v", deleted["guild"])
+	}
+	if len(deletes) != 2 {
+		t.Errorf("expected 2 delete requests, got %v", deletes)
+	}
+}
+
+func TestChannelEditForum(t *testing.T) {
+	session, err := New("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testErr := errors.New("test")
+	var got map[string]json.RawMessage
+	session.Client.Transport = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		return nil, testErr
+	})
+
+	layout := ForumLayoutGalleryView
+	session.ChannelEdit("channel", &ChannelEdit{
+		AvailableTags:        &[]ForumTag{{Name: "bug", EmojiName: "🐛"}},
+		DefaultReactionEmoji: &ForumDefaultReaction{EmojiID: "1"},
+		DefaultForumLayout:   &layout,
+	})
+	if string(got["available_tags"]) != `[{"name":"bug","moderated":false,"emoji_name":"🐛"}]` {
+		t.Errorf("unexpected available_tags %s", got["available_tags"])
+	}
+	if string(got["default_reaction_emoji"]) != `{"emoji_id":"1"}` {
+		t.Errorf("unexpected default_reaction_emoji %s", got["default_reaction_emoji"])
+	}
+	if string(got["default_forum_layout"]) != "2" {
+		t.Errorf("unexpected default_forum_layout %s", got["default_forum_layout"])
+	}
+
+	session.ChannelEdit("channel", &ChannelEdit{DefaultReactionEmoji: &ForumDefaultReaction{}})
+	if raw, ok := got["default_reaction_emoji"]; !ok || string(raw) != "null" {
+		t.Errorf("empty default reaction should be sent as null, got %s", raw)
+	}
+}
+
+func TestGuildRoleIcon(t *testing.T) {
+	session, err := New("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testErr := errors.New("test")
+	var got map[string]json.RawMessage
+	session.Client.Transport = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		return nil, testErr
+	})
+
+	session.GuildRoleCreate("guild", &RoleParams{Name: "role", Icon: []byte("\x89PNG\r\n\x1a\n")})
+	if string(got["icon"]) != `"data:image/png;base64,iVBORw0KGgo="` {
+		t.Errorf("unexpected icon %s", got["icon"])
+	}
+
+	emoji := "🎉"
+	_, err = session.GuildRoleEdit("guild", "role", &RoleParams{Icon: []byte("icon"), UnicodeEmoji: &emoji})
+	if err != ErrRoleIconAndEmoji {
+		t.Errorf("expected ErrRoleIconAndEmoji, got %v", err)
+	}
+}
+
+func TestCheckPermissions(t *testing.T) {
+	session, err := New("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.CheckPermissions = true
+	session.Client.Transport = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		t.Errorf("unexpected request to %s", r.URL)
+		return nil, errors.New("test")
+	})
+
+	session.State.User = &User{ID: "bot"}
+	session.State.GuildAdd(&Guild{ID: "guild", Roles: []*Role{{ID: "guild", Permissions: PermissionViewChannel | PermissionSendMessages}}})
+	session.State.ChannelAdd(&Channel{
+		ID:                   "channel",
+		GuildID:              "guild",
+		PermissionOverwrites: []*PermissionOverwrite{{ID: "guild", Type: PermissionOverwriteTypeRole, Deny: PermissionSendMessages}},
+	})
+	session.State.MemberAdd(&Member{GuildID: "guild", User: &User{ID: "bot"}})
+
+	_, err = session.ChannelMessageSend("channel", "hello")
+	permErr, ok := err.(*PermissionError)
+	if !ok || permErr.Missing != PermissionSendMessages {
+		t.Fatalf("expected PermissionError for SEND_MESSAGES, got %v", err)
+	}
+	if want := "missing permissions SEND_MESSAGES in channel channel"; permErr.Error() != want {
+		t.Errorf("Error() = %q, want %q", permErr.Error(), want)
+	}
+
+	// Without the current user, such as before READY, the check is skipped.
+	session.State.User = nil
+	requested := false
+	session.Client.Transport = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		requested = true
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(`{"id":"message"}`)), Header: http.Header{}}, nil
+	})
+	if _, err = session.ChannelMessageSend("channel", "hello"); err != nil || !requested {
+		t.Errorf("got error %v, requested %v without the current user, want the request to be made", err, requested)
+	}
+}
+
+func TestGuildThreadsActive(t *testing.T) {
+	session, err := New("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	session.Client.Transport = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		body := `{"threads": [{"id": "1", "type": 11}, {"id": "2", "type": 11}], "members": [{"id": "2", "user_id": "bot"}]}`
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(body)), Header: http.Header{}}, nil
+	})
+
+	threads, err := session.GuildThreadsActive("guild")
+	if err != nil {
+		t.Fatalf("GuildThreadsActive returned error: %s", err)
+	}
+	if len(threads.Threads) != 2 || len(threads.Members) != 1 {
+		t.Fatalf("unexpected threads list %+v", threads)
+	}
+	if threads.Threads[0].Member != nil {
+		t.Errorf("thread 1 should not have a member")
+	}
+	if m := threads.Threads[1].Member; m == nil || m.UserID != "bot" {
+		t.Errorf("thread 2 member not set, got %+v", m)
+	}
+}
+
+func TestUsers(t *testing.T) {
+	session, err := New("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var requested []string
+	session.Client.Transport = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		id := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		requested = append(requested, id)
+		if id == "unknown" {
+			return &http.Response{StatusCode: http.StatusNotFound, Status: "404 Not Found", Body: ioutil.NopCloser(strings.NewReader(`{"code": 10013}`)), Header: http.Header{}}, nil
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(`{"id": "` + id + `"}`)), Header: http.Header{}}, nil
+	})
+
+	session.State.GuildAdd(&Guild{ID: "guild"})
+	session.State.MemberAdd(&Member{GuildID: "guild", User: &User{ID: "cached"}})
+
+	users, err := session.Users([]string{"cached", "remote", "remote", "unknown"})
+	if err != nil {
+		t.Fatalf("Users returned error: %s", err)
+	}
+	if len(users) != 2 || users["cached"] == nil || users["remote"] == nil {
+		t.Errorf("unexpected users %v", users)
+	}
+	if len(requested) != 2 {
+		t.Errorf("expected requests for remote and unknown only, got %v", requested)
+	}
+}
+
+func TestInteractionResponseEditFiles(t *testing.T) {
+	session, err := New("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testErr := errors.New("test")
+	var (
+		payload map[string]json.RawMessage
+		files   []string
+	)
+	session.Client.Transport = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		payload, files = nil, nil
+		reader, err := r.MultipartReader()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for {
+			part, err := reader.NextPart()
+			if err != nil {
+				break
+			}
+			if part.FormName() == "payload_json" {
+				if err := json.NewDecoder(part).Decode(&payload); err != nil {
+					t.Fatal(err)
+				}
+			} else {
+				files = append(files, part.FormName()+"="+part.FileName())
+			}
+		}
+		return nil, testErr
+	})
+
+	interaction := &Interaction{AppID: "app", Token: "token"}
+	content := "generated"
+	session.InteractionResponseEdit(interaction, &WebhookEdit{
+		Content: &content,
+		Files:   []*File{{Name: "image.png", ContentType: "image/png", Reader: strings.NewReader("png")}},
+	})
+	if len(files) != 1 || files[0] != "files[0]=image.png" {
+		t.Errorf("got files %v, want files[0]=image.png", files)
+	}
+	if _, ok := payload["attachments"]; ok {
+		t.Errorf("attachments should be omitted when not retaining any, got %s", payload["attachments"])
+	}
+
+	session.InteractionResponseEdit(interaction, &WebhookEdit{
+		Attachments: &[]*MessageAttachment{{ID: "1"}},
+		Files:       []*File{{Name: "image.png", ContentType: "image/png", Reader: strings.NewReader("png")}},
+	})
+	var attachments []struct {
+		ID       json.RawMessage `json:"id"`
+		Filename string          `json:"filename"`
+	}
+	if err := json.Unmarshal(payload["attachments"], &attachments); err != nil {
+		t.Fatal(err)
+	}
+	if len(attachments) != 2 || string(attachments[0].ID) != `"1"` || string(attachments[1].ID) != "0" || attachments[1].Filename != "image.png" {
+		t.Errorf("got attachments %s, want the retained attachment and the uploaded file", payload["attachments"])
+	}
+}
+
+func TestApplicationEditMe(t *testing.T) {
+	session, err := New("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		method, url string
+		payload     map[string]json.RawMessage
+	)
+	session.Client.Transport = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		method, url = r.Method, r.URL.String()
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Fatal(err)
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(strings.NewReader(`{"id":"app","flags":8192,"integration_types_config":{"1":{}}}`)),
+		}, nil
+	})
+
+	description := "A bot"
+	app, err := session.ApplicationEditMe(&ApplicationParams{
+		Description: &description,
+		IntegrationTypesConfig: map[ApplicationIntegrationType]*ApplicationIntegrationTypeConfig{
+			ApplicationIntegrationUserInstall: {OAuth2InstallParams: &ApplicationInstallParams{Scopes: []string{"applications.commands"}}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if method != "PATCH" || url != EndpointApplication("@me") {
+		t.Errorf("request made to %s %s, want PATCH %s", method, url, EndpointApplication("@me"))
+	}
+	if want := `{"1":{"oauth2_install_params":{"scopes":["applications.commands"],"permissions":"0"}}}`; string(payload["integration_types_config"]) != want {
+		t.Errorf("got integration_types_config %s, want %s", payload["integration_types_config"], want)
+	}
+	if _, ok := payload["tags"]; ok {
+		t.Error("unset fields should be omitted")
+	}
+	if app.Flags&ApplicationFlagGatewayPresenceLimited == 0 || !ApplicationFlags(app.Flags).Has(ApplicationFlagGatewayPresenceLimited) {
+		t.Errorf("expected flags to be decoded, got %d", app.Flags)
+	}
+	if _, ok := app.IntegrationTypesConfig[ApplicationIntegrationUserInstall]; !ok {
+		t.Error("expected the user install integration type to be decoded")
+	}
+}
+
+func TestChannelMessageCrosspost(t *testing.T) {
+	session, err := New("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.MaxRestRetries = 0
+
+	var method, url string
+	session.Client.Transport = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		method, url = r.Method, r.URL.String()
+		return &http.Response{
+			StatusCode: http.StatusBadRequest,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       ioutil.NopCloser(strings.NewReader(`{"code":40033,"message":"This message has already been crossposted."}`)),
+		}, nil
+	})
+
+	_, err = session.ChannelMessageCrosspost("channel", "message")
+	if method != "POST" || url != EndpointChannelMessageCrosspost("channel", "message") {
+		t.Errorf("request made to %s %s, want POST %s", method, url, EndpointChannelMessageCrosspost("channel", "message"))
+	}
+	var restErr *RESTError
+	if !errors.As(err, &restErr) || restErr.Message == nil || restErr.Message.Code != ErrCodeMessageAlreadyCrossposted {
+		t.Errorf("got error %v, want a REST error with code %d", err, ErrCodeMessageAlreadyCrossposted)
+	}
+}
+
+func TestMessageReactionsByType(t *testing.T) {
+	session, err := New("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testErr := errors.New("test")
+	var got string
+	session.Client.Transport = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		got = r.URL.Query().Get("type")
+		return nil, testErr
+	})
+
+	session.MessageReactionsByType("channel", "message", "👍", ReactionTypeBurst, 10, "", "")
+	if got != "1" {
+		t.Errorf("got type %q, want %q", got, "1")
+	}
+	session.MessageReactions("channel", "message", "👍", 10, "", "")
+	if got != "" {
+		t.Errorf("got type %q for normal reactions, want it omitted", got)
+	}
+}
+
+func TestWebhookThreadMessage(t *testing.T) {
+	session, err := New("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testErr := errors.New("test")
+	var requests []string
+	session.Client.Transport = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		requests = append(requests, r.Method+" "+r.URL.Query().Get("thread_id"))
+		return nil, testErr
+	})
+
+	content := "edited"
+	session.WebhookThreadMessage("webhook", "token", "message", "thread")
+	session.WebhookThreadMessageEdit("webhook", "token", "message", "thread", &WebhookEdit{Content: &content})
+	session.WebhookThreadMessageDelete("webhook", "token", "message", "thread")
+	session.WebhookMessage("webhook", "token", "message")
+
+	want := []string{"GET thread", "PATCH thread", "DELETE thread", "GET "}
+	if strings.Join(requests, ",") != strings.Join(want, ",") {
+		t.Errorf("got requests %q, want %q", requests, want)
+	}
+}
+
+func TestRequestMiddleware(t *testing.T) {
+	session, err := New("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var order []string
+	session.Client.Transport = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		order = append(order, "request "+r.Header.Get("X-Trace"))
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(`{"id":"user"}`)), Header: http.Header{}}, nil
+	})
+
+	testErr := errors.New("test")
+	session.RequestMiddleware = []RequestMiddleware{
+		func(req *http.Request, next func() (*http.Response, error)) (*http.Response, error) {
+			order = append(order, "outer")
+			req.Header.Set("X-Trace", "trace")
+			resp, err := next()
+			order = append(order, "outer done")
+			return resp, err
+		},
+		func(req *http.Request, next func() (*http.Response, error)) (*http.Response, error) {
+			order = append(order, "inner")
+			return next()
+		},
+	}
+
+	if _, err := session.User("user"); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"outer", "inner", "request trace", "outer done"}
+	if strings.Join(order, ",") != strings.Join(want, ",") {
+		t.Errorf("got order %q, want %q", order, want)
+	}
+
+	session.RequestMiddleware = append(session.RequestMiddleware, func(req *http.Request, next func() (*http.Response, error)) (*http.Response, error) {
+		return nil, testErr
+	})
+	if _, err := session.User("user"); !errors.Is(err, testErr) {
+		t.Errorf("got error %v, want the middleware error", err)
+	}
+}
+
+func TestChannelPermissionsSyncToParent(t *testing.T) {
+	session, err := New("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	channels := map[string]string{
+		EndpointChannel("channel"):  `{"id":"channel","type":0,"parent_id":"category","permission_overwrites":[{"id":"role","type":0,"deny":"1024"}]}`,
+		EndpointChannel("category"): `{"id":"category","type":4,"permission_overwrites":[]}`,
+		EndpointChannel("orphan"):   `{"id":"orphan","type":0}`,
+	}
+	var patched string
+	session.Client.Transport = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		body := channels[r.URL.String()]
+		if r.Method == "PATCH" {
+			b, _ := ioutil.ReadAll(r.Body)
+			patched = string(b)
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(body))}, nil
+	})
+
+	if _, err = session.ChannelPermissionsSyncToParent("channel"); err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"permission_overwrites":[]}`; patched != want {
+		t.Errorf("got payload %s, want %s", patched, want)
+	}
+
+	if _, err = session.ChannelPermissionsSyncToParent("orphan"); err != ErrChannelNoParent {
+		t.Errorf("got error %v for a channel without parent, want ErrChannelNoParent", err)
+	}
+}
+
+func TestChannelInviteCreateComplex(t *testing.T) {
+	session, err := New("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var payload string
+	session.Client.Transport = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		b, _ := ioutil.ReadAll(r.Body)
+		payload = string(b)
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(`{"code":"code"}`))}, nil
+	})
+
+	_, err = session.ChannelInviteCreateComplex("channel", &InviteParams{
+		MaxAge:              time.Hour,
+		MaxUses:             5,
+		TargetType:          InviteTargetEmbeddedApplication,
+		TargetApplicationID: "app",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"max_age":3600,"max_uses":5,"temporary":false,"unique":false,"target_type":2,"target_application_id":"app"}`
+	if payload != want {
+		t.Errorf("got payload %s, want %s", payload, want)
+	}
+
+	if _, err = session.ChannelInviteCreateComplex("channel", &InviteParams{MaxAge: 8 * 24 * time.Hour}); err != ErrInviteMaxAgeBounds {
+		t.Errorf("got error %v for a max age of 8 days, want ErrInviteMaxAgeBounds", err)
+	}
+
+	if _, err = session.ChannelInviteCreate("channel", Invite{MaxAge: 60, TargetType: InviteTargetStream, TargetUser: &User{ID: "user"}}); err != nil {
+		t.Fatal(err)
+	}
+	want = `{"max_age":60,"max_uses":0,"temporary":false,"unique":false,"target_type":1,"target_user_id":"user"}`
+	if payload != want {
+		t.Errorf("got payload %s, want %s", payload, want)
+	}
+}
+
+func TestChannelMessagesSince(t *testing.T) {
+	session, err := New("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Messages 1 to 101, returned newest first like the API.
+	var afters []string
+	session.Client.Transport = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		after, _ := strconv.Atoi(r.URL.Query().Get("after"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		afters = append(afters, r.URL.Query().Get("after")+":"+r.URL.Query().Get("limit"))
+
+		var messages []*Message
+		for id := after + 1; id <= 101 && id <= after+limit; id++ {
+			messages = append([]*Message{{ID: strconv.Itoa(id)}}, messages...)
+		}
+		b, _ := json.Marshal(messages)
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewReader(b))}, nil
+	})
+
+	messages, err := session.ChannelMessagesSince("channel", "0", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 101 || messages[0].ID != "1" || messages[99].ID != "100" || messages[100].ID != "101" {
+		t.Errorf("got %d messages, want messages 1 to 101 in order", len(messages))
+	}
+	if got := strings.Join(afters, ","); got != "0:100,100:100" {
+		t.Errorf("got requests after %s, want 0:100,100:100", got)
+	}
+
+	afters = nil
+	if messages, err = session.ChannelMessagesSince("channel", "90", 5); err != nil || len(messages) != 5 || messages[0].ID != "91" {
+		t.Errorf("got %d messages, %v, want messages 91 to 95", len(messages), err)
+	}
+}
+
+func TestGuildChannelCreateComplex(t *testing.T) {
+	session, err := New("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var payload map[string]json.RawMessage
+	session.Client.Transport = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Fatal(err)
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(`{"id":"channel"}`))}, nil
+	})
+
+	_, err = session.GuildChannelCreateComplex("guild", GuildChannelCreateData{
+		Name:                 "help",
+		Type:                 ChannelTypeGuildForum,
+		PermissionOverwrites: []*PermissionOverwrite{{ID: "guild", Type: PermissionOverwriteTypeRole, Deny: PermissionViewChannel}},
+		AvailableTags:        []ForumTag{{Name: "bug"}},
+		DefaultForumLayout:   ForumLayoutGalleryView,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"permission_overwrites", "available_tags", "default_forum_layout"} {
+		if _, ok := payload[key]; !ok {
+			t.Errorf("payload is missing %s", key)
+		}
+	}
+	if _, ok := payload["rtc_region"]; ok {
+		t.Error("unset rtc_region should be omitted")
+	}
+}
+
+func TestMaxRateLimitWait(t *testing.T) {
+	session, err := New("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.MaxRateLimitWait = time.Second
+
+	var requests int
+	session.Client.Transport = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		requests++
+		header := http.Header{}
+		if strings.HasSuffix(r.URL.Path, "/users/limited") {
+			header.Set("X-RateLimit-Remaining", "0")
+			header.Set("X-RateLimit-Reset-After", "3600")
+			return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(`{"id": "limited"}`)), Header: header}, nil
+		}
+		return &http.Response{StatusCode: http.StatusTooManyRequests, Body: ioutil.NopCloser(strings.NewReader(`{"retry_after": 3600}`)), Header: header}, nil
+	})
+
+	if _, err := session.User("limited"); err != nil {
+		t.Fatal(err)
+	}
+
+	var rlErr *RateLimitError
+	for name, request := range map[string]func() error{
+		"bucket reset": func() error { _, err := session.User("limited"); return err },
+		"retry after":  func() error { _, err := session.Channel("channel"); return err },
+	} {
+		start := time.Now()
+		err := request()
+		if !errors.As(err, &rlErr) || rlErr.RetryAfter <= session.MaxRateLimitWait {
+			t.Errorf("%s: got error %v, want a RateLimitError", name, err)
+		}
+		if time.Since(start) > session.MaxRateLimitWait {
+			t.Errorf("%s: waited for the rate limit", name)
+		}
+	}
+	if requests != 2 {
+		t.Errorf("got %d requests, want 2", requests)
+	}
+}
+
+func TestGuildBansBulk(t *testing.T) {
+	session, err := New("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got map[string]interface{}
+	session.Client.Transport = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		if r.Method != "POST" || !strings.HasSuffix(r.URL.Path, "/guilds/guild/bulk-ban") {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(`{"banned_users": ["1"], "failed_users": ["2"]}`)), Header: http.Header{}}, nil
+	})
+
+	banned, failed, err := session.GuildBansBulk("guild", []string{"1", "2"}, 3600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(banned) != 1 || banned[0] != "1" || len(failed) != 1 || failed[0] != "2" {
+		t.Errorf("got banned %v and failed %v", banned, failed)
+	}
+	if got["delete_message_seconds"] != float64(3600) || len(got["user_ids"].([]interface{})) != 2 {
+		t.Errorf("unexpected request body %v", got)
+	}
+
+	if _, _, err := session.GuildBansBulk("guild", nil, 0); err != ErrBulkBanUserCount {
+		t.Errorf("GuildBansBulk() without users error = %v, want %v", err, ErrBulkBanUserCount)
+	}
+	if _, _, err := session.GuildBansBulk("guild", make([]string, GuildBulkBanMaxUsers+1), 0); err != ErrBulkBanUserCount {
+		t.Errorf("GuildBansBulk() with too many users error = %v, want %v", err, ErrBulkBanUserCount)
+	}
+}
+
+func TestWebhookCreateWithAvatar(t *testing.T) {
+	session, err := New("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got struct {
+		Name   string  `json:"name"`
+		Avatar *string `json:"avatar"`
+	}
+	session.Client.Transport = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		got.Avatar = nil
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(`{"id": "webhook"}`)), Header: http.Header{}}, nil
+	})
+
+	png := []byte("\x89PNG\r\n\x1a\n")
+	if _, err := session.WebhookCreateWithAvatar("channel", "logs", png); err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "logs" || got.Avatar == nil || *got.Avatar != "data:image/png;base64,iVBORw0KGgo=" {
+		t.Errorf("unexpected request body %+v", got)
+	}
+
+	if _, err := session.WebhookCreateWithAvatar("channel", "logs", nil); err != nil {
+		t.Fatal(err)
+	}
+	if got.Avatar != nil {
+		t.Errorf("default avatar sent as %q", *got.Avatar)
+	}
+}
+
+func TestSlowmodeError(t *testing.T) {
+	session, err := New("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var requests int
+	session.Client.Transport = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		requests++
+		body := `{"message": "You are being rate limited.", "retry_after": 12.5, "global": false, "code": 20016}`
+		return &http.Response{StatusCode: http.StatusTooManyRequests, Body: ioutil.NopCloser(strings.NewReader(body)), Header: http.Header{}}, nil
+	})
+
+	_, err = session.ChannelMessageSend("channel", "hello")
+	var slowmode *SlowmodeError
+	if !errors.As(err, &slowmode) {
+		t.Fatalf("got error %v, want a SlowmodeError", err)
+	}
+	if slowmode.RetryAfter != 12500*time.Millisecond {
+		t.Errorf("RetryAfter = %v, want 12.5s", slowmode.RetryAfter)
+	}
+	if requests != 1 {
+		t.Errorf("got %d requests, want the slowmode not to be retried", requests)
+	}
+}
+
+func TestGuildEditLevels(t *testing.T) {
+	s, _ := New("Bot token")
+	var body map[string]interface{}
+	s.Client.Transport = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		json.NewDecoder(r.Body).Decode(&body)
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(`{"id":"guild"}`)), Header: http.Header{}}, nil
+	})
+
+	notifications := MessageNotificationsOnlyMentions + 1
+	if _, err := s.GuildEdit("guild", &GuildParams{DefaultMessageNotifications: &notifications}); err != ErrMessageNotificationsBounds {
+		t.Errorf("GuildEdit() error = %v, want %v", err, ErrMessageNotificationsBounds)
+	}
+	filter := ExplicitContentFilterLevel(-1)
+	if _, err := s.GuildEdit("guild", &GuildParams{ExplicitContentFilter: &filter}); err != ErrExplicitContentFilterBounds {
+		t.Errorf("GuildEdit() error = %v, want %v", err, ErrExplicitContentFilterBounds)
+	}
+
+	notifications, filter = MessageNotificationsAllMessages, ExplicitContentFilterDisabled
+	if _, err := s.GuildEdit("guild", &GuildParams{DefaultMessageNotifications: &notifications, ExplicitContentFilter: &filter}); err != nil {
+		t.Fatal(err)
+	}
+	if body["default_message_notifications"] != float64(0) || body["explicit_content_filter"] != float64(0) {
+		t.Errorf("zero levels not sent, got body %v", body)
+	}
+
+	if VerificationLevelVeryHigh.String() != "VeryHigh" || ExplicitContentFilterLevel(7).String() != "ExplicitContentFilterLevel(7)" {
+		t.Errorf("unexpected level names %v, %v", VerificationLevelVeryHigh, ExplicitContentFilterLevel(7))
+	}
+}
+
+func TestFileUploadRetry(t *testing.T) {
+	s, _ := New("Bot token")
+	var bodies []string
+	s.Client.Transport = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		b, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+		status := http.StatusOK
+		if len(bodies) == 1 {
+			status = http.StatusBadGateway
+		}
+		return &http.Response{StatusCode: status, Body: ioutil.NopCloser(strings.NewReader(`{"id":"message"}`)), Header: http.Header{}}, nil
+	})
+
+	data := &MessageSend{Files: []*File{{Name: "file.txt", Reader: strings.NewReader("file contents")}}}
+	for i := 0; i < 2; i++ {
+		if _, err := s.ChannelMessageSendComplex("channel", data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(bodies) != 3 {
+		t.Fatalf("got %d requests, want 3", len(bodies))
+	}
+	for i, b := range bodies {
+		if !strings.Contains(b, "file contents") {
+			t.Errorf("request %d is missing the file contents: %q", i, b)
+		}
+	}
+
+	data.Files[0].Reader = ioutil.NopCloser(strings.NewReader("file contents"))
+	s.ChannelMessageSendComplex("channel", data)
+	if _, err := s.ChannelMessageSendComplex("channel", data); err == nil || !strings.Contains(err.Error(), "io.Seeker") {
+		t.Errorf("got error %v sending a consumed reader again, want a hint about io.Seeker", err)
+	}
+
+	data.Files[0].Reader = strings.NewReader("")
+	if _, err := s.ChannelMessageSendComplex("channel", data); err == nil || strings.Contains(err.Error(), "io.Seeker") {
+		t.Errorf("got error %v sending an empty io.Seeker, want an error without the io.Seeker hint", err)
+	}
+}
+
+func TestGuildMemberMove(t *testing.T) {
+	s, _ := New("Bot token")
+	var bodies []string
+	s.Client.Transport = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		b, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+		return &http.Response{StatusCode: http.StatusNoContent, Body: ioutil.NopCloser(strings.NewReader("")), Header: http.Header{}}, nil
+	})
+
+	channelID, empty := "channel", ""
+	for _, c := range []*string{&channelID, nil, &empty} {
+		if err := s.GuildMemberMove("guild", "user", c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []string{`{"channel_id":"channel"}`, `{"channel_id":null}`, `{"channel_id":null}`}
+	if strings.Join(bodies, ",") != strings.Join(want, ",") {
+		t.Errorf("got bodies %v, want %v", bodies, want)
+	}
+}
+
+func TestThreadMember(t *testing.T) {
+	s, _ := New("Bot token")
+	var urls []string
+	s.Client.Transport = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		urls = append(urls, r.URL.String())
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(`{"user_id":"user"}`)), Header: http.Header{}}, nil
+	})
+
+	if _, err := s.ThreadMember("thread", "user"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.ThreadMemberWithMember("thread", "user"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{EndpointThreadMember("thread", "user"), EndpointThreadMember("thread", "user") + "?with_member=true"}
+	if strings.Join(urls, " ") != strings.Join(want, " ") {
+		t.Errorf("got requests %v, want %v", urls, want)
+	}
+}
+
+func TestApplicationCommandEdit(t *testing.T) {
+	s, _ := New("Bot token")
+	var requests int
+	s.Client.Transport = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		requests++
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(`{"id":"cmd"}`)), Header: http.Header{}}, nil
+	})
+
+	perms := int64(PermissionManageMessages)
+	edits := []*ApplicationCommand{
+		{DefaultMemberPermissions: &perms},
+		{Options: []*ApplicationCommandOption{{Type: ApplicationCommandOptionString, Name: "value", Description: "Value"}}},
+		{Name: "View Profile"},
+	}
+	for _, cmd := range edits {
+		if _, err := s.ApplicationCommandEdit("app", "", "cmd", cmd); err != nil {
+			t.Errorf("ApplicationCommandEdit(%+v) returned error %v", cmd, err)
+		}
+	}
+
+	invalid := []*ApplicationCommand{
+		nil,
+		{Type: ChatApplicationCommand, Name: "Ping"},
+		{Options: []*ApplicationCommandOption{{Type: ApplicationCommandOptionString, Name: "value"}}},
+	}
+	for _, cmd := range invalid {
+		if _, err := s.ApplicationCommandEdit("app", "", "cmd", cmd); !errors.Is(err, ErrInvalidApplicationCommand) {
+			t.Errorf("ApplicationCommandEdit(%+v) returned error %v, want ErrInvalidApplicationCommand", cmd, err)
+		}
+	}
+
+	if requests != len(edits) {
+		t.Errorf("made %d requests, want %d", requests, len(edits))
+	}
+
+	if _, err := s.ApplicationCommandCreate("app", "", nil); !errors.Is(err, ErrInvalidApplicationCommand) {
+		t.Errorf("ApplicationCommandCreate(nil) returned error %v, want ErrInvalidApplicationCommand", err)
+	}
+	if _, err := s.ApplicationCommandBulkOverwrite("app", "", []*ApplicationCommand{nil}); !errors.Is(err, ErrInvalidApplicationCommand) {
+		t.Errorf("ApplicationCommandBulkOverwrite with a nil command returned error %v, want ErrInvalidApplicationCommand", err)
+	}
+}
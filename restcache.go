@@ -0,0 +1,127 @@
+// Discordgo - Discord bindings for Go
+// Available at https://github.com/bwmarrin/discordgo
+
+// Copyright 2015-2016 Bruce Marriner <bruce@sqls.net>.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file contains a cache for conditional REST requests using ETags.
+
+package discordgo
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+)
+
+// ResponseCache caches the bodies of GET responses which carry an ETag, so
+// that repeated requests can be made conditionally with If-None-Match and a
+// 304 Not Modified response is served from the cache.
+// Entries are keyed by URL and evicted least recently used first.
+//
+// Any successful non-GET request invalidates cached URLs which are a parent
+// or a child of the requested path, e.g. editing guilds/1/roles/2 invalidates
+// guilds/1 and guilds/1/roles but not guilds/1/emojis.
+type ResponseCache struct {
+	sync.Mutex
+
+	maxEntries int
+	entries    map[string]*list.Element
+	order      *list.List
+}
+
+type responseCacheEntry struct {
+	url  string
+	etag string
+	body []byte
+}
+
+// NewResponseCache returns a new ResponseCache holding at most maxEntries
+// responses. If maxEntries is 0 the cache is unbounded.
+func NewResponseCache(maxEntries int) *ResponseCache {
+	return &ResponseCache{
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+// get returns the cached ETag and body for a URL.
+func (c *ResponseCache) get(url string) (etag string, body []byte, ok bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	e, ok := c.entries[url]
+	if !ok {
+		return "", nil, false
+	}
+	c.order.MoveToFront(e)
+
+	entry := e.Value.(*responseCacheEntry)
+	return entry.etag, entry.body, true
+}
+
+// put stores the ETag and body of a response for a URL.
+func (c *ResponseCache) put(url, etag string, body []byte) {
+	c.Lock()
+	defer c.Unlock()
+
+	if e, ok := c.entries[url]; ok {
+		entry := e.Value.(*responseCacheEntry)
+		entry.etag = etag
+		entry.body = body
+		c.order.MoveToFront(e)
+		return
+	}
+
+	c.entries[url] = c.order.PushFront(&responseCacheEntry{url: url, etag: etag, body: body})
+
+	if c.maxEntries > 0 && c.order.Len() > c.maxEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*responseCacheEntry).url)
+	}
+}
+
+// relatedPath reports whether one of the paths is equal to or an ancestor of the other.
+func relatedPath(a, b string) bool {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	a = strings.TrimSuffix(a, "/")
+	return b == a || strings.HasPrefix(b, a+"/")
+}
+
+// Invalidate removes every cached response whose path is
+// equal to, a parent of, or a child of the path of url.
+func (c *ResponseCache) Invalidate(url string) {
+	path := strings.SplitN(url, "?", 2)[0]
+
+	c.Lock()
+	defer c.Unlock()
+
+	for key, e := range c.entries {
+		if relatedPath(path, strings.SplitN(key, "?", 2)[0]) {
+			c.order.Remove(e)
+			delete(c.entries, key)
+		}
+	}
+}
+
+// Clear removes all cached responses.
+func (c *ResponseCache) Clear() {
+	c.Lock()
+	defer c.Unlock()
+
+	c.entries = make(map[string]*list.Element)
+	c.order.Init()
+}
+
+// Len returns the number of cached responses.
+func (c *ResponseCache) Len() int {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.order.Len()
+}
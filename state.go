@@ -14,7 +14,9 @@ package discordgo
 
 import (
 	"errors"
+	"fmt"
 	"sort"
+	"strings"
 	"sync"
 )
 
@@ -30,6 +32,46 @@ var ErrStateNotFound = errors.New("state cache not found")
 // generate the permissions.
 var ErrMessageIncompletePermissions = errors.New("message incomplete, unable to determine permissions")
 
+// StateStore is the interface implemented by state caches. The default
+// implementation is the in-memory State, but a StateStore backed by an
+// external service (such as Redis) may be assigned to Session.Store so that
+// multiple processes can share a single cache.
+//
+// Gateway events are handled by the State of the session, which writes the
+// changes through to the Add and Remove methods of the store. It passes the
+// objects held by the State, which the store must not modify.
+type StateStore interface {
+	GuildAdd(guild *Guild) error
+	GuildRemove(guild *Guild) error
+	Guild(guildID string) (*Guild, error)
+
+	ChannelAdd(channel *Channel) error
+	ChannelRemove(channel *Channel) error
+	Channel(channelID string) (*Channel, error)
+
+	MemberAdd(member *Member) error
+	MemberRemove(member *Member) error
+	Member(guildID, userID string) (*Member, error)
+
+	RoleAdd(guildID string, role *Role) error
+	RoleRemove(guildID, roleID string) error
+	Role(guildID, roleID string) (*Role, error)
+
+	EmojiAdd(guildID string, emoji *Emoji) error
+	Emoji(guildID, emojiID string) (*Emoji, error)
+
+	MessageAdd(message *Message) error
+	MessageRemove(message *Message) error
+	Message(channelID, messageID string) (*Message, error)
+
+	PresenceAdd(guildID string, presence *Presence) error
+	PresenceRemove(guildID string, presence *Presence) error
+	Presence(guildID, userID string) (*Presence, error)
+}
+
+// State implements StateStore.
+var _ StateStore = (*State)(nil)
+
 // A State contains the current known state.
 // As discord sends this in a READY blob, it seems reasonable to simply
 // use that struct as the data store.
@@ -75,11 +117,31 @@ func NewState() *State {
 }
 
 func (s *State) createMemberMap(guild *Guild) {
-	members := make(map[string]*Member)
-	for _, m := range guild.Members {
-		members[m.User.ID] = m
+	s.memberMap[guild.ID], guild.Members = newMemberMap(guild.Members)
+}
+
+// newMemberMap returns members keyed by user ID, along with a new slice of
+// them without duplicates. Neither the slice passed nor the members in it
+// are modified, as they may be held by the state already.
+func newMemberMap(members []*Member) (byID map[string]*Member, unique []*Member) {
+	byID = make(map[string]*Member, len(members))
+	unique = make([]*Member, 0, len(members))
+	index := make(map[string]int, len(members))
+	for _, m := range members {
+		if m == nil || m.User == nil {
+			continue
+		}
+		// Members listed more than once are kept once, with the latest data.
+		if i, ok := index[m.User.ID]; ok {
+			unique[i] = m
+			byID[m.User.ID] = m
+			continue
+		}
+		index[m.User.ID] = len(unique)
+		byID[m.User.ID] = m
+		unique = append(unique, m)
 	}
-	s.memberMap[guild.ID] = members
+	return
 }
 
 // GuildAdd adds a guild to the current world state, or
@@ -89,9 +151,21 @@ func (s *State) GuildAdd(guild *Guild) error {
 		return ErrNilState
 	}
 
+	// Building the member map of large guilds takes a while, so it is done
+	// before locking to keep the state readable in the meantime.
+	var members map[string]*Member
+	var unique []*Member
+	if guild.Members != nil {
+		members, unique = newMemberMap(guild.Members)
+	}
+
 	s.Lock()
 	defer s.Unlock()
 
+	if members != nil {
+		guild.Members = unique
+	}
+
 	// Update the channels to point to the right guild, adding them to the channelMap as we go
 	for _, c := range guild.Channels {
 		s.channelMap[c.ID] = c
@@ -103,8 +177,8 @@ func (s *State) GuildAdd(guild *Guild) error {
 	}
 
 	// If this guild contains a new member slice, we must regenerate the member map so the pointers stay valid
-	if guild.Members != nil {
-		s.createMemberMap(guild)
+	if members != nil {
+		s.memberMap[guild.ID] = members
 	} else if _, ok := s.memberMap[guild.ID]; !ok {
 		// Even if we have no new member slice, we still initialize the member map for this guild if it doesn't exist
 		s.memberMap[guild.ID] = make(map[string]*Member)
@@ -192,6 +266,61 @@ func (s *State) Guild(guildID string) (*Guild, error) {
 	return nil, ErrStateNotFound
 }
 
+// GuildMemberCount returns the number of members of a guild, and whether
+// the count is exact rather than approximate.
+//
+// The count is exact when it was received with the guild in GUILD_CREATE,
+// from then on it is kept up to date by member add and remove events,
+// which are only received with IntentGuildMembers. Without that intent it
+// grows stale, and should be treated as approximate.
+// When only a guild from the REST API with counts is cached, its
+// ApproximateMemberCount is returned and exact is false.
+func (s *State) GuildMemberCount(guildID string) (count int, exact bool, err error) {
+	if s == nil {
+		return 0, false, ErrNilState
+	}
+
+	s.RLock()
+	defer s.RUnlock()
+
+	g, ok := s.guildMap[guildID]
+	if !ok {
+		return 0, false, ErrStateNotFound
+	}
+
+	if g.MemberCount == 0 && g.ApproximateMemberCount != 0 {
+		return g.ApproximateMemberCount, false, nil
+	}
+	return g.MemberCount, true, nil
+}
+
+// guildMemberCountAdd adds delta to the member count of a guild.
+func (s *State) guildMemberCountAdd(guildID string, delta int) error {
+	s.Lock()
+	defer s.Unlock()
+
+	g, ok := s.guildMap[guildID]
+	if !ok {
+		return ErrStateNotFound
+	}
+
+	g.MemberCount += delta
+	if g.MemberCount < 0 {
+		g.MemberCount = 0
+	}
+	return nil
+}
+
+// channelLastMessage updates the last message ID of a channel for a new message.
+func (s *State) channelLastMessage(channelID, messageID string) {
+	s.Lock()
+	defer s.Unlock()
+
+	if c, ok := s.channelMap[channelID]; ok && SnowflakeCompare(messageID, c.LastMessageID) > 0 {
+		c.LastMessageID = messageID
+	}
+}
+
 func (s *State) presenceAdd(guildID string, presence *Presence) error {
 	guild, ok := s.guildMap[guildID]
 	if !ok {
@@ -302,6 +431,45 @@ func (s *State) Presence(guildID, userID string) (*Presence, error) {
 	return nil, ErrStateNotFound
 }
 
+// UserPresence returns a presence of a user from any guild in the state.
+// Presences are received per guild, but the status and activities are
+// the same in all guilds shared with the user.
+func (s *State) UserPresence(userID string) (*Presence, error) {
+	if s == nil {
+		return nil, ErrNilState
+	}
+
+	s.RLock()
+	defer s.RUnlock()
+
+	for _, guild := range s.guildMap {
+		for _, p := range guild.Presences {
+			if p.User != nil && p.User.ID == userID {
+				return p, nil
+			}
+		}
+	}
+
+	return nil, ErrStateNotFound
+}
+
+// ShardStates gives a unified view of the states of the sessions of
+// multiple shards, e.g. ShardStates{shard0.State, shard1.State}.
+type ShardStates []*State
+
+// Presence returns a presence of a user from any guild of any shard.
+// As presences are per guild, any shard with a guild shared with the user
+// may have it, so all shards are searched in order.
+func (ss ShardStates) Presence(userID string) (*Presence, error) {
+	for _, s := range ss {
+		if p, err := s.UserPresence(userID); err == nil {
+			return p, nil
+		}
+	}
+
+	return nil, ErrStateNotFound
+}
+
 // TODO: Consider moving Guild state update methods onto *Guild.
 
 func (s *State) memberAdd(member *Member) error {
@@ -400,6 +568,136 @@ func (s *State) Member(guildID, userID string) (*Member, error) {
 	return nil, ErrStateNotFound
 }
 
+// user finds a user by ID among the current user, tracked members
+// and private channel recipients. "@me" refers to the current user.
+func (s *State) user(userID string) (*User, error) {
+	if s == nil {
+		return nil, ErrNilState
+	}
+
+	s.RLock()
+	defer s.RUnlock()
+
+	if s.User != nil && (userID == "@me" || s.User.ID == userID) {
+		return s.User, nil
+	}
+
+	for _, members := range s.memberMap {
+		if m, ok := members[userID]; ok && m.User != nil {
+			return m.User, nil
+		}
+	}
+
+	for _, c := range s.PrivateChannels {
+		for _, u := range c.Recipients {
+			if u.ID == userID {
+				return u, nil
+			}
+		}
+	}
+
+	return nil, ErrStateNotFound
+}
+
+// memberNameMatches reports whether match returns true for the member's
+// nickname, username or global name.
+func memberNameMatches(m *Member, match func(string) bool) bool {
+	if m.Nick != "" && match(m.Nick) {
+		return true
+	}
+	if m.User == nil {
+		return false
+	}
+	return (m.User.Username != "" && match(m.User.Username)) ||
+		(m.User.GlobalName != "" && match(m.User.GlobalName))
+}
+
+// MemberByName gets a member of a guild whose nickname, username or global
+// name is equal to name, ignoring case.
+// To search for members through the API, use Session.GuildMembersSearch.
+func (s *State) MemberByName(guildID, name string) (*Member, error) {
+	if s == nil {
+		return nil, ErrNilState
+	}
+
+	guild, err := s.Guild(guildID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.RLock()
+	defer s.RUnlock()
+
+	for _, m := range guild.Members {
+		if memberNameMatches(m, func(n string) bool { return strings.EqualFold(n, name) }) {
+			return m, nil
+		}
+	}
+
+	return nil, ErrStateNotFound
+}
+
+// MembersSearch returns up to limit members of a guild whose nickname,
+// username or global name starts with query, ignoring case.
+// This mirrors Session.GuildMembersSearch without making an API call.
+// limit    : Max number of members to return, or 0 for all matches
+func (s *State) MembersSearch(guildID, query string, limit int) ([]*Member, error) {
+	if s == nil {
+		return nil, ErrNilState
+	}
+
+	guild, err := s.Guild(guildID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.RLock()
+	defer s.RUnlock()
+
+	query = strings.ToLower(query)
+	var st []*Member
+	for _, m := range guild.Members {
+		if memberNameMatches(m, func(n string) bool { return strings.HasPrefix(strings.ToLower(n), query) }) {
+			st = append(st, m)
+			if limit > 0 && len(st) >= limit {
+				break
+			}
+		}
+	}
+
+	return st, nil
+}
+
+// GuildMembersFilter returns the members of a guild for which pred returns
+// true, e.g. members who joined after a given time or who are still pending
+// membership screening:
+//
+//	members, err := s.GuildMembersFilter(guildID, func(m *Member) bool { return m.Pending })
+//
+// pred is called while the state is locked, so it must not call methods of the State.
+func (s *State) GuildMembersFilter(guildID string, pred func(*Member) bool) ([]*Member, error) {
+	if s == nil {
+		return nil, ErrNilState
+	}
+
+	guild, err := s.Guild(guildID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.RLock()
+	defer s.RUnlock()
+
+	var st []*Member
+	for _, m := range guild.Members {
+		if pred(m) {
+			st = append(st, m)
+		}
+	}
+
+	return st, nil
+}
+
 // RoleAdd adds a role to the current world state, or
 // updates it if it already exists.
 func (s *State) RoleAdd(guildID string, role *Role) error {
@@ -473,6 +771,28 @@ func (s *State) Role(guildID, roleID string) (*Role, error) {
 	return nil, ErrStateNotFound
 }
 
+// GuildRoleByName gets the first role of a guild whose name is equal to name,
+// ignoring case. It returns ErrStateNotFound if the guild has no such role.
+func (s *State) GuildRoleByName(guildID, name string) (*Role, error) {
+	if s == nil {
+		return nil, ErrNilState
+	}
+
+	guild, err := s.Guild(guildID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.RLock()
+	defer s.RUnlock()
+
+	if r := guild.RoleByName(name); r != nil {
+		return r, nil
+	}
+
+	return nil, ErrStateNotFound
+}
+
 // ChannelAdd adds a channel to the current world state, or
 // updates it if it already exists.
 // Channels may exist either as PrivateChannels or inside
@@ -489,6 +809,8 @@ func (s *State) ChannelAdd(channel *Channel) error {
 	if c, ok := s.channelMap[channel.ID]; ok {
 		if channel.Messages == nil {
 			channel.Messages = c.Messages
+			channel.messageIndex = c.messageIndex
+			channel.messageOffset = c.messageOffset
 		}
 		if channel.PermissionOverwrites == nil {
 			channel.PermissionOverwrites = c.PermissionOverwrites
@@ -686,6 +1008,30 @@ func (s *State) Channel(channelID string) (*Channel, error) {
 	return nil, ErrStateNotFound
 }
 
+// ActiveThreads returns the threads of a guild which are not archived.
+func (s *State) ActiveThreads(guildID string) ([]*Channel, error) {
+	if s == nil {
+		return nil, ErrNilState
+	}
+
+	guild, err := s.Guild(guildID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.RLock()
+	defer s.RUnlock()
+
+	var threads []*Channel
+	for _, t := range guild.Threads {
+		if !threadArchived(t) {
+			threads = append(threads, t)
+		}
+	}
+
+	return threads, nil
+}
+
 // Emoji returns an emoji for a guild and emoji id.
 func (s *State) Emoji(guildID, emojiID string) (*Emoji, error) {
 	if s == nil {
@@ -761,46 +1107,100 @@ func (s *State) MessageAdd(message *Message) error {
 	defer s.Unlock()
 
 	// If the message exists, merge in the new message contents.
-	for _, m := range c.Messages {
-		if m.ID == message.ID {
-			if message.Content != "" {
-				m.Content = message.Content
-			}
-			if message.EditedTimestamp != nil {
-				m.EditedTimestamp = message.EditedTimestamp
-			}
-			if message.Mentions != nil {
-				m.Mentions = message.Mentions
-			}
-			if message.Embeds != nil {
-				m.Embeds = message.Embeds
-			}
-			if message.Attachments != nil {
-				m.Attachments = message.Attachments
-			}
-			if !message.Timestamp.IsZero() {
-				m.Timestamp = message.Timestamp
-			}
-			if message.Author != nil {
-				m.Author = message.Author
-			}
-			if message.Components != nil {
-				m.Components = message.Components
-			}
-
-			return nil
+	if i, err := findMessage(c, message.ID); err == nil {
+		m := c.Messages[i]
+		if message.Content != "" {
+			m.Content = message.Content
+		}
+		if message.EditedTimestamp != nil {
+			m.EditedTimestamp = message.EditedTimestamp
+		}
+		if message.Mentions != nil {
+			m.Mentions = message.Mentions
 		}
+		if message.Embeds != nil {
+			m.Embeds = message.Embeds
+		}
+		if message.Attachments != nil {
+			m.Attachments = message.Attachments
+		}
+		if !message.Timestamp.IsZero() {
+			m.Timestamp = message.Timestamp
+		}
+		if message.Author != nil {
+			m.Author = message.Author
+		}
+		if message.Components != nil {
+			m.Components = message.Components
+		}
+
+		return nil
 	}
 
+	c.messageIndex[message.ID] = c.messageOffset + len(c.Messages)
 	c.Messages = append(c.Messages, message)
 
 	if len(c.Messages) > s.MaxMessageCount {
-		c.Messages = c.Messages[len(c.Messages)-s.MaxMessageCount:]
+		evicted := len(c.Messages) - s.MaxMessageCount
+		for _, m := range c.Messages[:evicted] {
+			delete(c.messageIndex, m.ID)
+		}
+		c.Messages = c.Messages[evicted:]
+		c.messageOffset += evicted
 	}
 
 	return nil
 }
 
+// messagePosition returns the index in c.Messages of a message using the
+// message index of the channel. It returns ErrStateNotFound for messages
+// which aren't indexed, and an error describing the mismatch if the index
+// is out of sync with the messages, such as after they were modified
+// outside of the state. The caller must hold the state lock.
+func messagePosition(c *Channel, messageID string) (int, error) {
+	if c.messageIndex == nil || len(c.messageIndex) != len(c.Messages) {
+		return -1, fmt.Errorf("message index of channel %s has %d entries for %d messages", c.ID, len(c.messageIndex), len(c.Messages))
+	}
+	if n := len(c.Messages); n > 0 {
+		first, firstOk := c.messageIndex[c.Messages[0].ID]
+		last, lastOk := c.messageIndex[c.Messages[n-1].ID]
+		if !firstOk || !lastOk || first != c.messageOffset || last != c.messageOffset+n-1 {
+			return -1, fmt.Errorf("message index of channel %s does not match its first and last messages", c.ID)
+		}
+	}
+
+	p, ok := c.messageIndex[messageID]
+	if !ok {
+		return -1, ErrStateNotFound
+	}
+
+	i := p - c.messageOffset
+	if i < 0 || i >= len(c.Messages) {
+		return -1, fmt.Errorf("message %s is indexed at %d, outside the %d messages of channel %s", messageID, i, len(c.Messages), c.ID)
+	}
+	if c.Messages[i].ID != messageID {
+		return -1, fmt.Errorf("message %s is indexed at %d, which holds message %s in channel %s", messageID, i, c.Messages[i].ID, c.ID)
+	}
+	return i, nil
+}
+
+// findMessage returns the index in c.Messages of a message, rebuilding the
+// message index of the channel if it is out of sync with the messages.
+// The caller must hold the state write lock.
+func findMessage(c *Channel, messageID string) (int, error) {
+	i, err := messagePosition(c, messageID)
+	if err == nil || err == ErrStateNotFound {
+		return i, err
+	}
+
+	c.messageIndex = make(map[string]int, len(c.Messages))
+	c.messageOffset = 0
+	for i, m := range c.Messages {
+		c.messageIndex[m.ID] = i
+	}
+	return messagePosition(c, messageID)
+}
+
 // MessageRemove removes a message from the world state.
 func (s *State) MessageRemove(message *Message) error {
 	if s == nil {
@@ -820,15 +1220,24 @@ func (s *State) messageRemoveByID(channelID, messageID string) error {
 	s.Lock()
 	defer s.Unlock()
 
-	for i, m := range c.Messages {
-		if m.ID == messageID {
-			c.Messages = append(c.Messages[:i], c.Messages[i+1:]...)
+	i, err := findMessage(c, messageID)
+	if err != nil {
+		return err
+	}
 
-			return nil
+	c.Messages = append(c.Messages[:i], c.Messages[i+1:]...)
+	delete(c.messageIndex, messageID)
+
+	// The messages after the removed one moved down by one.
+	if i == 0 {
+		c.messageOffset++
+	} else {
+		for _, m := range c.Messages[i:] {
+			c.messageIndex[m.ID]--
 		}
 	}
 
-	return ErrStateNotFound
+	return nil
 }
 
 func (s *State) voiceStateUpdate(update *VoiceStateUpdate) error {
@@ -896,6 +1305,16 @@ func (s *State) Message(channelID, messageID string) (*Message, error) {
 	s.RLock()
 	defer s.RUnlock()
 
+	i, err := messagePosition(c, messageID)
+	if err == nil {
+		return c.Messages[i], nil
+	}
+	if err == ErrStateNotFound {
+		return nil, err
+	}
+
+	// The index is out of sync and can only be rebuilt under the write lock,
+	// so fall back to searching the messages.
 	for _, m := range c.Messages {
 		if m.ID == messageID {
 			return m, nil
@@ -918,11 +1337,12 @@ func (s *State) onReady(se *Session, r *Ready) (err error) {
 	// if state is disabled, store the bare essentials.
 	if !se.StateEnabled {
 		ready := Ready{
-			Version:     r.Version,
-			SessionID:   r.SessionID,
-			User:        r.User,
-			Shard:       r.Shard,
-			Application: r.Application,
+			Version:          r.Version,
+			SessionID:        r.SessionID,
+			ResumeGatewayURL: r.ResumeGatewayURL,
+			User:             r.User,
+			Shard:            r.Shard,
+			Application:      r.Application,
 		}
 
 		s.Ready = ready
@@ -954,6 +1374,16 @@ func (s *State) OnInterface(se *Session, i interface{}) (err error) {
 		return ErrNilState
 	}
 
+	err = s.onInterface(se, i)
+	if se.Store != nil && se.StateEnabled {
+		if werr := s.writeThrough(se.Store, i); err == nil {
+			err = werr
+		}
+	}
+	return
+}
+
+func (s *State) onInterface(se *Session, i interface{}) (err error) {
 	r, ok := i.(*Ready)
 	if ok {
 		return s.onReady(se, r)
@@ -976,15 +1406,23 @@ func (s *State) OnInterface(se *Session, i interface{}) (err error) {
 			t.BeforeDelete = &oldCopy
 		}
 
-		err = s.GuildRemove(t.Guild)
+		if t.Unavailable {
+			// The guild is unavailable because of an outage, keep it
+			// until the guild is available again with a GuildCreate.
+			if old != nil {
+				s.Lock()
+				old.Unavailable = true
+				s.Unlock()
+			}
+		} else {
+			err = s.GuildRemove(t.Guild)
+		}
 	case *GuildMemberAdd:
-		var guild *Guild
 		// Updates the MemberCount of the guild.
-		guild, err = s.Guild(t.Member.GuildID)
+		err = s.guildMemberCountAdd(t.Member.GuildID, 1)
 		if err != nil {
 			return err
 		}
-		guild.MemberCount++
 
 		// Caches member if tracking is enabled.
 		if s.TrackMembers {
@@ -1002,13 +1440,11 @@ func (s *State) OnInterface(se *Session, i interface{}) (err error) {
 			err = s.MemberAdd(t.Member)
 		}
 	case *GuildMemberRemove:
-		var guild *Guild
 		// Updates the MemberCount of the guild.
-		guild, err = s.Guild(t.Member.GuildID)
+		err = s.guildMemberCountAdd(t.Member.GuildID, -1)
 		if err != nil {
 			return err
 		}
-		guild.MemberCount--
 
 		// Removes member from the cache if tracking is enabled.
 		if s.TrackMembers {
@@ -1092,6 +1528,9 @@ func (s *State) OnInterface(se *Session, i interface{}) (err error) {
 			err = s.ThreadListSync(t)
 		}
 	case *MessageCreate:
+		if s.TrackChannels {
+			s.channelLastMessage(t.ChannelID, t.ID)
+		}
 		if s.MaxMessageCount != 0 {
 			err = s.MessageAdd(t.Message)
 		}
@@ -1166,6 +1605,184 @@ func (s *State) OnInterface(se *Session, i interface{}) (err error) {
 	return
 }
 
+// writeThrough applies the changes an event made to the State to store as
+// well, passing it the updated objects of the State.
+func (s *State) writeThrough(store StateStore, i interface{}) (err error) {
+	switch t := i.(type) {
+	case *Ready:
+		for _, g := range t.Guilds {
+			if err = store.GuildAdd(g); err != nil {
+				return
+			}
+		}
+		for _, c := range t.PrivateChannels {
+			if err = store.ChannelAdd(c); err != nil {
+				return
+			}
+		}
+	case *GuildCreate:
+		err = s.writeGuild(store, t.ID)
+	case *GuildUpdate:
+		err = s.writeGuild(store, t.ID)
+	case *GuildDelete:
+		if t.Unavailable {
+			err = s.writeGuild(store, t.ID)
+		} else {
+			err = store.GuildRemove(t.Guild)
+		}
+	case *GuildEmojisUpdate:
+		if s.TrackEmojis {
+			err = s.writeGuild(store, t.GuildID)
+		}
+	case *GuildMemberAdd:
+		if s.TrackMembers {
+			err = s.writeMember(store, t.GuildID, t.User.ID)
+		}
+	case *GuildMemberUpdate:
+		if s.TrackMembers {
+			err = s.writeMember(store, t.GuildID, t.User.ID)
+		}
+	case *GuildMemberRemove:
+		if s.TrackMembers {
+			err = store.MemberRemove(t.Member)
+		}
+	case *GuildMembersChunk:
+		if s.TrackMembers {
+			for _, m := range t.Members {
+				if err = s.writeMember(store, t.GuildID, m.User.ID); err != nil {
+					return
+				}
+			}
+		}
+		if s.TrackPresences {
+			for _, p := range t.Presences {
+				if err = store.PresenceAdd(t.GuildID, p); err != nil {
+					return
+				}
+			}
+		}
+	case *GuildRoleCreate:
+		if s.TrackRoles {
+			err = store.RoleAdd(t.GuildID, t.Role)
+		}
+	case *GuildRoleUpdate:
+		if s.TrackRoles {
+			err = store.RoleAdd(t.GuildID, t.Role)
+		}
+	case *GuildRoleDelete:
+		if s.TrackRoles {
+			err = store.RoleRemove(t.GuildID, t.RoleID)
+		}
+	case *ChannelCreate:
+		if s.TrackChannels {
+			err = s.writeChannel(store, t.ID)
+		}
+	case *ChannelUpdate:
+		if s.TrackChannels {
+			err = s.writeChannel(store, t.ID)
+		}
+	case *ChannelDelete:
+		if s.TrackChannels {
+			err = store.ChannelRemove(t.Channel)
+		}
+	case *ThreadCreate:
+		if s.TrackThreads {
+			err = s.writeChannel(store, t.ID)
+		}
+	case *ThreadUpdate:
+		if s.TrackThreads {
+			err = s.writeChannel(store, t.ID)
+		}
+	case *ThreadDelete:
+		if s.TrackThreads {
+			err = store.ChannelRemove(t.Channel)
+		}
+	case *ThreadMemberUpdate:
+		if s.TrackThreads {
+			err = s.writeChannel(store, t.ID)
+		}
+	case *ThreadMembersUpdate:
+		if s.TrackThreadMembers {
+			err = s.writeChannel(store, t.ID)
+		}
+	case *ThreadListSync:
+		if s.TrackThreads {
+			for _, c := range t.Threads {
+				if err = s.writeChannel(store, c.ID); err != nil {
+					return
+				}
+			}
+		}
+	case *MessageCreate:
+		if s.MaxMessageCount != 0 {
+			err = s.writeMessage(store, t.ChannelID, t.ID)
+		}
+	case *MessageUpdate:
+		if s.MaxMessageCount != 0 {
+			err = s.writeMessage(store, t.ChannelID, t.ID)
+		}
+	case *MessageDelete:
+		if s.MaxMessageCount != 0 {
+			err = store.MessageRemove(t.Message)
+		}
+	case *MessageDeleteBulk:
+		if s.MaxMessageCount != 0 {
+			for _, mID := range t.Messages {
+				if err = store.MessageRemove(&Message{ID: mID, ChannelID: t.ChannelID}); err != nil {
+					return
+				}
+			}
+		}
+	case *PresenceUpdate:
+		if s.TrackPresences {
+			if err = store.PresenceAdd(t.GuildID, &t.Presence); err != nil {
+				return
+			}
+		}
+		if s.TrackMembers && t.Status != StatusOffline {
+			err = s.writeMember(store, t.GuildID, t.User.ID)
+		}
+	}
+
+	return
+}
+
+// writeGuild adds the guild of the State with the given ID to store. The
+// helpers below do the same for channels, members and messages. Objects
+// missing from the State, because the event could not be applied to it, are
+// skipped.
+func (s *State) writeGuild(store StateStore, guildID string) error {
+	g, err := s.Guild(guildID)
+	if err != nil {
+		return nil
+	}
+	return store.GuildAdd(g)
+}
+
+func (s *State) writeChannel(store StateStore, channelID string) error {
+	c, err := s.Channel(channelID)
+	if err != nil {
+		return nil
+	}
+	return store.ChannelAdd(c)
+}
+
+func (s *State) writeMember(store StateStore, guildID, userID string) error {
+	m, err := s.Member(guildID, userID)
+	if err != nil {
+		return nil
+	}
+	return store.MemberAdd(m)
+}
+
+func (s *State) writeMessage(store StateStore, channelID, messageID string) error {
+	m, err := s.Message(channelID, messageID)
+	if err != nil {
+		return nil
+	}
+	return store.MessageAdd(m)
+}
+
 // UserChannelPermissions returns the permission of a user in a channel.
 // userID    : The ID of the user to calculate permissions for.
 // channelID : The ID of the channel to calculate permission for.
@@ -1179,12 +1796,40 @@ func (s *State) UserChannelPermissions(userID, channelID string) (apermissions i
 		return
 	}
 
-	guild, err := s.Guild(channel.GuildID)
+	return s.MemberPermissions(channel.GuildID, channelID, userID)
+}
+
+// MemberPermissions returns the permissions of a member in a channel, computed
+// from the state without making any API requests. The guild's base role
+// permissions are combined, then the channel's @everyone, role and member
+// overwrites are applied in that order. Owners and administrators have all
+// permissions. Threads use the overwrites of their parent channel.
+// guildID   : The ID of the guild of the channel.
+// channelID : The ID of the channel to calculate permissions for.
+// userID    : The ID of the user to calculate permissions for.
+func (s *State) MemberPermissions(guildID, channelID, userID string) (apermissions int64, err error) {
+	if s == nil {
+		return 0, ErrNilState
+	}
+
+	guild, err := s.Guild(guildID)
 	if err != nil {
 		return
 	}
 
-	member, err := s.Member(guild.ID, userID)
+	channel, err := s.Channel(channelID)
+	if err != nil {
+		return
+	}
+
+	if channel.IsThread() {
+		channel, err = s.Channel(channel.ParentID)
+		if err != nil {
+			return
+		}
+	}
+
+	member, err := s.Member(guildID, userID)
 	if err != nil {
 		return
 	}
@@ -1213,6 +1858,13 @@ func (s *State) MessagePermissions(message *Message) (apermissions int64, err er
 		return
 	}
 
+	if channel.IsThread() {
+		channel, err = s.Channel(channel.ParentID)
+		if err != nil {
+			return
+		}
+	}
+
 	return memberPermissions(guild, channel, message.Author.ID, message.Member.Roles), nil
 }
 
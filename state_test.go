@@ -0,0 +1,445 @@
+package discordgo
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStateMemberByName(t *testing.T) {
+	state := NewState()
+	state.GuildAdd(&Guild{ID: "guild"})
+	state.MemberAdd(&Member{GuildID: "guild", User: &User{ID: "1", Username: "alice"}})
+	state.MemberAdd(&Member{GuildID: "guild", Nick: "Bobby", User: &User{ID: "2", Username: "bob"}})
+	state.MemberAdd(&Member{GuildID: "guild", User: &User{ID: "3", Username: "carol", GlobalName: "Caroline"}})
+
+	tests := map[string]string{
+		"ALICE":    "1",
+		"bobby":    "2",
+		"bob":      "2",
+		"caroline": "3",
+	}
+	for name, want := range tests {
+		m, err := state.MemberByName("guild", name)
+		if err != nil || m.User.ID != want {
+			t.Errorf("MemberByName(%q) = %v, %v, want user %s", name, m, err, want)
+		}
+	}
+
+	if _, err := state.MemberByName("guild", "dave"); err != ErrStateNotFound {
+		t.Errorf("MemberByName(\"dave\") error = %v, want ErrStateNotFound", err)
+	}
+
+	members, err := state.MembersSearch("guild", "B", 0)
+	if err != nil || len(members) != 1 || members[0].User.ID != "2" {
+		t.Errorf("MembersSearch(\"B\") = %v, %v", members, err)
+	}
+}
+
+func TestStateMemberPermissions(t *testing.T) {
+	state := NewState()
+	state.GuildAdd(&Guild{
+		ID:      "guild",
+		OwnerID: "owner",
+		Roles: []*Role{
+			{ID: "guild", Permissions: PermissionViewChannel | PermissionSendMessages},
+			{ID: "mod", Permissions: PermissionManageMessages},
+			{ID: "admin", Permissions: PermissionAdministrator},
+		},
+	})
+	state.ChannelAdd(&Channel{
+		ID:      "channel",
+		GuildID: "guild",
+		Type:    ChannelTypeGuildText,
+		PermissionOverwrites: []*PermissionOverwrite{
+			{ID: "guild", Type: PermissionOverwriteTypeRole, Deny: PermissionSendMessages},
+			{ID: "mod", Type: PermissionOverwriteTypeRole, Allow: PermissionSendMessages},
+			{ID: "muted", Type: PermissionOverwriteTypeMember, Deny: PermissionSendMessages},
+			{ID: "admin", Type: PermissionOverwriteTypeRole, Deny: PermissionViewChannel},
+		},
+	})
+	state.ChannelAdd(&Channel{ID: "thread", GuildID: "guild", ParentID: "channel", Type: ChannelTypeGuildPublicThread})
+	state.MemberAdd(&Member{GuildID: "guild", User: &User{ID: "member"}})
+	state.MemberAdd(&Member{GuildID: "guild", User: &User{ID: "moderator"}, Roles: []string{"mod"}})
+	state.MemberAdd(&Member{GuildID: "guild", User: &User{ID: "muted"}, Roles: []string{"mod"}})
+	state.MemberAdd(&Member{GuildID: "guild", User: &User{ID: "administrator"}, Roles: []string{"admin"}})
+	state.MemberAdd(&Member{GuildID: "guild", User: &User{ID: "owner"}})
+
+	tests := []struct {
+		userID  string
+		channel string
+		want    int64
+	}{
+		{"member", "channel", PermissionViewChannel},
+		{"moderator", "channel", PermissionViewChannel | PermissionSendMessages | PermissionManageMessages},
+		{"muted", "channel", PermissionViewChannel | PermissionManageMessages},
+		{"moderator", "thread", PermissionViewChannel | PermissionSendMessages | PermissionManageMessages},
+		{"administrator", "channel", PermissionAll},
+		{"owner", "channel", PermissionAll},
+	}
+	for _, tt := range tests {
+		perms, err := state.MemberPermissions("guild", tt.channel, tt.userID)
+		if err != nil {
+			t.Errorf("MemberPermissions(%s, %s) returned error: %s", tt.channel, tt.userID, err)
+			continue
+		}
+		if perms != tt.want {
+			t.Errorf("MemberPermissions(%s, %s) = %d, want %d", tt.channel, tt.userID, perms, tt.want)
+		}
+	}
+}
+
+func TestStateMessageCache(t *testing.T) {
+	state := NewState()
+	state.MaxMessageCount = 2
+	state.GuildAdd(&Guild{ID: "guild"})
+	state.ChannelAdd(&Channel{ID: "channel", GuildID: "guild"})
+
+	for _, id := range []string{"1", "2", "3"} {
+		if err := state.MessageAdd(&Message{ID: id, ChannelID: "channel", Content: "message " + id}); err != nil {
+			t.Fatalf("MessageAdd(%s) returned error: %s", id, err)
+		}
+	}
+	state.MessageAdd(&Message{ID: "2", ChannelID: "channel", Content: "edited"})
+
+	if _, err := state.Message("channel", "1"); err != ErrStateNotFound {
+		t.Errorf("oldest message was not evicted, got error %v", err)
+	}
+	if m, err := state.Message("channel", "2"); err != nil || m.Content != "edited" {
+		t.Errorf("Message(2) = %v, %v, want edited message", m, err)
+	}
+
+	if err := state.MessageRemove(&Message{ID: "3", ChannelID: "channel"}); err != nil {
+		t.Errorf("MessageRemove(3) returned error: %s", err)
+	}
+	if _, err := state.Message("channel", "3"); err != ErrStateNotFound {
+		t.Errorf("removed message still found, got error %v", err)
+	}
+
+	c, _ := state.Channel("channel")
+	if len(c.Messages) != 1 || c.Messages[0].ID != "2" {
+		t.Errorf("unexpected channel messages %v", c.Messages)
+	}
+
+	// Replacing the messages outside of the state leaves the index pointing
+	// past the end, which must not be trusted.
+	c.Messages = []*Message{{ID: "4", ChannelID: "channel"}}
+	if _, err := messagePosition(c, "4"); err == nil || err == ErrStateNotFound {
+		t.Errorf("messagePosition() returned error %v for an out of sync index, want a description of the mismatch", err)
+	}
+	if m, err := state.Message("channel", "4"); err != nil || m.ID != "4" {
+		t.Errorf("Message(4) = %v, %v with an out of sync index, want message 4", m, err)
+	}
+	if err := state.MessageRemove(&Message{ID: "4", ChannelID: "channel"}); err != nil || len(c.Messages) != 0 {
+		t.Errorf("MessageRemove(4) returned error %v with an out of sync index, %d messages left", err, len(c.Messages))
+	}
+}
+
+func TestStateGuildMemberCount(t *testing.T) {
+	state := NewState()
+	session := &Session{StateEnabled: true, State: state}
+
+	if err := state.GuildAdd(&Guild{ID: "approximate", ApproximateMemberCount: 100}); err != nil {
+		t.Fatal(err)
+	}
+	if count, exact, err := state.GuildMemberCount("approximate"); err != nil || count != 100 || exact {
+		t.Errorf("got count %d, exact %t, err %v, want approximate count 100", count, exact, err)
+	}
+
+	if err := state.OnInterface(session, &GuildCreate{&Guild{ID: "guild", MemberCount: 2}}); err != nil {
+		t.Fatal(err)
+	}
+	member := &Member{GuildID: "guild", User: &User{ID: "user"}}
+	if err := state.OnInterface(session, &GuildMemberAdd{member}); err != nil {
+		t.Fatal(err)
+	}
+	if count, exact, err := state.GuildMemberCount("guild"); err != nil || count != 3 || !exact {
+		t.Errorf("got count %d, exact %t, err %v, want exact count 3", count, exact, err)
+	}
+	if err := state.OnInterface(session, &GuildMemberRemove{member}); err != nil {
+		t.Fatal(err)
+	}
+	if count, _, _ := state.GuildMemberCount("guild"); count != 2 {
+		t.Errorf("got count %d after removing a member, want 2", count)
+	}
+
+	if _, _, err := state.GuildMemberCount("unknown"); err != ErrStateNotFound {
+		t.Errorf("got error %v for an unknown guild, want ErrStateNotFound", err)
+	}
+}
+
+func TestShardStatesPresence(t *testing.T) {
+	shard0, shard1 := NewState(), NewState()
+	if err := shard0.GuildAdd(&Guild{ID: "guild0"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := shard1.GuildAdd(&Guild{ID: "guild1", Presences: []*Presence{{User: &User{ID: "user"}, Status: StatusIdle}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	states := ShardStates{shard0, shard1}
+	p, err := states.Presence("user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Status != StatusIdle {
+		t.Errorf("got status %s, want %s", p.Status, StatusIdle)
+	}
+
+	if _, err := states.Presence("unknown"); err != ErrStateNotFound {
+		t.Errorf("got error %v for an unknown user, want ErrStateNotFound", err)
+	}
+}
+
+func TestStateGuildMembersFilter(t *testing.T) {
+	var member Member
+	err := json.Unmarshal([]byte(`{"joined_at":"2015-04-26T06:26:56.936000+00:00","pending":true,"user":{"id":"1"}}`), &member)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2015, time.April, 26, 6, 26, 56, 936000000, time.UTC); !member.JoinedAt.Equal(want) {
+		t.Errorf("got JoinedAt %v, want %v", member.JoinedAt, want)
+	}
+
+	state := NewState()
+	state.GuildAdd(&Guild{ID: "guild"})
+	member.GuildID = "guild"
+	state.MemberAdd(&member)
+	state.MemberAdd(&Member{GuildID: "guild", JoinedAt: time.Now(), User: &User{ID: "2"}})
+
+	pending, err := state.GuildMembersFilter("guild", func(m *Member) bool { return m.Pending })
+	if err != nil || len(pending) != 1 || pending[0].User.ID != "1" {
+		t.Errorf("GuildMembersFilter(pending) = %v, %v", pending, err)
+	}
+
+	cutoff := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	recent, err := state.GuildMembersFilter("guild", func(m *Member) bool { return m.JoinedAt.After(cutoff) })
+	if err != nil || len(recent) != 1 || recent[0].User.ID != "2" {
+		t.Errorf("GuildMembersFilter(recent) = %v, %v", recent, err)
+	}
+}
+
+func TestStateChannelLastMessage(t *testing.T) {
+	state := NewState()
+	state.GuildAdd(&Guild{ID: "guild", Channels: []*Channel{{ID: "channel", GuildID: "guild", LastMessageID: "99"}}})
+
+	for _, id := range []string{"100", "98"} {
+		if err := state.OnInterface(&Session{StateEnabled: true}, &MessageCreate{Message: &Message{ID: id, ChannelID: "channel"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c, err := state.Channel("channel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.LastMessageID != "100" {
+		t.Errorf("got last message ID %s, want 100", c.LastMessageID)
+	}
+}
+
+func TestStateGuildUnavailable(t *testing.T) {
+	state := NewState()
+	se := &Session{StateEnabled: true}
+	state.OnInterface(se, &GuildCreate{&Guild{ID: "guild", Name: "guild", Channels: []*Channel{{ID: "channel", GuildID: "guild"}}}})
+
+	if err := state.OnInterface(se, &GuildDelete{Guild: &Guild{ID: "guild", Unavailable: true}}); err != nil {
+		t.Fatal(err)
+	}
+	g, err := state.Guild("guild")
+	if err != nil {
+		t.Fatalf("guild removed from the state during an outage: %v", err)
+	}
+	if !g.Unavailable || g.Name != "guild" {
+		t.Errorf("got guild %+v, want the unavailable guild", g)
+	}
+
+	state.OnInterface(se, &GuildCreate{&Guild{ID: "guild", Name: "guild"}})
+	if g, err = state.Guild("guild"); err != nil || g.Unavailable || len(g.Channels) != 1 {
+		t.Errorf("got guild %+v, %v, want the available guild with its channels", g, err)
+	}
+
+	state.OnInterface(se, &GuildDelete{Guild: &Guild{ID: "guild"}})
+	if _, err = state.Guild("guild"); err != ErrStateNotFound {
+		t.Errorf("got error %v after leaving the guild, want ErrStateNotFound", err)
+	}
+}
+
+func TestStateMemberDeduplication(t *testing.T) {
+	state := NewState()
+	session := &Session{StateEnabled: true, State: state}
+
+	member := func(nick string) *Member {
+		return &Member{User: &User{ID: "user"}, Nick: nick}
+	}
+	if err := state.OnInterface(session, &GuildCreate{&Guild{ID: "guild", Members: []*Member{member("create"), member("duplicate")}}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := state.OnInterface(session, &GuildMembersChunk{GuildID: "guild", Members: []*Member{member("chunk")}}); err != nil {
+		t.Fatal(err)
+	}
+
+	guild, err := state.Guild("guild")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(guild.Members) != 1 {
+		t.Fatalf("got %d members in the guild, want 1", len(guild.Members))
+	}
+	m, err := state.Member("guild", "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m != guild.Members[0] || m.Nick != "chunk" {
+		t.Errorf("member %+v is not the single up to date entry %+v", m, guild.Members[0])
+	}
+}
+
+func TestStateGuildAddConcurrentReads(t *testing.T) {
+	state := NewState()
+	members := make([]*Member, 1000)
+	for i := range members {
+		members[i] = &Member{User: &User{ID: strconv.Itoa(i)}}
+	}
+	// The duplicate makes the state deduplicate the members on every add.
+	members = append(members, &Member{User: &User{ID: "0"}})
+	state.GuildAdd(&Guild{ID: "guild", Members: members})
+	guild, _ := state.Guild("guild")
+	guild.Members = append(guild.Members, members[len(members)-1])
+
+	// Adding the guild held by the state again must only change it while
+	// holding the lock, run with -race to check.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			state.RLock()
+			_ = len(guild.Members)
+			state.RUnlock()
+			if m, err := state.Member("guild", "0"); err == nil {
+				_ = m.Nick
+			}
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		if err := state.GuildAdd(guild); err != nil {
+			t.Fatal(err)
+		}
+	}
+	<-done
+
+	if len(guild.Members) != 1000 {
+		t.Errorf("got %d members, want 1000", len(guild.Members))
+	}
+}
+
+func TestStateReadyInfo(t *testing.T) {
+	var ready Ready
+	data := `{"v": 10, "session_id": "session", "resume_gateway_url": "wss://resume.discord.gg", "user": {"id": "bot"},
+		"shard": [1, 4], "application": {"id": "app", "flags": 8388608}, "guilds": [{"id": "guild", "unavailable": true}]}`
+	if err := json.Unmarshal([]byte(data), &ready); err != nil {
+		t.Fatal(err)
+	}
+
+	state := NewState()
+	if err := state.OnInterface(&Session{StateEnabled: false}, &ready); err != nil {
+		t.Fatal(err)
+	}
+	if state.ApplicationID() != "app" || state.Application.Flags != ApplicationFlagApplicationCommandBadge {
+		t.Errorf("got application %+v", state.Application)
+	}
+	if state.ShardID() != 1 || state.ShardCount() != 4 {
+		t.Errorf("got shard %d of %d, want 1 of 4", state.ShardID(), state.ShardCount())
+	}
+	if state.User == nil || state.User.ID != "bot" || state.ResumeGatewayURL != "wss://resume.discord.gg" {
+		t.Errorf("got user %+v and resume URL %q", state.User, state.ResumeGatewayURL)
+	}
+
+	var unsharded Ready
+	if unsharded.ApplicationID() != "" || unsharded.ShardID() != 0 || unsharded.ShardCount() != 1 {
+		t.Errorf("unexpected defaults for an empty Ready")
+	}
+}
+
+func TestStateThreadArchive(t *testing.T) {
+	state := NewState()
+	se := &Session{StateEnabled: true}
+	thread := func(archived, locked bool) *Channel {
+		return &Channel{ID: "thread", GuildID: "guild", Type: ChannelTypeGuildPublicThread, ThreadMetadata: &ThreadMetadata{Archived: archived, Locked: locked}}
+	}
+	state.OnInterface(se, &GuildCreate{&Guild{ID: "guild", Threads: []*Channel{thread(false, false)}}})
+
+	if threads, err := state.ActiveThreads("guild"); err != nil || len(threads) != 1 {
+		t.Fatalf("ActiveThreads() = %v, %v, want the thread", threads, err)
+	}
+
+	archive := &ThreadUpdate{Channel: thread(true, true)}
+	state.OnInterface(se, archive)
+	if !archive.BecameArchived() || archive.BecameUnarchived() || !archive.Archived() || !archive.Locked() {
+		t.Errorf("archive update not detected, before %+v", archive.BeforeUpdate)
+	}
+	if threads, err := state.ActiveThreads("guild"); err != nil || len(threads) != 0 {
+		t.Errorf("ActiveThreads() = %v, %v, want no threads", threads, err)
+	}
+
+	unarchive := &ThreadUpdate{Channel: thread(false, false)}
+	state.OnInterface(se, unarchive)
+	if unarchive.BecameArchived() || !unarchive.BecameUnarchived() || unarchive.Archived() || unarchive.Locked() {
+		t.Errorf("unarchive update not detected, before %+v", unarchive.BeforeUpdate)
+	}
+
+	if (&ThreadUpdate{Channel: thread(true, false)}).BecameArchived() {
+		t.Errorf("BecameArchived() = true without a previous state")
+	}
+}
+
+// recordingStore is a StateStore which records the objects written to it.
+type recordingStore struct {
+	*State
+	writes []string
+}
+
+func (r *recordingStore) GuildAdd(g *Guild) error {
+	r.writes = append(r.writes, "guild "+g.ID)
+	return r.State.GuildAdd(g)
+}
+
+func (r *recordingStore) ChannelAdd(c *Channel) error {
+	r.writes = append(r.writes, "channel "+c.ID+" "+c.Name)
+	return r.State.ChannelAdd(c)
+}
+
+func (r *recordingStore) ChannelRemove(c *Channel) error {
+	r.writes = append(r.writes, "remove channel "+c.ID)
+	return r.State.ChannelRemove(c)
+}
+
+func TestStateStoreWriteThrough(t *testing.T) {
+	store := &recordingStore{State: NewState()}
+	se := &Session{StateEnabled: true, State: NewState(), Store: store}
+
+	if err := se.State.OnInterface(se, &GuildCreate{&Guild{ID: "guild", Channels: []*Channel{{ID: "channel", GuildID: "guild", Name: "old"}}}}); err != nil {
+		t.Fatal(err)
+	}
+	update := &ThreadUpdate{Channel: &Channel{ID: "thread", GuildID: "guild", Type: ChannelTypeGuildPublicThread, Name: "thread"}}
+	se.State.OnInterface(se, &ThreadCreate{Channel: &Channel{ID: "thread", GuildID: "guild", Type: ChannelTypeGuildPublicThread, Name: "before"}})
+	se.State.OnInterface(se, update)
+	se.State.OnInterface(se, &ChannelDelete{Channel: &Channel{ID: "channel", GuildID: "guild"}})
+
+	want := []string{"guild guild", "channel thread before", "channel thread thread", "remove channel channel"}
+	if strings.Join(store.writes, ",") != strings.Join(want, ",") {
+		t.Errorf("got writes %q, want %q", store.writes, want)
+	}
+	if update.BeforeUpdate == nil || update.BeforeUpdate.Name != "before" {
+		t.Errorf("BeforeUpdate = %+v, want the thread before the update", update.BeforeUpdate)
+	}
+	if c, err := se.stateStore().Channel("thread"); err != nil || c.Name != "thread" {
+		t.Errorf("store lookup = %v, %v, want the updated thread", c, err)
+	}
+
+	if (&Session{}).stateStore() != nil {
+		t.Errorf("stateStore() without a state is not nil")
+	}
+}
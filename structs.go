@@ -17,6 +17,7 @@ import (
 	"math"
 	"net/http"
 	"regexp"
+	"strings"
 	"sync"
 	"time"
 
@@ -39,7 +40,18 @@ type Session struct {
 	Debug    bool // Deprecated, will be removed.
 	LogLevel int
 
+	// Logger, if set, replaces the package level Logger for messages
+	// logged by this session.
+	Logger func(msgL, caller int, format string, a ...interface{})
+
+	// OnUnknownEnum, if set, is called instead of logging a warning the
+	// first time a channel or message type missing from this package is
+	// received in an event, with the name of the enum type and the value.
+	OnUnknownEnum func(name string, value int)
+
 	// Should the session reconnect the websocket on errors.
+	// When false, the Disconnect event carries the reason the connection
+	// was closed and Reconnect may be called to reconnect manually.
 	ShouldReconnectOnError bool
 
 	// Should the session retry requests when rate limited.
@@ -62,10 +74,40 @@ type Session struct {
 	// active guilds and the members of the guilds.
 	StateEnabled bool
 
+	// Whether REST getters (Guild, Channel, ChannelMessage, GuildMember and
+	// User) should consult the state before making a request to the API.
+	// Has no effect unless StateEnabled is true.
+	PreferState bool
+
 	// Whether or not to call event handlers synchronously.
 	// e.g. false = launch event handlers in their own goroutines.
 	SyncEvents bool
 
+	// The maximum number of event handlers called concurrently when
+	// SyncEvents is false. When 0, every handler call is launched in its own
+	// goroutine, otherwise calls are run by a pool of that many goroutines,
+	// started on the first event and stopped by Close. Must be set before
+	// the first event.
+	MaxConcurrentHandlers int
+
+	// The number of handler calls queued while all goroutines of the pool
+	// are busy. Once full, dispatching events blocks until a handler
+	// finishes, unless DropEventsWhenQueueFull is set.
+	HandlerQueueSize int
+
+	// Whether to drop handler calls instead of waiting when the handler
+	// queue is full, see DroppedHandlerCalls.
+	DropEventsWhenQueueFull bool
+
+	// The maximum number of events held back from event handlers until
+	// ReleaseEvents is called, so that handlers added after Open, such as
+	// handlers set up using the data of the READY event, receive the events
+	// of the initial burst as well. Events are still processed by the State
+	// as they arrive. Once the buffer is full, the buffered events are
+	// dispatched and buffering ends, as if ReleaseEvents was called.
+	// Buffering only happens once per session; 0 disables it.
+	BufferEvents int
+
 	// Exposed but should not be modified by User.
 
 	// Whether the Data Websocket is ready
@@ -74,6 +116,44 @@ type Session struct {
 	// Max number of REST API retries
 	MaxRestRetries int
 
+	// The longest time a request waits for a rate limit to reset, 0 means
+	// no limit. Requests which would wait longer, e.g. because clock skew
+	// puts a reset far into the future, fail with a RateLimitError instead.
+	MaxRateLimitWait time.Duration
+
+	// Max number of commands (such as status updates) sent over the
+	// gateway per minute, 0 disables the limit. Discord closes connections
+	// which exceed 120 commands per minute, heartbeats included.
+	GatewayCommandLimit int
+
+	// Should gateway commands wait for GatewayCommandLimit to allow them,
+	// instead of failing with ErrGatewayRateLimited.
+	ShouldWaitOnGatewayRateLimit bool
+
+	// Overrides the heartbeat interval sent by the gateway when non-zero,
+	// e.g. to test heartbeats against a mock gateway without waiting for
+	// the interval of Discord. A connection is restarted as a zombie when
+	// no ACK was received for FailedHeartbeatAcks intervals.
+	HeartbeatInterval time.Duration
+
+	// Whether Open, and reconnects, refuse to identify once the daily
+	// session start limit returned by GatewayBot is used up, returning a
+	// *SessionStartLimitError instead. Resuming sessions is not limited.
+	CheckSessionStartLimit bool
+
+	// Whether to check the permissions of the current user in the state
+	// before sending messages, returning a *PermissionError instead of
+	// making a request which would fail. Has no effect for channels which
+	// are not fully known to the state.
+	CheckPermissions bool
+
+	// How long ChannelVoiceJoin waits for the voice connection to be ready.
+	VoiceJoinTimeout time.Duration
+
+	// DefaultAllowedMentions is applied to every message sent by the session
+	// whose AllowedMentions is nil. Per-message AllowedMentions always win.
+	DefaultAllowedMentions *MessageAllowedMentions
+
 	// Status stores the current status of the websocket connection
 	// this is being tested, may stay, may go away.
 	status int32
@@ -91,6 +171,11 @@ type Session struct {
 	// StateEnabled is true.
 	State *State
 
+	// Store is an optional state backend. When set, State writes the changes
+	// made by events through to Store, and library lookups consult Store.
+	// Has no effect unless StateEnabled is true.
+	Store StateStore
+
 	// The http client used for REST requests
 	Client *http.Client
 
@@ -100,6 +185,24 @@ type Session struct {
 	// The user agent used for REST APIs
 	UserAgent string
 
+	// The Discord API version used for REST requests and the gateway
+	// connection. Defaults to the package level APIVersion.
+	APIVersion string
+
+	// Optional URL of the gateway to connect to, instead of the URL
+	// returned by the API. Version and encoding parameters are appended.
+	GatewayURL string
+
+	// Optional cache of REST responses, used for conditional requests
+	// with ETags. Nil disables caching.
+	ResponseCache *ResponseCache
+
+	// Middleware called around every REST request, including retries,
+	// for example for tracing or metrics. The first middleware is the
+	// outermost, and is the first to see the request and last to see the
+	// response. See RequestMiddleware.
+	RequestMiddleware []RequestMiddleware
+
 	// Stores the last HeartbeatAck that was received (in UTC)
 	LastHeartbeatAck time.Time
 
@@ -114,6 +217,19 @@ type Session struct {
 	handlers     map[string][]*eventHandlerInstance
 	onceHandlers map[string][]*eventHandlerInstance
 
+	// Runs event handlers when MaxConcurrentHandlers is set
+	handlerPool handlerPool
+
+	// Holds events back from handlers when BufferEvents is set
+	eventBuffer eventBuffer
+
+	// Sessions created with Clone, which events are also dispatched to
+	clonesMu sync.RWMutex
+	clones   []*Session
+
+	// The Session this one was cloned from, until it is detached
+	parent *Session
+
 	// The websocket connection.
 	wsConn *websocket.Conn
 
@@ -129,30 +245,133 @@ type Session struct {
 	// stores session ID of current Gateway connection
 	sessionID string
 
+	// stores the Gateway to resume the current session on
+	resumeGatewayURL string
+
+	// stores the IDs of the guilds of the current user, to tell joined
+	// guilds from guilds becoming available
+	knownGuilds map[string]bool
+
+	// stores the unknown enum values which have been logged, see
+	// logUnknownEnums
+	unknownEnums sync.Map
+
+	// stores the IDs of the guilds listed in READY which have not been
+	// received yet, and when READY was received
+	pendingGuilds map[string]bool
+	readyAt       time.Time
+
+	// the session start limit last returned by GatewayBot
+	sessionStartLimit sessionStartLimit
+
 	// used to make sure gateway websocket writes do not happen concurrently
 	wsMutex sync.Mutex
+
+	// paces gateway commands according to GatewayCommandLimit
+	gatewayLimiter gatewayRateLimiter
+
+	// tracks the state of the gateway connection
+	connState connectionStateTracker
+
+	// callbacks for raw gateway messages
+	rawGateway rawGatewayHooks
+
+	// counts of dispatched gateway events by type
+	eventStatsMu sync.Mutex
+	eventStats   map[string]uint64
 }
 
 // Application stores values for a Discord Application
 type Application struct {
-	ID                  string   `json:"id,omitempty"`
-	Name                string   `json:"name"`
-	Icon                string   `json:"icon,omitempty"`
-	Description         string   `json:"description,omitempty"`
-	RPCOrigins          []string `json:"rpc_origins,omitempty"`
-	BotPublic           bool     `json:"bot_public,omitempty"`
-	BotRequireCodeGrant bool     `json:"bot_require_code_grant,omitempty"`
-	TermsOfServiceURL   string   `json:"terms_of_service_url"`
-	PrivacyProxyURL     string   `json:"privacy_policy_url"`
-	Owner               *User    `json:"owner"`
-	Summary             string   `json:"summary"`
-	VerifyKey           string   `json:"verify_key"`
-	Team                *Team    `json:"team"`
-	GuildID             string   `json:"guild_id"`
-	PrimarySKUID        string   `json:"primary_sku_id"`
-	Slug                string   `json:"slug"`
-	CoverImage          string   `json:"cover_image"`
-	Flags               int      `json:"flags,omitempty"`
+	ID                             string                                                           `json:"id,omitempty"`
+	Name                           string                                                           `json:"name"`
+	Icon                           string                                                           `json:"icon,omitempty"`
+	Description                    string                                                           `json:"description,omitempty"`
+	RPCOrigins                     []string                                                         `json:"rpc_origins,omitempty"`
+	BotPublic                      bool                                                             `json:"bot_public,omitempty"`
+	BotRequireCodeGrant            bool                                                             `json:"bot_require_code_grant,omitempty"`
+	TermsOfServiceURL              string                                                           `json:"terms_of_service_url"`
+	PrivacyProxyURL                string                                                           `json:"privacy_policy_url"`
+	Owner                          *User                                                            `json:"owner"`
+	Summary                        string                                                           `json:"summary"`
+	VerifyKey                      string                                                           `json:"verify_key"`
+	Team                           *Team                                                            `json:"team"`
+	GuildID                        string                                                           `json:"guild_id"`
+	PrimarySKUID                   string                                                           `json:"primary_sku_id"`
+	Slug                           string                                                           `json:"slug"`
+	CoverImage                     string                                                           `json:"cover_image"`
+	Flags                          int                                                              `json:"flags,omitempty"`
+	Tags                           []string                                                         `json:"tags,omitempty"`
+	InstallParams                  *ApplicationInstallParams                                        `json:"install_params,omitempty"`
+	IntegrationTypesConfig         map[ApplicationIntegrationType]*ApplicationIntegrationTypeConfig `json:"integration_types_config,omitempty"`
+	CustomInstallURL               string                                                           `json:"custom_install_url,omitempty"`
+	InteractionsEndpointURL        string                                                           `json:"interactions_endpoint_url,omitempty"`
+	RoleConnectionsVerificationURL string                                                           `json:"role_connections_verification_url,omitempty"`
+	ApproximateGuildCount          int                                                              `json:"approximate_guild_count,omitempty"`
+}
+
+// ApplicationFlags are the flags of an Application.
+type ApplicationFlags int
+
+// Application flags.
+const (
+	ApplicationFlagAutoModerationRuleCreateBadge = 1 << 6
+	ApplicationFlagGatewayPresence               = 1 << 12
+	ApplicationFlagGatewayPresenceLimited        = 1 << 13
+	ApplicationFlagGatewayGuildMembers           = 1 << 14
+	ApplicationFlagGatewayGuildMembersLimited    = 1 << 15
+	ApplicationFlagVerificationPendingGuildLimit = 1 << 16
+	ApplicationFlagEmbedded                      = 1 << 17
+	ApplicationFlagGatewayMessageContent         = 1 << 18
+	ApplicationFlagGatewayMessageContentLimited  = 1 << 19
+	ApplicationFlagApplicationCommandBadge       = 1 << 23
+)
+
+// Has returns whether all of the given flags are set.
+func (f ApplicationFlags) Has(flags ApplicationFlags) bool {
+	return f&flags == flags
+}
+
+// ApplicationIntegrationType is where an Application can be installed.
+type ApplicationIntegrationType int
+
+// Application integration types.
+const (
+	// ApplicationIntegrationGuildInstall means the application can be installed to guilds.
+	ApplicationIntegrationGuildInstall ApplicationIntegrationType = 0
+	// ApplicationIntegrationUserInstall means the application can be installed to users.
+	ApplicationIntegrationUserInstall ApplicationIntegrationType = 1
+)
+
+// ApplicationInstallParams are the settings used to add an Application to a guild
+// or user with its default in-app authorization link.
+type ApplicationInstallParams struct {
+	Scopes      []string `json:"scopes"`
+	Permissions int64    `json:"permissions,string"`
+}
+
+// ApplicationIntegrationTypeConfig is the configuration of an integration type of an Application.
+type ApplicationIntegrationTypeConfig struct {
+	OAuth2InstallParams *ApplicationInstallParams `json:"oauth2_install_params,omitempty"`
+}
+
+// ApplicationParams are the fields of the current Application which can be
+// edited with ApplicationEditMe. Fields left empty are not changed.
+type ApplicationParams struct {
+	Description                    *string                                                          `json:"description,omitempty"`
+	CustomInstallURL               *string                                                          `json:"custom_install_url,omitempty"`
+	RoleConnectionsVerificationURL *string                                                          `json:"role_connections_verification_url,omitempty"`
+	InteractionsEndpointURL        *string                                                          `json:"interactions_endpoint_url,omitempty"`
+	InstallParams                  *ApplicationInstallParams                                        `json:"install_params,omitempty"`
+	IntegrationTypesConfig         map[ApplicationIntegrationType]*ApplicationIntegrationTypeConfig `json:"integration_types_config,omitempty"`
+	// Only the limited gateway intent flags can be changed.
+	Flags *ApplicationFlags `json:"flags,omitempty"`
+	// A base64 encoded image data URI, e.g. "data:image/png;base64,...".
+	Icon string `json:"icon,omitempty"`
+	// A base64 encoded image data URI.
+	CoverImage string `json:"cover_image,omitempty"`
+	// Up to 5 tags describing the application.
+	Tags *[]string `json:"tags,omitempty"`
 }
 
 // ApplicationRoleConnectionMetadataType represents the type of application role connection metadata.
@@ -269,6 +488,30 @@ type Invite struct {
 	ExpiresAt *time.Time `json:"expires_at"`
 }
 
+// InviteMaxAge is the longest an invite can be valid for.
+const InviteMaxAge = 7 * 24 * time.Hour
+
+// InviteParams stores the parameters for creating an invite with
+// ChannelInviteCreateComplex.
+type InviteParams struct {
+	// How long the invite is valid for, in seconds precision and at most
+	// InviteMaxAge. Zero creates an invite which never expires.
+	MaxAge time.Duration
+	// The number of times the invite can be used, up to 100. Zero is unlimited.
+	MaxUses int
+	// Whether the invite only grants temporary membership.
+	Temporary bool
+	// Whether to always create a new invite, instead of reusing a similar one.
+	Unique bool
+
+	// The type of target of a voice channel invite, with the user
+	// streaming for InviteTargetStream or the application for
+	// InviteTargetEmbeddedApplication.
+	TargetType          InviteTargetType
+	TargetUserID        string
+	TargetApplicationID string
+}
+
 // ChannelType is the type of a Channel
 type ChannelType int
 
@@ -286,6 +529,33 @@ const (
 	ChannelTypeGuildPrivateThread ChannelType = 12
 	ChannelTypeGuildStageVoice    ChannelType = 13
 	ChannelTypeGuildForum         ChannelType = 15
+	ChannelTypeGuildMedia         ChannelType = 16
+)
+
+// IsKnown returns whether the type is one of the ChannelType constants of
+// this package. Discord adds new channel types from time to time, which code
+// switching over the type of a channel may want to handle separately.
+func (t ChannelType) IsKnown() bool {
+	switch t {
+	case ChannelTypeGuildText, ChannelTypeDM, ChannelTypeGuildVoice, ChannelTypeGroupDM,
+		ChannelTypeGuildCategory, ChannelTypeGuildNews, ChannelTypeGuildStore,
+		ChannelTypeGuildNewsThread, ChannelTypeGuildPublicThread, ChannelTypeGuildPrivateThread,
+		ChannelTypeGuildStageVoice, ChannelTypeGuildForum, ChannelTypeGuildMedia:
+		return true
+	}
+	return false
+}
+
+// VideoQualityMode is the camera video quality mode of a voice channel.
+// https://discord.com/developers/docs/resources/channel#channel-object-video-quality-modes
+type VideoQualityMode int
+
+// Block containing known VideoQualityMode values.
+const (
+	// VideoQualityModeAuto lets Discord choose the quality for optimal performance.
+	VideoQualityModeAuto VideoQualityMode = 1
+	// VideoQualityModeFull uses 720p.
+	VideoQualityModeFull VideoQualityMode = 2
 )
 
 // ChannelFlags represent flags of a channel/thread.
@@ -369,16 +639,29 @@ type Channel struct {
 	// The recipients of the channel. This is only populated in DM channels.
 	Recipients []*User `json:"recipients"`
 
-	// The messages in the channel. This is only present in state-cached channels,
-	// and State.MaxMessageCount must be non-zero.
+	// The messages in the channel, oldest first. This is only present in
+	// state-cached channels, and State.MaxMessageCount must be non-zero.
+	// It should not be modified, as the state indexes it.
 	Messages []*Message `json:"-"`
 
+	// Positions of Messages by ID, maintained by the state. Positions are
+	// counted from the first message indexed, so messageOffset is the
+	// position of Messages[0].
+	messageIndex  map[string]int
+	messageOffset int
+
 	// A list of permission overwrites present for the channel.
 	PermissionOverwrites []*PermissionOverwrite `json:"permission_overwrites"`
 
 	// The user limit of the voice channel.
 	UserLimit int `json:"user_limit"`
 
+	// The voice region ID of the voice channel, automatic when empty.
+	RTCRegion string `json:"rtc_region"`
+
+	// The camera video quality mode of the voice channel.
+	VideoQualityMode VideoQualityMode `json:"video_quality_mode"`
+
 	// The ID of the parent channel, if the channel is under a category. For threads - id of the channel thread was created in.
 	ParentID string `json:"parent_id"`
 
@@ -435,6 +718,42 @@ func (c *Channel) IsThread() bool {
 	return c.Type == ChannelTypeGuildPublicThread || c.Type == ChannelTypeGuildPrivateThread || c.Type == ChannelTypeGuildNewsThread
 }
 
+func threadArchived(c *Channel) bool {
+	return c.ThreadMetadata != nil && c.ThreadMetadata.Archived
+}
+
+// IsText is a helper function to determine if channel is a text channel,
+// including DMs and announcement channels but not threads.
+func (c *Channel) IsText() bool {
+	switch c.Type {
+	case ChannelTypeGuildText, ChannelTypeDM, ChannelTypeGroupDM, ChannelTypeGuildNews:
+		return true
+	}
+	return false
+}
+
+// IsVoice is a helper function to determine if channel is a voice or stage channel
+func (c *Channel) IsVoice() bool {
+	return c.Type == ChannelTypeGuildVoice || c.Type == ChannelTypeGuildStageVoice
+}
+
+// IsForum is a helper function to determine if channel is a forum or media channel
+func (c *Channel) IsForum() bool {
+	return c.Type == ChannelTypeGuildForum || c.Type == ChannelTypeGuildMedia
+}
+
+// IsCategory is a helper function to determine if channel is a category or not
+func (c *Channel) IsCategory() bool {
+	return c.Type == ChannelTypeGuildCategory
+}
+
+// HasUnread reports whether the channel has messages newer than lastSeenID,
+// the ID of the last message which has been read. Messages are always unread
+// if lastSeenID is empty.
+func (c *Channel) HasUnread(lastSeenID string) bool {
+	return c.LastMessageID != "" && SnowflakeCompare(c.LastMessageID, lastSeenID) > 0
+}
+
 // A ChannelEdit holds Channel Field data for a channel edit.
 type ChannelEdit struct {
 	Name                          string                 `json:"name,omitempty"`
@@ -449,6 +768,13 @@ type ChannelEdit struct {
 	Flags                         *ChannelFlags          `json:"flags,omitempty"`
 	DefaultThreadRateLimitPerUser *int                   `json:"default_thread_rate_limit_per_user,omitempty"`
 
+	// NOTE: voice and stage channels only
+
+	// The voice region ID of the channel. A pointer to an empty string
+	// resets it to automatic region selection, nil leaves it unchanged.
+	RTCRegion        *string           `json:"rtc_region,omitempty"`
+	VideoQualityMode *VideoQualityMode `json:"video_quality_mode,omitempty"`
+
 	// NOTE: threads only
 
 	Archived            *bool `json:"archived,omitempty"`
@@ -456,7 +782,7 @@ type ChannelEdit struct {
 	Locked              *bool `json:"locked,omitempty"`
 	Invitable           *bool `json:"invitable,omitempty"`
 
-	// NOTE: forum channels only
+	// NOTE: forum and media channels only
 
 	AvailableTags        *[]ForumTag           `json:"available_tags,omitempty"`
 	DefaultReactionEmoji *ForumDefaultReaction `json:"default_reaction_emoji,omitempty"`
@@ -467,6 +793,23 @@ type ChannelEdit struct {
 	AppliedTags *[]string `json:"applied_tags,omitempty"`
 }
 
+// MarshalJSON is a helper function to marshal ChannelEdit
+func (c ChannelEdit) MarshalJSON() ([]byte, error) {
+	type channelEdit ChannelEdit
+
+	if c.RTCRegion != nil && *c.RTCRegion == "" {
+		return Marshal(struct {
+			channelEdit
+			RTCRegion json.RawMessage `json:"rtc_region"`
+		}{
+			channelEdit: channelEdit(c),
+			RTCRegion:   json.RawMessage("null"),
+		})
+	}
+
+	return Marshal(channelEdit(c))
+}
+
 // A ChannelFollow holds data returned after following a news channel
 type ChannelFollow struct {
 	ChannelID string `json:"channel_id"`
@@ -528,6 +871,10 @@ type ThreadMember struct {
 	JoinTimestamp time.Time `json:"join_timestamp"`
 	// Any user-thread settings, currently only used for notifications
 	Flags int `json:"flags"`
+	// Additional information about the user.
+	// NOTE: only present if the withMember parameter is set to true
+	// when calling Session.ThreadMembers or Session.ThreadMember.
+	Member *Member `json:"member,omitempty"`
 }
 
 // ThreadsList represents a list of threads alongisde with thread member objects for the current user.
@@ -537,6 +884,28 @@ type ThreadsList struct {
 	HasMore bool            `json:"has_more"`
 }
 
+// UnmarshalJSON is a helper function to unmarshal ThreadsList, which also
+// sets the Member of every thread the current user is a member of.
+func (t *ThreadsList) UnmarshalJSON(data []byte) error {
+	type threadsList ThreadsList
+	var v threadsList
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*t = ThreadsList(v)
+
+	members := make(map[string]*ThreadMember, len(t.Members))
+	for _, m := range t.Members {
+		members[m.ID] = m
+	}
+	for _, thread := range t.Threads {
+		if m, ok := members[thread.ID]; ok && thread.Member == nil {
+			thread.Member = m
+		}
+	}
+	return nil
+}
+
 // AddedThreadMember holds information about the user who was added to the thread
 type AddedThreadMember struct {
 	*ThreadMember
@@ -545,7 +914,8 @@ type AddedThreadMember struct {
 }
 
 // ForumDefaultReaction specifies emoji to use as the default reaction to a forum post.
-// NOTE: Exactly one of EmojiID and EmojiName must be set.
+// NOTE: Exactly one of EmojiID and EmojiName must be set. Editing a channel with
+// an empty ForumDefaultReaction removes its default reaction.
 type ForumDefaultReaction struct {
 	// The id of a guild's custom emoji.
 	EmojiID string `json:"emoji_id,omitempty"`
@@ -553,6 +923,18 @@ type ForumDefaultReaction struct {
 	EmojiName string `json:"emoji_name,omitempty"`
 }
 
+// MarshalJSON is a helper function to marshal ForumDefaultReaction.
+// A reaction without an emoji is marshaled as null, which removes the
+// default reaction when editing a channel.
+func (r ForumDefaultReaction) MarshalJSON() ([]byte, error) {
+	if r.EmojiID == "" && r.EmojiName == "" {
+		return []byte("null"), nil
+	}
+
+	type forumDefaultReaction ForumDefaultReaction
+	return Marshal(forumDefaultReaction(r))
+}
+
 // ForumTag represents a tag that is able to be applied to a thread in a forum channel.
 type ForumTag struct {
 	ID        string `json:"id,omitempty"`
@@ -579,9 +961,11 @@ var (
 	EmojiRegex = regexp.MustCompile(`<(a|):[A-z0-9_~]+:[0-9]{18,20}>`)
 )
 
-// MessageFormat returns a correctly formatted Emoji for use in Message content and embeds
+// MessageFormat returns a correctly formatted Emoji for use in Message content and embeds,
+// e.g. "<:name:id>" or "<a:name:id>" for custom emojis and the emoji itself for unicode emojis.
+// Use APIName instead for the MessageReactions endpoints.
 func (e *Emoji) MessageFormat() string {
-	if e.ID != "" && e.Name != "" {
+	if e.ID != "" {
 		if e.Animated {
 			return "<a:" + e.APIName() + ">"
 		}
@@ -592,15 +976,17 @@ func (e *Emoji) MessageFormat() string {
 	return e.APIName()
 }
 
-// APIName returns an correctly formatted API name for use in the MessageReactions endpoints.
+// APIName returns an correctly formatted API name for use in the MessageReactions endpoints,
+// e.g. "name:id" for custom emojis and the emoji itself for unicode emojis.
+// Custom emojis without a name, such as reactions of removed emojis, use "_" as their name.
 func (e *Emoji) APIName() string {
-	if e.ID != "" && e.Name != "" {
+	if e.ID != "" {
+		if e.Name == "" {
+			return "_:" + e.ID
+		}
 		return e.Name + ":" + e.ID
 	}
-	if e.Name != "" {
-		return e.Name
-	}
-	return e.ID
+	return e.Name
 }
 
 // EmojiParams represents parameters needed to create or update an Emoji.
@@ -672,6 +1058,22 @@ const (
 	VerificationLevelVeryHigh VerificationLevel = 4
 )
 
+func (l VerificationLevel) String() string {
+	switch l {
+	case VerificationLevelNone:
+		return "None"
+	case VerificationLevelLow:
+		return "Low"
+	case VerificationLevelMedium:
+		return "Medium"
+	case VerificationLevelHigh:
+		return "High"
+	case VerificationLevelVeryHigh:
+		return "VeryHigh"
+	}
+	return fmt.Sprintf("VerificationLevel(%d)", l)
+}
+
 // ExplicitContentFilterLevel type definition
 type ExplicitContentFilterLevel int
 
@@ -682,6 +1084,18 @@ const (
 	ExplicitContentFilterAllMembers          ExplicitContentFilterLevel = 2
 )
 
+func (l ExplicitContentFilterLevel) String() string {
+	switch l {
+	case ExplicitContentFilterDisabled:
+		return "Disabled"
+	case ExplicitContentFilterMembersWithoutRoles:
+		return "MembersWithoutRoles"
+	case ExplicitContentFilterAllMembers:
+		return "AllMembers"
+	}
+	return fmt.Sprintf("ExplicitContentFilterLevel(%d)", l)
+}
+
 // GuildNSFWLevel type definition
 type GuildNSFWLevel int
 
@@ -693,6 +1107,20 @@ const (
 	GuildNSFWLevelAgeRestricted GuildNSFWLevel = 3
 )
 
+func (l GuildNSFWLevel) String() string {
+	switch l {
+	case GuildNSFWLevelDefault:
+		return "Default"
+	case GuildNSFWLevelExplicit:
+		return "Explicit"
+	case GuildNSFWLevelSafe:
+		return "Safe"
+	case GuildNSFWLevelAgeRestricted:
+		return "AgeRestricted"
+	}
+	return fmt.Sprintf("GuildNSFWLevel(%d)", l)
+}
+
 // MfaLevel type definition
 type MfaLevel int
 
@@ -702,6 +1130,16 @@ const (
 	MfaLevelElevated MfaLevel = 1
 )
 
+func (l MfaLevel) String() string {
+	switch l {
+	case MfaLevelNone:
+		return "None"
+	case MfaLevelElevated:
+		return "Elevated"
+	}
+	return fmt.Sprintf("MfaLevel(%d)", l)
+}
+
 // PremiumTier type definition
 type PremiumTier int
 
@@ -755,6 +1193,7 @@ type Guild struct {
 	// The number of members in the guild.
 	// This field is only present in GUILD_CREATE events and websocket
 	// update events, and thus is only present in state-cached guilds.
+	// See State.GuildMemberCount for how accurate it is.
 	MemberCount int `json:"member_count"`
 
 	// The verification level required for the guild.
@@ -880,6 +1319,15 @@ type Guild struct {
 	StageInstances []*StageInstance `json:"stage_instances"`
 }
 
+// A GuildVanityURL stores the vanity invite of a guild.
+type GuildVanityURL struct {
+	// The vanity invite code, empty if the guild has none set.
+	Code string `json:"code"`
+
+	// The number of times the vanity invite has been used.
+	Uses int `json:"uses"`
+}
+
 // A GuildPreview holds data related to a specific public Discord Guild, even if the user is not in the guild.
 type GuildPreview struct {
 	// The ID of the guild.
@@ -905,15 +1353,16 @@ type GuildPreview struct {
 	Features []string `json:"features"`
 
 	// Approximate number of members in this guild
-	// NOTE: this field is only filled when using GuildWithCounts
 	ApproximateMemberCount int `json:"approximate_member_count"`
 
 	// Approximate number of non-offline members in this guild
-	// NOTE: this field is only filled when using GuildWithCounts
 	ApproximatePresenceCount int `json:"approximate_presence_count"`
 
 	// the description for the guild
 	Description string `json:"description"`
+
+	// A list of the custom stickers present in the guild.
+	Stickers []*Sticker `json:"stickers"`
 }
 
 // IconURL returns a URL to the guild's icon.
@@ -963,6 +1412,8 @@ type GuildScheduledEvent struct {
 	// see https://discord.com/developers/docs/reference#image-formatting for more
 	// information about image formatting
 	Image string `json:"image"`
+	// The rule by which the scheduled event recurs, or nil if it does not
+	RecurrenceRule *GuildScheduledEventRecurrenceRule `json:"recurrence_rule"`
 }
 
 // GuildScheduledEventParams are the parameters allowed for creating or updating a scheduled event
@@ -988,16 +1439,26 @@ type GuildScheduledEventParams struct {
 	EntityType GuildScheduledEventEntityType `json:"entity_type,omitempty"`
 	// Additional metadata for the guild scheduled event
 	EntityMetadata *GuildScheduledEventEntityMetadata `json:"entity_metadata,omitempty"`
-	// The cover image hash of the scheduled event
-	// see https://discord.com/developers/docs/reference#image-formatting for more
-	// information about image formatting
+	// The cover image of the scheduled event, as a data URI
+	// e.g. "data:image/png;base64,BASE64_STRING_OF_IMAGE"
+	// see https://discord.com/developers/docs/reference#image-data for more
+	// information about image data
 	Image string `json:"image,omitempty"`
+	// The raw cover image of the scheduled event, encoded into Image when
+	// Image is empty. The content type is detected from the data.
+	ImageData []byte `json:"-"`
+	// The rule by which the scheduled event recurs
+	RecurrenceRule *GuildScheduledEventRecurrenceRule `json:"recurrence_rule,omitempty"`
 }
 
 // MarshalJSON is a helper function to marshal GuildScheduledEventParams
 func (p GuildScheduledEventParams) MarshalJSON() ([]byte, error) {
 	type guildScheduledEventParams GuildScheduledEventParams
 
+	if p.Image == "" && len(p.ImageData) > 0 {
+		p.Image = imageDataURI(p.ImageData)
+	}
+
 	if p.EntityType == GuildScheduledEventEntityTypeExternal && p.ChannelID == "" {
 		return Marshal(struct {
 			guildScheduledEventParams
@@ -1059,6 +1520,71 @@ const (
 	GuildScheduledEventEntityTypeExternal GuildScheduledEventEntityType = 3
 )
 
+// GuildScheduledEventRecurrenceRule is the rule by which a scheduled event
+// recurs. Only a subset of the possible combinations of the fields is
+// accepted by Discord, see the link below.
+// https://discord.com/developers/docs/resources/guild-scheduled-event#guild-scheduled-event-recurrence-rule-object
+type GuildScheduledEventRecurrenceRule struct {
+	// The start of the recurrence interval
+	Start time.Time `json:"start"`
+	// The end of the recurrence interval, set by Discord
+	End *time.Time `json:"end,omitempty"`
+	// How often the event occurs
+	Frequency GuildScheduledEventRecurrenceFrequency `json:"frequency"`
+	// The spacing between the events, in units of Frequency
+	Interval int `json:"interval"`
+	// The days of the week on which the event recurs
+	ByWeekday []GuildScheduledEventRecurrenceWeekday `json:"by_weekday,omitempty"`
+	// The days of specific weeks of the month on which the event recurs
+	ByNWeekday []*GuildScheduledEventRecurrenceNWeekday `json:"by_n_weekday,omitempty"`
+	// The months in which the event recurs
+	ByMonth []time.Month `json:"by_month,omitempty"`
+	// The days of the month on which the event recurs
+	ByMonthDay []int `json:"by_month_day,omitempty"`
+	// The days of the year on which the event recurs, set by Discord
+	ByYearDay []int `json:"by_year_day,omitempty"`
+	// The number of times the event can recur before stopping, set by Discord
+	Count int `json:"count,omitempty"`
+}
+
+// GuildScheduledEventRecurrenceFrequency is how often a scheduled event recurs.
+// https://discord.com/developers/docs/resources/guild-scheduled-event#guild-scheduled-event-recurrence-rule-object-guild-scheduled-event-recurrence-rule-frequency
+type GuildScheduledEventRecurrenceFrequency int
+
+// Valid GuildScheduledEventRecurrenceFrequency values
+const (
+	GuildScheduledEventRecurrenceFrequencyYearly  GuildScheduledEventRecurrenceFrequency = 0
+	GuildScheduledEventRecurrenceFrequencyMonthly GuildScheduledEventRecurrenceFrequency = 1
+	GuildScheduledEventRecurrenceFrequencyWeekly  GuildScheduledEventRecurrenceFrequency = 2
+	GuildScheduledEventRecurrenceFrequencyDaily   GuildScheduledEventRecurrenceFrequency = 3
+)
+
+// GuildScheduledEventRecurrenceWeekday is a day of the week in a recurrence
+// rule. Unlike time.Weekday, weeks start on Monday.
+// https://discord.com/developers/docs/resources/guild-scheduled-event#guild-scheduled-event-recurrence-rule-object-guild-scheduled-event-recurrence-rule-weekday
+type GuildScheduledEventRecurrenceWeekday int
+
+// Valid GuildScheduledEventRecurrenceWeekday values
+const (
+	GuildScheduledEventRecurrenceWeekdayMonday    GuildScheduledEventRecurrenceWeekday = 0
+	GuildScheduledEventRecurrenceWeekdayTuesday   GuildScheduledEventRecurrenceWeekday = 1
+	GuildScheduledEventRecurrenceWeekdayWednesday GuildScheduledEventRecurrenceWeekday = 2
+	GuildScheduledEventRecurrenceWeekdayThursday  GuildScheduledEventRecurrenceWeekday = 3
+	GuildScheduledEventRecurrenceWeekdayFriday    GuildScheduledEventRecurrenceWeekday = 4
+	GuildScheduledEventRecurrenceWeekdaySaturday  GuildScheduledEventRecurrenceWeekday = 5
+	GuildScheduledEventRecurrenceWeekdaySunday    GuildScheduledEventRecurrenceWeekday = 6
+)
+
+// GuildScheduledEventRecurrenceNWeekday is a day of a specific week of the
+// month in a recurrence rule.
+// https://discord.com/developers/docs/resources/guild-scheduled-event#guild-scheduled-event-recurrence-rule-object-guild-scheduled-event-recurrence-rule-nweekday-structure
+type GuildScheduledEventRecurrenceNWeekday struct {
+	// The week of the month, from 1 to 5
+	N int `json:"n"`
+	// The day of the week
+	Day GuildScheduledEventRecurrenceWeekday `json:"day"`
+}
+
 // GuildScheduledEventUser is a user subscribed to a scheduled event.
 // https://discord.com/developers/docs/resources/guild-scheduled-event#guild-scheduled-event-user-object
 type GuildScheduledEventUser struct {
@@ -1121,6 +1647,16 @@ const (
 	MessageNotificationsOnlyMentions MessageNotifications = 1
 )
 
+func (n MessageNotifications) String() string {
+	switch n {
+	case MessageNotificationsAllMessages:
+		return "AllMessages"
+	case MessageNotificationsOnlyMentions:
+		return "OnlyMentions"
+	}
+	return fmt.Sprintf("MessageNotifications(%d)", n)
+}
+
 // SystemChannelFlag is the type of flags in the system channel (see SystemChannelFlag* consts)
 // https://discord.com/developers/docs/resources/guild#guild-object-system-channel-flags
 type SystemChannelFlag int
@@ -1149,6 +1685,35 @@ func (g *Guild) BannerURL(size string) string {
 	return bannerURL(g.Banner, EndpointGuildBanner(g.ID, g.Banner), EndpointGuildBannerAnimated(g.ID, g.Banner), size)
 }
 
+// HasFeature reports whether the guild has the given feature enabled.
+func (g *Guild) HasFeature(feature GuildFeature) bool {
+	return hasGuildFeature(g.Features, feature)
+}
+
+// RoleByName returns the first role of the guild whose name is equal to name,
+// ignoring case, or nil if there is none. Role names are not unique, use
+// RolesByName to get all matching roles.
+func (g *Guild) RoleByName(name string) *Role {
+	for _, r := range g.Roles {
+		if strings.EqualFold(r.Name, name) {
+			return r
+		}
+	}
+	return nil
+}
+
+// RolesByName returns all roles of the guild whose name is equal to name,
+// ignoring case.
+func (g *Guild) RolesByName(name string) []*Role {
+	var roles []*Role
+	for _, r := range g.Roles {
+		if strings.EqualFold(r.Name, name) {
+			roles = append(roles, r)
+		}
+	}
+	return roles
+}
+
 // A UserGuild holds a brief version of a Guild
 type UserGuild struct {
 	ID          string         `json:"id"`
@@ -1159,6 +1724,11 @@ type UserGuild struct {
 	Features    []GuildFeature `json:"features"`
 }
 
+// HasFeature reports whether the guild has the given feature enabled.
+func (g *UserGuild) HasFeature(feature GuildFeature) bool {
+	return hasGuildFeature(g.Features, feature)
+}
+
 // GuildFeature indicates the presence of a feature in a guild
 type GuildFeature string
 
@@ -1185,30 +1755,43 @@ const (
 	GuildFeatureVerified                      GuildFeature = "VERIFIED"
 	GuildFeatureVipRegions                    GuildFeature = "VIP_REGIONS"
 	GuildFeatureWelcomeScreenEnabled          GuildFeature = "WELCOME_SCREEN_ENABLED"
+	GuildFeatureGuildOnboarding               GuildFeature = "GUILD_ONBOARDING"
+	GuildFeatureInvitesDisabled               GuildFeature = "INVITES_DISABLED"
+	GuildFeatureRaidAlertsDisabled            GuildFeature = "RAID_ALERTS_DISABLED"
+	GuildFeatureSoundboard                    GuildFeature = "SOUNDBOARD"
 )
 
+func hasGuildFeature(features []GuildFeature, feature GuildFeature) bool {
+	for _, f := range features {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
+
 // A GuildParams stores all the data needed to update discord guild settings
 type GuildParams struct {
-	Name                        string             `json:"name,omitempty"`
-	Region                      string             `json:"region,omitempty"`
-	VerificationLevel           *VerificationLevel `json:"verification_level,omitempty"`
-	DefaultMessageNotifications int                `json:"default_message_notifications,omitempty"` // TODO: Separate type?
-	ExplicitContentFilter       int                `json:"explicit_content_filter,omitempty"`
-	AfkChannelID                string             `json:"afk_channel_id,omitempty"`
-	AfkTimeout                  int                `json:"afk_timeout,omitempty"`
-	Icon                        string             `json:"icon,omitempty"`
-	OwnerID                     string             `json:"owner_id,omitempty"`
-	Splash                      string             `json:"splash,omitempty"`
-	DiscoverySplash             string             `json:"discovery_splash,omitempty"`
-	Banner                      string             `json:"banner,omitempty"`
-	SystemChannelID             string             `json:"system_channel_id,omitempty"`
-	SystemChannelFlags          SystemChannelFlag  `json:"system_channel_flags,omitempty"`
-	RulesChannelID              string             `json:"rules_channel_id,omitempty"`
-	PublicUpdatesChannelID      string             `json:"public_updates_channel_id,omitempty"`
-	PreferredLocale             Locale             `json:"preferred_locale,omitempty"`
-	Features                    []GuildFeature     `json:"features,omitempty"`
-	Description                 string             `json:"description,omitempty"`
-	PremiumProgressBarEnabled   *bool              `json:"premium_progress_bar_enabled,omitempty"`
+	Name                        string                      `json:"name,omitempty"`
+	Region                      string                      `json:"region,omitempty"`
+	VerificationLevel           *VerificationLevel          `json:"verification_level,omitempty"`
+	DefaultMessageNotifications *MessageNotifications       `json:"default_message_notifications,omitempty"`
+	ExplicitContentFilter       *ExplicitContentFilterLevel `json:"explicit_content_filter,omitempty"`
+	AfkChannelID                string                      `json:"afk_channel_id,omitempty"`
+	AfkTimeout                  int                         `json:"afk_timeout,omitempty"`
+	Icon                        string                      `json:"icon,omitempty"`
+	OwnerID                     string                      `json:"owner_id,omitempty"`
+	Splash                      string                      `json:"splash,omitempty"`
+	DiscoverySplash             string                      `json:"discovery_splash,omitempty"`
+	Banner                      string                      `json:"banner,omitempty"`
+	SystemChannelID             string                      `json:"system_channel_id,omitempty"`
+	SystemChannelFlags          SystemChannelFlag           `json:"system_channel_flags,omitempty"`
+	RulesChannelID              string                      `json:"rules_channel_id,omitempty"`
+	PublicUpdatesChannelID      string                      `json:"public_updates_channel_id,omitempty"`
+	PreferredLocale             Locale                      `json:"preferred_locale,omitempty"`
+	Features                    []GuildFeature              `json:"features,omitempty"`
+	Description                 string                      `json:"description,omitempty"`
+	PremiumProgressBarEnabled   *bool                       `json:"premium_progress_bar_enabled,omitempty"`
 }
 
 // A Role stores information about Discord guild member roles.
@@ -1239,6 +1822,12 @@ type Role struct {
 	// This is a combination of bit masks; the presence of a certain permission can
 	// be checked by performing a bitwise AND between this int and the permission.
 	Permissions int64 `json:"permissions,string"`
+
+	// The hash of the role's icon, if it has one.
+	Icon string `json:"icon"`
+
+	// The unicode emoji shown as the role's icon, if it has one.
+	UnicodeEmoji string `json:"unicode_emoji"`
 }
 
 // Mention returns a string which mentions the role
@@ -1246,6 +1835,22 @@ func (r *Role) Mention() string {
 	return fmt.Sprintf("<@&%s>", r.ID)
 }
 
+// IconURL returns the URL of the role's icon.
+//
+//	size:    The size of the desired role icon as a power of two
+//	         Image size can be any power of two between 16 and 4096.
+func (r *Role) IconURL(size string) string {
+	if r.Icon == "" {
+		return ""
+	}
+
+	URL := EndpointRoleIcon(r.ID, r.Icon)
+	if size != "" {
+		return URL + "?size=" + size
+	}
+	return URL
+}
+
 // RoleParams represents the parameters needed to create or update a Role
 type RoleParams struct {
 	// The role's name
@@ -1258,6 +1863,35 @@ type RoleParams struct {
 	Permissions *int64 `json:"permissions,omitempty,string"`
 	// Whether this role is mentionable
 	Mentionable *bool `json:"mentionable,omitempty"`
+	// The role's icon image, sent as a data URI. The guild needs the ROLE_ICONS feature.
+	// NOTE: cannot be set together with UnicodeEmoji.
+	Icon []byte `json:"-"`
+	// The role's unicode emoji icon. The guild needs the ROLE_ICONS feature.
+	// Set to an empty string to remove it.
+	UnicodeEmoji *string `json:"unicode_emoji,omitempty"`
+}
+
+// hasIconAndEmoji reports whether both an icon and a unicode emoji are set,
+// which Discord does not allow.
+func (p *RoleParams) hasIconAndEmoji() bool {
+	return len(p.Icon) > 0 && p.UnicodeEmoji != nil && *p.UnicodeEmoji != ""
+}
+
+// MarshalJSON is a helper function to marshal RoleParams, encoding Icon as a data URI.
+func (p RoleParams) MarshalJSON() ([]byte, error) {
+	type roleParams RoleParams
+
+	if len(p.Icon) == 0 {
+		return Marshal(roleParams(p))
+	}
+
+	return Marshal(struct {
+		roleParams
+		Icon string `json:"icon"`
+	}{
+		roleParams: roleParams(p),
+		Icon:       imageDataURI(p.Icon),
+	})
 }
 
 // Roles are a collection of Role
@@ -1301,6 +1935,33 @@ type Presence struct {
 	ClientStatus ClientStatus `json:"client_status"`
 }
 
+// CustomStatus returns the text and emoji of the user's custom status.
+// The emoji is nil if the custom status has none, and both are empty
+// if the user has no custom status set.
+func (p *Presence) CustomStatus() (text string, emoji *Emoji) {
+	for _, a := range p.Activities {
+		if a.Type != ActivityTypeCustom {
+			continue
+		}
+
+		if a.Emoji.ID != "" || a.Emoji.Name != "" {
+			emoji = &a.Emoji
+		}
+		return a.State, emoji
+	}
+	return "", nil
+}
+
+// IsStreaming reports whether the user is currently streaming.
+func (p *Presence) IsStreaming() bool {
+	for _, a := range p.Activities {
+		if a.Type == ActivityTypeStreaming {
+			return true
+		}
+	}
+	return false
+}
+
 // A TimeStamps struct contains start and end times used in the rich presence "playing .." Game
 type TimeStamps struct {
 	EndTimestamp   int64 `json:"end,omitempty"`
@@ -1337,6 +1998,7 @@ type Member struct {
 	GuildID string `json:"guild_id"`
 
 	// The time at which the member joined the guild.
+	// It is the zero time for partial members which don't include it.
 	JoinedAt time.Time `json:"joined_at"`
 
 	// The nickname of the member, if they have one.
@@ -1351,6 +2013,9 @@ type Member struct {
 	// The hash of the avatar for the guild member, if any.
 	Avatar string `json:"avatar"`
 
+	// The hash of the banner for the guild member, if any.
+	Banner string `json:"banner"`
+
 	// The underlying user on which the member is based.
 	User *User `json:"user"`
 
@@ -1361,6 +2026,7 @@ type Member struct {
 	PremiumSince *time.Time `json:"premium_since"`
 
 	// Is true while the member hasn't accepted the membership screen.
+	// A GuildMemberUpdate with Pending false is sent once they do.
 	Pending bool `json:"pending"`
 
 	// Total permissions of the member in the channel, including overrides, returned when in the interaction object.
@@ -1369,6 +2035,40 @@ type Member struct {
 	// The time at which the member's timeout will expire.
 	// Time in the past or nil if the user is not timed out.
 	CommunicationDisabledUntil *time.Time `json:"communication_disabled_until"`
+
+	// The flags of the member, such as whether they completed onboarding.
+	Flags MemberFlags `json:"flags"`
+}
+
+// MemberFlags represent flags of a guild member.
+// https://discord.com/developers/docs/resources/guild#guild-member-object-guild-member-flags
+type MemberFlags int
+
+// Block containing known MemberFlags values.
+const (
+	// MemberFlagDidRejoin indicates the member has left and rejoined the guild.
+	MemberFlagDidRejoin MemberFlags = 1 << 0
+	// MemberFlagCompletedOnboarding indicates the member has completed onboarding.
+	MemberFlagCompletedOnboarding MemberFlags = 1 << 1
+	// MemberFlagBypassesVerification indicates the member is exempt from guild verification requirements.
+	MemberFlagBypassesVerification MemberFlags = 1 << 2
+	// MemberFlagStartedOnboarding indicates the member has started onboarding.
+	MemberFlagStartedOnboarding MemberFlags = 1 << 3
+	// MemberFlagIsGuest indicates the member is a guest and can only access the voice channel they were invited to.
+	MemberFlagIsGuest MemberFlags = 1 << 4
+	// MemberFlagStartedHomeActions indicates the member has started the Server Guide new member actions.
+	MemberFlagStartedHomeActions MemberFlags = 1 << 5
+	// MemberFlagCompletedHomeActions indicates the member has completed the Server Guide new member actions.
+	MemberFlagCompletedHomeActions MemberFlags = 1 << 6
+	// MemberFlagAutomodQuarantinedUsername indicates the member's username, display name, or nickname is blocked by AutoMod.
+	MemberFlagAutomodQuarantinedUsername MemberFlags = 1 << 7
+	// MemberFlagDMSettingsUpsellAcknowledged indicates the member has dismissed the DM settings upsell.
+	MemberFlagDMSettingsUpsellAcknowledged MemberFlags = 1 << 9
+)
+
+// HasFlag returns whether the member has all of the given flags set.
+func (m *Member) HasFlag(flag MemberFlags) bool {
+	return m.Flags&flag == flag
 }
 
 // Mention creates a member mention
@@ -1391,6 +2091,20 @@ func (m *Member) AvatarURL(size string) string {
 
 }
 
+// BannerURL returns the URL of the member's banner image, falling back
+// to the banner of the user. The user's banner is only set in users
+// returned by Session.User.
+//
+//	size:    The size of the desired banner image as a power of two
+//	         Image size can be any power of two between 16 and 4096.
+func (m *Member) BannerURL(size string) string {
+	if m.Banner == "" {
+		return m.User.BannerURL(size)
+	}
+	return bannerURL(m.Banner, EndpointGuildMemberBanner(m.GuildID, m.User.ID, m.Banner),
+		EndpointGuildMemberBannerAnimated(m.GuildID, m.User.ID, m.Banner), size)
+}
+
 // ClientStatus stores the online, offline, idle, or dnd status of each device of a Guild member.
 type ClientStatus struct {
 	Desktop Status `json:"desktop"`
@@ -1416,6 +2130,9 @@ type TooManyRequests struct {
 	Bucket     string        `json:"bucket"`
 	Message    string        `json:"message"`
 	RetryAfter time.Duration `json:"retry_after"`
+	Global     bool          `json:"global"`
+	// The error code, e.g. ErrCodeThisActionCannotBePerformedDueToSlowmodeRateLimit.
+	Code int `json:"code"`
 }
 
 // UnmarshalJSON helps support translation of a milliseconds-based float
@@ -1425,6 +2142,8 @@ func (t *TooManyRequests) UnmarshalJSON(b []byte) error {
 		Bucket     string  `json:"bucket"`
 		Message    string  `json:"message"`
 		RetryAfter float64 `json:"retry_after"`
+		Global     bool    `json:"global"`
+		Code       int     `json:"code"`
 	}{}
 	err := Unmarshal(b, &u)
 	if err != nil {
@@ -1433,6 +2152,8 @@ func (t *TooManyRequests) UnmarshalJSON(b []byte) error {
 
 	t.Bucket = u.Bucket
 	t.Message = u.Message
+	t.Global = u.Global
+	t.Code = u.Code
 	whole, frac := math.Modf(u.RetryAfter)
 	t.RetryAfter = time.Duration(whole)*time.Second + time.Duration(frac*1000)*time.Millisecond
 	return nil
@@ -1905,11 +2626,15 @@ type APIErrorMessage struct {
 
 // MessageReaction stores the data for a message reaction.
 type MessageReaction struct {
-	UserID    string `json:"user_id"`
-	MessageID string `json:"message_id"`
-	Emoji     Emoji  `json:"emoji"`
-	ChannelID string `json:"channel_id"`
-	GuildID   string `json:"guild_id,omitempty"`
+	UserID    string       `json:"user_id"`
+	MessageID string       `json:"message_id"`
+	Emoji     Emoji        `json:"emoji"`
+	ChannelID string       `json:"channel_id"`
+	GuildID   string       `json:"guild_id,omitempty"`
+	Burst     bool         `json:"burst"`
+	Type      ReactionType `json:"type"`
+	// The colors of a super reaction, in hex format. Only set in MessageReactionAdd events.
+	BurstColors []string `json:"burst_colors,omitempty"`
 }
 
 // GatewayBotResponse stores the data for the gateway/bot response
@@ -1920,6 +2645,8 @@ type GatewayBotResponse struct {
 }
 
 // SessionInformation provides the information for max concurrency sharding
+// and the session start limit, the number of times a bot may identify per day.
+// ResetAfter is the number of milliseconds until Remaining is reset to Total.
 type SessionInformation struct {
 	Total          int `json:"total,omitempty"`
 	Remaining      int `json:"remaining,omitempty"`
@@ -2029,7 +2756,7 @@ type Identify struct {
 	Token          string              `json:"token"`
 	Properties     IdentifyProperties  `json:"properties"`
 	Compress       bool                `json:"compress"`
-	LargeThreshold int                 `json:"large_threshold"`
+	LargeThreshold int                 `json:"large_threshold,omitempty"` // 50-250, 0 for Discord's default of 50
 	Shard          *[2]int             `json:"shard,omitempty"`
 	Presence       GatewayStatusUpdate `json:"presence,omitempty"`
 	Intents        Intent              `json:"intents"`
@@ -2341,6 +3068,8 @@ const (
 
 	ErrCodeCannotUpdateAFinishedEvent             = 180000
 	ErrCodeFailedToCreateStageNeededForStageEvent = 180002
+
+	ErrCodeFailedToBanUsers = 500000
 )
 
 // Intent is the type of a Gateway Intent
@@ -0,0 +1,158 @@
+package discordgo
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestEmojiFormats(t *testing.T) {
+	tests := []struct {
+		emoji         Emoji
+		messageFormat string
+		apiName       string
+	}{
+		{Emoji{Name: "👍"}, "👍", "👍"},
+		{Emoji{ID: "1", Name: "custom"}, "<:custom:1>", "custom:1"},
+		{Emoji{ID: "1", Name: "custom", Animated: true}, "<a:custom:1>", "custom:1"},
+		{Emoji{ID: "1"}, "<:_:1>", "_:1"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.emoji.MessageFormat(); got != tt.messageFormat {
+			t.Errorf("MessageFormat() of %+v = %q, want %q", tt.emoji, got, tt.messageFormat)
+		}
+		if got := tt.emoji.APIName(); got != tt.apiName {
+			t.Errorf("APIName() of %+v = %q, want %q", tt.emoji, got, tt.apiName)
+		}
+	}
+}
+
+func TestChannelTypePredicates(t *testing.T) {
+	tests := []struct {
+		typ                                  ChannelType
+		thread, text, voice, forum, category bool
+	}{
+		{ChannelTypeGuildText, false, true, false, false, false},
+		{ChannelTypeDM, false, true, false, false, false},
+		{ChannelTypeGroupDM, false, true, false, false, false},
+		{ChannelTypeGuildNews, false, true, false, false, false},
+		{ChannelTypeGuildVoice, false, false, true, false, false},
+		{ChannelTypeGuildStageVoice, false, false, true, false, false},
+		{ChannelTypeGuildCategory, false, false, false, false, true},
+		{ChannelTypeGuildNewsThread, true, false, false, false, false},
+		{ChannelTypeGuildPublicThread, true, false, false, false, false},
+		{ChannelTypeGuildPrivateThread, true, false, false, false, false},
+		{ChannelTypeGuildForum, false, false, false, true, false},
+		{ChannelTypeGuildMedia, false, false, false, true, false},
+	}
+
+	for _, tt := range tests {
+		c := &Channel{Type: tt.typ}
+		got := [5]bool{c.IsThread(), c.IsText(), c.IsVoice(), c.IsForum(), c.IsCategory()}
+		want := [5]bool{tt.thread, tt.text, tt.voice, tt.forum, tt.category}
+		if got != want {
+			t.Errorf("predicates of channel type %d = %v, want %v", tt.typ, got, want)
+		}
+	}
+}
+
+func TestGuildHasFeature(t *testing.T) {
+	var g Guild
+	if err := json.Unmarshal([]byte(`{"features":["COMMUNITY","SOME_FUTURE_FEATURE"]}`), &g); err != nil {
+		t.Fatalf("unmarshalling guild: %v", err)
+	}
+
+	if !g.HasFeature(GuildFeatureCommunity) {
+		t.Error("HasFeature(GuildFeatureCommunity) = false, want true")
+	}
+	if g.HasFeature(GuildFeatureVanityURL) {
+		t.Error("HasFeature(GuildFeatureVanityURL) = true, want false")
+	}
+	if !g.HasFeature("SOME_FUTURE_FEATURE") {
+		t.Error("HasFeature of an unknown feature = false, want true")
+	}
+}
+
+func TestGuildRoleByName(t *testing.T) {
+	g := &Guild{ID: "guild", Roles: []*Role{{ID: "1", Name: "Mod"}, {ID: "2", Name: "Admin"}, {ID: "3", Name: "mod"}}}
+
+	if r := g.RoleByName("MOD"); r == nil || r.ID != "1" {
+		t.Errorf("RoleByName() = %v, want the first mod role", r)
+	}
+	if r := g.RoleByName("member"); r != nil {
+		t.Errorf("RoleByName() of a missing role = %v, want nil", r)
+	}
+	if roles := g.RolesByName("mod"); len(roles) != 2 {
+		t.Errorf("RolesByName() = %v, want both mod roles", roles)
+	}
+
+	state := NewState()
+	if err := state.GuildAdd(g); err != nil {
+		t.Fatal(err)
+	}
+	if r, err := state.GuildRoleByName("guild", "admin"); err != nil || r.ID != "2" {
+		t.Errorf("GuildRoleByName() = %v, %v, want the admin role", r, err)
+	}
+	if _, err := state.GuildRoleByName("guild", "member"); err != ErrStateNotFound {
+		t.Errorf("GuildRoleByName() of a missing role error = %v, want ErrStateNotFound", err)
+	}
+}
+
+func TestChannelHasUnread(t *testing.T) {
+	c := &Channel{LastMessageID: "1000000000000000000"}
+
+	if !c.HasUnread("999999999999999999") {
+		t.Error("HasUnread of an older ID with fewer digits = false, want true")
+	}
+	if c.HasUnread("1000000000000000000") {
+		t.Error("HasUnread of the last message ID = true, want false")
+	}
+	if (&Channel{}).HasUnread("") {
+		t.Error("HasUnread of a channel without messages = true, want false")
+	}
+}
+
+func TestMemberHasFlag(t *testing.T) {
+	var m Member
+	if err := json.Unmarshal([]byte(`{"user": {"id": "user"}, "flags": 10}`), &m); err != nil {
+		t.Fatal(err)
+	}
+
+	if !m.HasFlag(MemberFlagCompletedOnboarding) || !m.HasFlag(MemberFlagStartedOnboarding) {
+		t.Errorf("onboarding flags not set in %d", m.Flags)
+	}
+	if !m.HasFlag(MemberFlagCompletedOnboarding | MemberFlagStartedOnboarding) {
+		t.Error("HasFlag() with combined flags = false")
+	}
+	if m.HasFlag(MemberFlagDidRejoin) || m.HasFlag(MemberFlagCompletedOnboarding|MemberFlagDidRejoin) {
+		t.Error("HasFlag() reported a flag which is not set")
+	}
+}
+
+func TestChannelEditRTCRegion(t *testing.T) {
+	region := "rotterdam"
+	automatic := ""
+	quality := VideoQualityModeFull
+
+	tests := []struct {
+		name string
+		edit ChannelEdit
+		want string
+	}{
+		{"unchanged", ChannelEdit{Name: "voice"}, `{"name":"voice","position":0}`},
+		{"region", ChannelEdit{RTCRegion: &region, VideoQualityMode: &quality}, `{"position":0,"rtc_region":"rotterdam","video_quality_mode":2}`},
+		{"automatic", ChannelEdit{RTCRegion: &automatic}, `{"position":0,"rtc_region":null}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := json.Marshal(&tt.edit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != tt.want {
+				t.Errorf("got %s, want %s", b, tt.want)
+			}
+		})
+	}
+}
@@ -1,5 +1,7 @@
 package discordgo
 
+import "strconv"
+
 // UserFlags is the flags of "user" (see UserFlags* consts)
 // https://discord.com/developers/docs/resources/user#user-object-user-flags
 type UserFlags int
@@ -34,6 +36,9 @@ type User struct {
 	// The user's username.
 	Username string `json:"username"`
 
+	// The user's display name, if it is set.
+	GlobalName string `json:"global_name"`
+
 	// The hash of the user's avatar. Use Session.UserAvatar
 	// to retrieve the avatar itself.
 	Avatar string `json:"avatar"`
@@ -95,10 +100,23 @@ func (u *User) Mention() string {
 //             if size is an empty string, no size parameter will
 //             be added to the URL.
 func (u *User) AvatarURL(size string) string {
-	return avatarURL(u.Avatar, EndpointDefaultUserAvatar(u.Discriminator),
+	return avatarURL(u.Avatar, EndpointDefaultUserAvatarIndex(u.DefaultAvatarIndex()),
 		EndpointUserAvatar(u.ID, u.Avatar), EndpointUserAvatarAnimated(u.ID, u.Avatar), size)
 }
 
+// DefaultAvatarIndex returns the index of the default avatar of the user,
+// which is based on the ID for users on the new username system and on
+// the discriminator for others.
+func (u *User) DefaultAvatarIndex() int {
+	if u.Discriminator == "" || u.Discriminator == "0" {
+		id, _ := strconv.ParseUint(u.ID, 10, 64)
+		return int((id >> 22) % 6)
+	}
+
+	discriminator, _ := strconv.Atoi(u.Discriminator)
+	return discriminator % 5
+}
+
 // BannerURL returns the URL of the users's banner image.
 //    size:    The size of the desired banner image as a power of two
 //             Image size can be any power of two between 16 and 4096.
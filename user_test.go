@@ -14,3 +14,56 @@ func TestUser(t *testing.T) {
 		t.Errorf("user.String() == %v", user.String())
 	}
 }
+
+func TestPresenceCustomStatus(t *testing.T) {
+	t.Parallel()
+
+	p := &Presence{
+		Activities: []*Activity{
+			{Type: ActivityTypeGame, Name: "Go"},
+			{Type: ActivityTypeCustom, Name: "Custom Status", State: "coding", Emoji: Emoji{Name: "🐹"}},
+		},
+	}
+
+	text, emoji := p.CustomStatus()
+	if text != "coding" || emoji == nil || emoji.Name != "🐹" {
+		t.Errorf("CustomStatus() == %q, %v", text, emoji)
+	}
+	if p.IsStreaming() {
+		t.Error("IsStreaming() == true, want false")
+	}
+}
+
+func TestUserAvatarURL(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		user *User
+		want string
+	}{
+		{&User{ID: "1", Avatar: "hash"}, EndpointCDNAvatars + "1/hash.png?size=64"},
+		{&User{ID: "1", Avatar: "a_hash"}, EndpointCDNAvatars + "1/a_hash.gif?size=64"},
+		{&User{ID: "1", Discriminator: "0007"}, EndpointCDN + "embed/avatars/2.png?size=64"},
+		{&User{ID: "80351110224678912", Discriminator: "0"}, EndpointCDN + "embed/avatars/5.png?size=64"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.user.AvatarURL("64"); got != tt.want {
+			t.Errorf("AvatarURL() of %+v = %q, want %q", tt.user, got, tt.want)
+		}
+	}
+}
+
+func TestMemberBannerURL(t *testing.T) {
+	t.Parallel()
+
+	m := &Member{GuildID: "guild", User: &User{ID: "user", Banner: "user_banner"}}
+	if got, want := m.BannerURL(""), EndpointCDNBanners+"user/user_banner.png"; got != want {
+		t.Errorf("BannerURL() = %q, want the user banner %q", got, want)
+	}
+
+	m.Banner = "a_member_banner"
+	if got, want := m.BannerURL("256"), EndpointCDNGuilds+"guild/users/user/banners/a_member_banner.gif?size=256"; got != want {
+		t.Errorf("BannerURL() = %q, want the animated member banner %q", got, want)
+	}
+}
@@ -2,9 +2,11 @@ package discordgo
 
 import (
 	"bytes"
+	"encoding/base64"
 	"fmt"
 	"io"
 	"mime/multipart"
+	"net/http"
 	"net/textproto"
 	"strconv"
 	"strings"
@@ -22,6 +24,91 @@ func SnowflakeTimestamp(ID string) (t time.Time, err error) {
 	return
 }
 
+// SnowflakeCompare compares two snowflake IDs numerically, returning -1 if a
+// is older than b, 0 if they are equal and +1 if a is newer than b.
+// Comparing IDs as strings is wrong when they differ in length.
+func SnowflakeCompare(a, b string) int {
+	a = strings.TrimLeft(a, "0")
+	b = strings.TrimLeft(b, "0")
+	switch {
+	case len(a) < len(b):
+		return -1
+	case len(a) > len(b):
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// TimestampStyle is the style in which a timestamp created with
+// FormatTimestamp is displayed.
+type TimestampStyle string
+
+// Timestamp styles, the examples are for the en-US locale.
+const (
+	TimestampStyleDefault       TimestampStyle = ""  // Same as TimestampStyleShortDateTime
+	TimestampStyleShortTime     TimestampStyle = "t" // 4:20 PM
+	TimestampStyleLongTime      TimestampStyle = "T" // 4:20:30 PM
+	TimestampStyleShortDate     TimestampStyle = "d" // 04/20/2021
+	TimestampStyleLongDate      TimestampStyle = "D" // April 20, 2021
+	TimestampStyleShortDateTime TimestampStyle = "f" // April 20, 2021 4:20 PM
+	TimestampStyleLongDateTime  TimestampStyle = "F" // Tuesday, April 20, 2021 4:20 PM
+	TimestampStyleRelative      TimestampStyle = "R" // 2 months ago
+)
+
+// FormatTimestamp returns the message markdown for a timestamp, which
+// Discord displays in the time zone and locale of each user.
+func FormatTimestamp(t time.Time, style TimestampStyle) string {
+	if style == TimestampStyleDefault {
+		return fmt.Sprintf("<t:%d>", t.Unix())
+	}
+	return fmt.Sprintf("<t:%d:%s>", t.Unix(), style)
+}
+
+// MarkdownSpecialChars are the characters escaped by EscapeMarkdown.
+// The characters '>' and '#' are only escaped at the start of a line,
+// where they start a quote or a header.
+const MarkdownSpecialChars = "\\*_~`|>#"
+
+// EscapeMarkdown escapes the characters in MarkdownSpecialChars with
+// backslashes, so that text such as user input is displayed verbatim
+// instead of being formatted.
+func EscapeMarkdown(s string) string {
+	return EscapeMarkdownChars(s, MarkdownSpecialChars)
+}
+
+// EscapeMarkdownChars is like EscapeMarkdown but only escapes the given characters.
+func EscapeMarkdownChars(s, chars string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+
+	lineStart := true
+	for _, r := range s {
+		switch {
+		case r == '>' || r == '#':
+			if lineStart && strings.ContainsRune(chars, r) {
+				b.WriteByte('\\')
+			}
+		case strings.ContainsRune(chars, r):
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+
+		if r == '\n' {
+			lineStart = true
+		} else if r != ' ' && r != '\t' {
+			lineStart = false
+		}
+	}
+	return b.String()
+}
+
+// EscapeMentions inserts a zero-width space after every '@', so that user,
+// role, @everyone and @here mentions in s are displayed without pinging anyone.
+// AllowedMentions should be preferred when the message is sent by the session.
+func EscapeMentions(s string) string {
+	return strings.Replace(s, "@", "@\u200b", -1)
+}
+
 // MultipartBodyWithJSON returns the contentType and body for a discord request
 // data  : The object to encode for payload_json in the multipart request
 // files : Files to include in the request
@@ -63,9 +150,13 @@ func MultipartBodyWithJSON(data interface{}, files []*File) (requestContentType
 			return
 		}
 
+		rewind := rewinder(file.Reader)
 		if _, err = io.Copy(p, file.Reader); err != nil {
 			return
 		}
+		if err = rewind(); err != nil {
+			return
+		}
 	}
 
 	err = bodywriter.Close()
@@ -76,6 +167,41 @@ func MultipartBodyWithJSON(data interface{}, files []*File) (requestContentType
 	return bodywriter.FormDataContentType(), body.Bytes(), nil
 }
 
+// rewinder returns a function which seeks r back to its current offset, so
+// that files which were read into a request body can be sent again, for
+// example after a RateLimitError. It does nothing if r is not an io.Seeker.
+func rewinder(r io.Reader) func() error {
+	seeker, ok := r.(io.Seeker)
+	if !ok {
+		return func() error { return nil }
+	}
+	offset, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return func() error { return nil }
+	}
+	return func() error {
+		_, err := seeker.Seek(offset, io.SeekStart)
+		return err
+	}
+}
+
+// emojiKey normalises an emoji in any of the forms accepted by the API, or
+// "<:name:id>" and "<a:name:id>", to the ID for custom emojis and the name
+// for unicode emojis, so different forms of the same emoji can be compared.
+func emojiKey(emoji string) string {
+	emoji = strings.TrimSuffix(strings.TrimPrefix(emoji, "<"), ">")
+	if i := strings.LastIndex(emoji, ":"); i >= 0 {
+		return emoji[i+1:]
+	}
+	return emoji
+}
+
+// imageDataURI returns the data URI of an image, as used to upload images
+// in JSON payloads. The content type is detected from the data.
+func imageDataURI(data []byte) string {
+	return "data:" + http.DetectContentType(data) + ";base64," + base64.StdEncoding.EncodeToString(data)
+}
+
 func avatarURL(avatarHash, defaultAvatarURL, staticAvatarURL, animatedAvatarURL, size string) string {
 	var URL string
 	if avatarHash == "" {
@@ -19,3 +19,66 @@ func TestSnowflakeTimestamp(t *testing.T) {
 		t.Errorf("parsed time incorrect: got %v, want %v", parsedTimestamp, correctTimestamp)
 	}
 }
+
+func TestImageDataURI(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n")
+	if got, want := imageDataURI(png), "data:image/png;base64,iVBORw0KGgo="; got != want {
+		t.Errorf("imageDataURI() = %q, want %q", got, want)
+	}
+}
+
+func TestFormatTimestamp(t *testing.T) {
+	ts := time.Date(2021, time.April, 20, 16, 20, 30, 0, time.UTC)
+
+	if got, want := FormatTimestamp(ts, TimestampStyleRelative), "<t:1618935630:R>"; got != want {
+		t.Errorf("FormatTimestamp(TimestampStyleRelative) = %q, want %q", got, want)
+	}
+	if got, want := FormatTimestamp(ts, TimestampStyleDefault), "<t:1618935630>"; got != want {
+		t.Errorf("FormatTimestamp(TimestampStyleDefault) = %q, want %q", got, want)
+	}
+}
+
+func TestEscapeMarkdown(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"**bold** _it_ ~~del~~ `code` ||spoiler||", "\\*\\*bold\\*\\* \\_it\\_ \\~\\~del\\~\\~ \\`code\\` \\|\\|spoiler\\|\\|"},
+		{"> quote\n  # header", "\\> quote\n  \\# header"},
+		{"1 > 0 #1", "1 > 0 #1"},
+		{`back\slash`, `back\\slash`},
+	}
+
+	for _, tt := range tests {
+		if got := EscapeMarkdown(tt.in); got != tt.want {
+			t.Errorf("EscapeMarkdown(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+
+	if got, want := EscapeMarkdownChars("*_`", "`"), "*_\\`"; got != want {
+		t.Errorf("EscapeMarkdownChars = %q, want %q", got, want)
+	}
+}
+
+func TestEscapeMentions(t *testing.T) {
+	if got, want := EscapeMentions("@everyone <@1>"), "@\u200beveryone <@\u200b1>"; got != want {
+		t.Errorf("EscapeMentions = %q, want %q", got, want)
+	}
+}
+
+func TestSnowflakeCompare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"999999999999999999", "1000000000000000000", -1},
+		{"175928847299117063", "175928847299117063", 0},
+		{"175928847299117064", "175928847299117063", 1},
+		{"1", "", 1},
+	}
+
+	for _, tt := range tests {
+		if got := SnowflakeCompare(tt.a, tt.b); got != tt.want {
+			t.Errorf("SnowflakeCompare(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -12,7 +12,9 @@ package discordgo
 import (
 	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net"
 	"strconv"
 	"strings"
@@ -45,6 +47,11 @@ type VoiceConnection struct {
 	OpusSend chan []byte  // Chan for sending opus audio
 	OpusRecv chan *Packet // Chan for receiving opus audio
 
+	// The sample rate and number of channels ReceivePCM decodes audio to.
+	// Default to VoiceSampleRate and VoiceChannels when zero.
+	DecodeSampleRate int
+	DecodeChannels   int
+
 	wsConn  *websocket.Conn
 	wsMutex sync.Mutex
 	udpConn *net.UDPConn
@@ -57,8 +64,11 @@ type VoiceConnection struct {
 	// Used to send a close signal to goroutines
 	close chan struct{}
 
-	// Used to allow blocking until connected
-	connected chan bool
+	// Closed when the session description is received, to allow blocking until connected
+	connected chan struct{}
+
+	// Used by Stop to wait until opusSender has transmitted the queued frames
+	opusFlush chan chan struct{}
 
 	// Used to pass the sessionid from onVoiceStateUpdate
 	// sessionRecv chan string UNUSED ATM
@@ -113,6 +123,344 @@ func (v *VoiceConnection) Speaking(b bool) (err error) {
 	return
 }
 
+// opusSilenceFrame is a frame of opus encoded silence.
+var opusSilenceFrame = []byte{0xF8, 0xFF, 0xFE}
+
+// opusSilenceFrameCount is the number of silence frames to send when
+// pausing, to avoid unintended interpolation with the following audio.
+const opusSilenceFrameCount = 5
+
+// ErrVoiceJoinTimeout is returned by ChannelVoiceJoin when the voice
+// connection is not ready within Session.VoiceJoinTimeout.
+var ErrVoiceJoinTimeout = errors.New("timeout waiting for voice connection")
+
+// ErrVoiceNotReady is returned when sending audio on a VoiceConnection
+// which is not connected.
+var ErrVoiceNotReady = errors.New("voice connection is not ready to send audio")
+
+// SendSilenceFrames queues five frames of opus silence on OpusSend.
+// Discord recommends sending these whenever transmission stops, so that
+// clients don't interpolate the last frame into the following audio.
+func (v *VoiceConnection) SendSilenceFrames() error {
+	v.RLock()
+	send, closed := v.OpusSend, v.close
+	v.RUnlock()
+
+	if send == nil || closed == nil {
+		return ErrVoiceNotReady
+	}
+
+	for i := 0; i < opusSilenceFrameCount; i++ {
+		frame := make([]byte, len(opusSilenceFrame))
+		copy(frame, opusSilenceFrame)
+
+		select {
+		case send <- frame:
+		case <-closed:
+			return ErrVoiceNotReady
+		}
+	}
+	return nil
+}
+
+// Pause sends silence frames, so that sending audio can be stopped
+// without artifacts. The speaking state is kept, use Stop to clear it.
+func (v *VoiceConnection) Pause() error {
+	return v.SendSilenceFrames()
+}
+
+// Stop sends silence frames, waits for them to be transmitted and then
+// sends a speaking notification that audio has stopped.
+// No more audio should be sent on OpusSend while Stop is running.
+func (v *VoiceConnection) Stop() error {
+	err := v.SendSilenceFrames()
+	if err != nil {
+		return err
+	}
+
+	v.RLock()
+	flush, closed := v.opusFlush, v.close
+	v.RUnlock()
+
+	if flush == nil || closed == nil {
+		return ErrVoiceNotReady
+	}
+
+	// Wait for the queued frames, and the one being sent, to be transmitted.
+	done := make(chan struct{}, 1)
+	select {
+	case flush <- done:
+	case <-closed:
+		return ErrVoiceNotReady
+	}
+	select {
+	case <-done:
+	case <-closed:
+		return ErrVoiceNotReady
+	}
+
+	return v.Speaking(false)
+}
+
+// An OpusEncoder encodes PCM audio into opus frames for SendPCM.
+// It is satisfied by, for example, *gopus.Encoder from layeh.com/gopus.
+// If the encoder also implements io.Closer, it is closed once sending ends.
+type OpusEncoder interface {
+	// Encode encodes a frame of interleaved samples, frameSize samples per channel.
+	Encode(pcm []int16, frameSize, maxDataBytes int) ([]byte, error)
+	// SetBitrate sets the target bitrate in bits per second.
+	SetBitrate(bitrate int)
+}
+
+// PCMConfig configures how SendPCM encodes audio.
+type PCMConfig struct {
+	// NewEncoder creates the encoder used for each call to SendPCM, e.g.
+	//	func(sampleRate, channels int) (discordgo.OpusEncoder, error) {
+	//		return gopus.NewEncoder(sampleRate, channels, gopus.Audio)
+	//	}
+	NewEncoder func(sampleRate, channels int) (OpusEncoder, error)
+
+	// The number of interleaved channels, 1 or 2. Defaults to 2.
+	Channels int
+
+	// The target bitrate in bits per second. Defaults to 64000.
+	Bitrate int
+
+	// The number of samples per channel in each frame. Frames are sent
+	// one every 20ms on OpusSend, so this defaults to, and currently must
+	// be, 960 samples (20ms at 48kHz).
+	FrameSize int
+}
+
+// Audio parameters of voice connections. Discord sends and expects opus
+// audio at 48kHz with 2 channels, in frames of 20ms.
+const (
+	VoiceSampleRate = 48000
+	VoiceChannels   = 2
+	VoiceFrameSize  = 960 // samples per channel in 20ms
+)
+
+// Defaults used by SendPCM.
+const (
+	pcmDefaultBitrate   = 64000
+	pcmMaxOpusFrameSize = 4000
+)
+
+// ErrNoOpusEncoder is returned by SendPCM when PCMConfig.NewEncoder is not set.
+var ErrNoOpusEncoder = errors.New("no opus encoder configured")
+
+// SendPCM encodes 48kHz, 16-bit, interleaved PCM audio received on pcm and
+// queues it on OpusSend until pcm is closed. Samples may be sent in chunks of
+// any length, the last partial frame is padded with silence.
+// It returns ErrVoiceNotReady if the connection closes while sending.
+// For pre-encoded audio, send opus frames on OpusSend directly.
+func (v *VoiceConnection) SendPCM(pcm <-chan []int16, config PCMConfig) (err error) {
+	if config.NewEncoder == nil {
+		return ErrNoOpusEncoder
+	}
+	if config.Channels == 0 {
+		config.Channels = VoiceChannels
+	}
+	if config.Channels != 1 && config.Channels != 2 {
+		return fmt.Errorf("invalid number of channels %d, must be 1 or 2", config.Channels)
+	}
+	if config.Bitrate == 0 {
+		config.Bitrate = pcmDefaultBitrate
+	}
+	if config.FrameSize == 0 {
+		config.FrameSize = VoiceFrameSize
+	}
+	if config.FrameSize != VoiceFrameSize {
+		return fmt.Errorf("invalid frame size %d, must be %d", config.FrameSize, VoiceFrameSize)
+	}
+
+	v.RLock()
+	send, closed := v.OpusSend, v.close
+	v.RUnlock()
+
+	if send == nil || closed == nil {
+		return ErrVoiceNotReady
+	}
+
+	enc, err := config.NewEncoder(VoiceSampleRate, config.Channels)
+	if err != nil {
+		return fmt.Errorf("error creating opus encoder: %w", err)
+	}
+	if c, ok := enc.(io.Closer); ok {
+		defer func() {
+			if cerr := c.Close(); cerr != nil && err == nil {
+				err = fmt.Errorf("error closing opus encoder: %w", cerr)
+			}
+		}()
+	}
+	enc.SetBitrate(config.Bitrate)
+
+	sendFrame := func(frame []int16) error {
+		opus, err := enc.Encode(frame, config.FrameSize, pcmMaxOpusFrameSize)
+		if err != nil {
+			return fmt.Errorf("error encoding opus frame: %w", err)
+		}
+
+		select {
+		case send <- opus:
+			return nil
+		case <-closed:
+			return ErrVoiceNotReady
+		}
+	}
+
+	frameLen := config.FrameSize * config.Channels
+	frame := make([]int16, 0, frameLen)
+	for {
+		var samples []int16
+		var ok bool
+		select {
+		case samples, ok = <-pcm:
+		case <-closed:
+			return ErrVoiceNotReady
+		}
+		if !ok {
+			break
+		}
+
+		for len(samples) > 0 {
+			n := copy(frame[len(frame):frameLen], samples)
+			frame = frame[:len(frame)+n]
+			samples = samples[n:]
+
+			if len(frame) == frameLen {
+				if err = sendFrame(frame); err != nil {
+					return
+				}
+				frame = frame[:0]
+			}
+		}
+	}
+
+	if len(frame) > 0 {
+		for len(frame) < frameLen {
+			frame = append(frame, 0)
+		}
+		err = sendFrame(frame)
+	}
+	return
+}
+
+// An OpusDecoder decodes opus frames into PCM audio for ReceivePCM.
+// It is satisfied by, for example, *gopus.Decoder from layeh.com/gopus.
+// If the decoder also implements io.Closer, it is closed once receiving ends.
+type OpusDecoder interface {
+	// Decode decodes a frame of at most frameSize samples per channel into interleaved samples.
+	Decode(data []byte, frameSize int, fec bool) ([]int16, error)
+}
+
+// PCMReceiveConfig configures how ReceivePCM decodes audio.
+type PCMReceiveConfig struct {
+	// NewDecoder creates a decoder for each SSRC audio is received from, e.g.
+	//	func(sampleRate, channels int) (discordgo.OpusDecoder, error) {
+	//		return gopus.NewDecoder(sampleRate, channels)
+	//	}
+	NewDecoder func(sampleRate, channels int) (OpusDecoder, error)
+
+	// The number of interleaved channels to decode to, 1 or 2.
+	// Defaults to the connection's DecodeChannels.
+	Channels int
+
+	// The maximum number of samples per channel in a decoded frame.
+	// Defaults to 20ms at the decode sample rate, the frames sent by Discord clients.
+	FrameSize int
+}
+
+// ErrNoOpusDecoder is returned by ReceivePCM when PCMReceiveConfig.NewDecoder is not set.
+var ErrNoOpusDecoder = errors.New("no opus decoder configured")
+
+// ReceivePCM decodes the packets received on OpusRecv, sending them on pcm
+// with PCM set to 16-bit, interleaved samples at DecodeSampleRate, until the
+// connection is closed or reconnects. Each SSRC, i.e. each user, is decoded separately.
+// Packets which fail to decode are logged and dropped.
+// OpusRecv must not be read from elsewhere while ReceivePCM is running.
+func (v *VoiceConnection) ReceivePCM(pcm chan<- *Packet, config PCMReceiveConfig) (err error) {
+	if config.NewDecoder == nil {
+		return ErrNoOpusDecoder
+	}
+
+	v.RLock()
+	recv, closed := v.OpusRecv, v.close
+	sampleRate, channels := v.DecodeSampleRate, v.DecodeChannels
+	v.RUnlock()
+
+	if sampleRate == 0 {
+		sampleRate = VoiceSampleRate
+	}
+	switch sampleRate {
+	case 8000, 12000, 16000, 24000, 48000:
+	default:
+		return fmt.Errorf("invalid sample rate %d, must be 8000, 12000, 16000, 24000 or 48000", sampleRate)
+	}
+	if config.Channels == 0 {
+		config.Channels = channels
+	}
+	if config.Channels == 0 {
+		config.Channels = VoiceChannels
+	}
+	if config.Channels != 1 && config.Channels != 2 {
+		return fmt.Errorf("invalid number of channels %d, must be 1 or 2", config.Channels)
+	}
+	if config.FrameSize == 0 {
+		config.FrameSize = sampleRate / 50 // 20ms
+	}
+
+	if recv == nil || closed == nil {
+		return ErrVoiceNotReady
+	}
+
+	decoders := make(map[uint32]OpusDecoder)
+	defer func() {
+		for _, dec := range decoders {
+			if c, ok := dec.(io.Closer); ok {
+				if cerr := c.Close(); cerr != nil && err == nil {
+					err = fmt.Errorf("error closing opus decoder: %w", cerr)
+				}
+			}
+		}
+	}()
+
+	for {
+		var p *Packet
+		var ok bool
+		select {
+		case p, ok = <-recv:
+			if !ok {
+				return nil
+			}
+		case <-closed:
+			return nil
+		}
+
+		dec, ok := decoders[p.SSRC]
+		if !ok {
+			dec, err = config.NewDecoder(sampleRate, config.Channels)
+			if err != nil {
+				return fmt.Errorf("error creating opus decoder: %w", err)
+			}
+			decoders[p.SSRC] = dec
+		}
+
+		samples, derr := dec.Decode(p.Opus, config.FrameSize, false)
+		if derr != nil {
+			v.log(LogWarning, "error decoding opus packet from ssrc %d, %s", p.SSRC, derr)
+			continue
+		}
+		p.PCM = samples
+
+		select {
+		case pcm <- p:
+		case <-closed:
+			return nil
+		}
+	}
+}
+
 // ChangeChannel sends Discord a request to change channels within a Guild
 // !!! NOTE !!! This function may be removed in favour of just using ChannelVoiceJoin
 func (v *VoiceConnection) ChangeChannel(channelID string, mute, deaf bool) (err error) {
@@ -121,7 +469,7 @@ func (v *VoiceConnection) ChangeChannel(channelID string, mute, deaf bool) (err
 
 	data := voiceChannelJoinOp{4, voiceChannelJoinData{&v.GuildID, &channelID, mute, deaf}}
 	v.session.wsMutex.Lock()
-	err = v.session.wsConn.WriteJSON(data)
+	err = v.session.wsWriteJSON(v.session.wsConn, data.Op, data)
 	v.session.wsMutex.Unlock()
 	if err != nil {
 		return
@@ -143,7 +491,7 @@ func (v *VoiceConnection) Disconnect() (err error) {
 	if v.sessionID != "" {
 		data := voiceChannelJoinOp{4, voiceChannelJoinData{&v.GuildID, nil, true, true}}
 		v.session.wsMutex.Lock()
-		err = v.session.wsConn.WriteJSON(data)
+		err = v.session.wsWriteJSON(v.session.wsConn, data.Op, data)
 		v.session.wsMutex.Unlock()
 		v.sessionID = ""
 	}
@@ -248,27 +596,32 @@ type voiceOP2 struct {
 	IP                string        `json:"ip"`
 }
 
-// WaitUntilConnected waits for the Voice Connection to
-// become ready, if it does not become ready it returns an err
-func (v *VoiceConnection) waitUntilConnected() error {
+// waitUntilConnected waits up to timeout for the Voice Connection to
+// receive its session description and become ready, if it does not
+// become ready it returns an err
+func (v *VoiceConnection) waitUntilConnected(timeout time.Duration) error {
 
 	v.log(LogInformational, "called")
 
-	i := 0
-	for {
-		v.RLock()
-		ready := v.Ready
-		v.RUnlock()
-		if ready {
-			return nil
-		}
+	v.Lock()
+	if v.Ready {
+		v.Unlock()
+		return nil
+	}
+	if v.connected == nil {
+		v.connected = make(chan struct{})
+	}
+	connected := v.connected
+	v.Unlock()
 
-		if i > 10 {
-			return fmt.Errorf("timeout waiting for voice")
-		}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
 
-		time.Sleep(1 * time.Second)
-		i++
+	select {
+	case <-connected:
+		return nil
+	case <-timer.C:
+		return fmt.Errorf("%w to channel %s after %s", ErrVoiceJoinTimeout, v.ChannelID, timeout)
 	}
 }
 
@@ -451,7 +804,12 @@ func (v *VoiceConnection) onEvent(message []byte) {
 		if v.OpusSend == nil {
 			v.OpusSend = make(chan []byte, 2)
 		}
-		go v.opusSender(v.udpConn, v.close, v.OpusSend, 48000, 960)
+		v.Lock()
+		if v.opusFlush == nil {
+			v.opusFlush = make(chan chan struct{})
+		}
+		v.Unlock()
+		go v.opusSender(v.udpConn, v.close, v.OpusSend, VoiceSampleRate, VoiceFrameSize)
 
 		// Start the opusReceiver
 		if !v.deaf {
@@ -477,6 +835,13 @@ func (v *VoiceConnection) onEvent(message []byte) {
 			v.log(LogError, "OP4 unmarshall error, %s, %s", err, string(e.RawData))
 			return
 		}
+
+		// With the secret key audio can be sent, so the connection is ready.
+		v.Ready = true
+		if v.connected != nil {
+			close(v.connected)
+			v.connected = nil
+		}
 		return
 
 	case 5:
@@ -702,11 +1067,7 @@ func (v *VoiceConnection) opusSender(udpConn *net.UDPConn, close <-chan struct{}
 		return
 	}
 
-	// VoiceConnection is now ready to receive audio packets
-	// TODO: this needs reviewed as I think there must be a better way.
-	v.Lock()
-	v.Ready = true
-	v.Unlock()
+	// The VoiceConnection is ready once the session description is received.
 	defer func() {
 		v.Lock()
 		v.Ready = false
@@ -725,6 +1086,14 @@ func (v *VoiceConnection) opusSender(udpConn *net.UDPConn, close <-chan struct{}
 	udpHeader[1] = 0x78
 	binary.BigEndian.PutUint32(udpHeader[8:], v.op2.SSRC)
 
+	v.RLock()
+	flush := v.opusFlush
+	v.RUnlock()
+
+	// Channels waiting for the queued frames to be sent, see Stop.
+	// They are buffered, so signalling them never blocks.
+	var flushed []chan struct{}
+
 	// start a send loop that loops until buf chan is closed
 	ticker := time.NewTicker(time.Millisecond * time.Duration(size/(rate/1000)))
 	defer ticker.Stop()
@@ -734,6 +1103,15 @@ func (v *VoiceConnection) opusSender(udpConn *net.UDPConn, close <-chan struct{}
 		select {
 		case <-close:
 			return
+		case done := <-flush:
+			// Nothing is being sent here, so the queue is flushed once
+			// the frames still in it are sent.
+			if len(opus) == 0 {
+				done <- struct{}{}
+			} else {
+				flushed = append(flushed, done)
+			}
+			continue
 		case recvbuf, ok = <-opus:
 			if !ok {
 				return
@@ -788,10 +1166,19 @@ func (v *VoiceConnection) opusSender(udpConn *net.UDPConn, close <-chan struct{}
 		} else {
 			timestamp += uint32(size)
 		}
+
+		if len(flushed) > 0 && len(opus) == 0 {
+			for _, done := range flushed {
+				done <- struct{}{}
+			}
+			flushed = nil
+		}
 	}
 }
 
 // A Packet contains the headers and content of a received voice packet.
+// Opus is a frame of VoiceSampleRate audio, PCM is only set by ReceivePCM.
+// Packets with the same SSRC are from the same user, see VoiceSpeakingUpdate.
 type Packet struct {
 	SSRC      uint32
 	Sequence  uint16
@@ -846,7 +1233,7 @@ func (v *VoiceConnection) opusReceiver(udpConn *net.UDPConn, close <-chan struct
 
 		// build a audio packet struct
 		p := Packet{}
-		p.Type = recvbuf[0:2]
+		p.Type = []byte{recvbuf[0], recvbuf[1]}
 		p.Sequence = binary.BigEndian.Uint16(recvbuf[2:4])
 		p.Timestamp = binary.BigEndian.Uint32(recvbuf[4:8])
 		p.SSRC = binary.BigEndian.Uint32(recvbuf[8:12])
@@ -936,7 +1323,7 @@ func (v *VoiceConnection) reconnect() {
 		// Send a OP4 with a nil channel to disconnect
 		data := voiceChannelJoinOp{4, voiceChannelJoinData{&v.GuildID, nil, true, true}}
 		v.session.wsMutex.Lock()
-		err = v.session.wsConn.WriteJSON(data)
+		err = v.session.wsWriteJSON(v.session.wsConn, data.Op, data)
 		v.session.wsMutex.Unlock()
 		if err != nil {
 			v.log(LogError, "error sending disconnect packet, %s", err)
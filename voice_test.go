@@ -0,0 +1,245 @@
+package discordgo
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestVoiceWaitUntilConnected(t *testing.T) {
+	v := &VoiceConnection{ChannelID: "channel"}
+
+	err := v.waitUntilConnected(10 * time.Millisecond)
+	if !errors.Is(err, ErrVoiceJoinTimeout) {
+		t.Fatalf("got error %v, want ErrVoiceJoinTimeout", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- v.waitUntilConnected(time.Second)
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	v.onEvent([]byte(`{"op":4,"d":{"secret_key":[1,2,3],"mode":"xsalsa20_poly1305"}}`))
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("got error %v after the session description, want nil", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the session description to be signaled")
+	}
+
+	if err := v.waitUntilConnected(0); err != nil {
+		t.Errorf("got error %v for a ready connection, want nil", err)
+	}
+}
+
+type testOpusEncoder struct {
+	frames  [][]int16
+	bitrate int
+	closed  bool
+}
+
+func (e *testOpusEncoder) Encode(pcm []int16, frameSize, maxDataBytes int) ([]byte, error) {
+	e.frames = append(e.frames, append([]int16(nil), pcm...))
+	return []byte{byte(len(e.frames))}, nil
+}
+
+func (e *testOpusEncoder) SetBitrate(bitrate int) { e.bitrate = bitrate }
+
+func (e *testOpusEncoder) Close() error {
+	e.closed = true
+	return nil
+}
+
+func TestVoiceSendPCM(t *testing.T) {
+	v := &VoiceConnection{OpusSend: make(chan []byte, 10), close: make(chan struct{})}
+	enc := &testOpusEncoder{}
+	config := PCMConfig{
+		NewEncoder: func(sampleRate, channels int) (OpusEncoder, error) {
+			if sampleRate != 48000 || channels != 2 {
+				t.Errorf("got encoder for %dHz with %d channels, want 48000Hz with 2 channels", sampleRate, channels)
+			}
+			return enc, nil
+		},
+	}
+
+	// One and a half frames of stereo audio in uneven chunks.
+	pcm := make(chan []int16, 3)
+	pcm <- make([]int16, 1000)
+	pcm <- make([]int16, 1500)
+	pcm <- []int16{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 1}
+	close(pcm)
+
+	if err := v.SendPCM(pcm, config); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(v.OpusSend) != 2 || len(enc.frames) != 2 {
+		t.Fatalf("got %d queued and %d encoded frames, want 2", len(v.OpusSend), len(enc.frames))
+	}
+	for i, frame := range enc.frames {
+		if len(frame) != 1920 {
+			t.Errorf("frame %d has %d samples, want 1920", i, len(frame))
+		}
+	}
+	if last := enc.frames[1]; last[2500-1920+17] != 1 || last[len(last)-1] != 0 {
+		t.Error("last frame was not made of the remaining samples padded with silence")
+	}
+	if enc.bitrate != 64000 || !enc.closed {
+		t.Errorf("got bitrate %d and closed %v, want 64000 and true", enc.bitrate, enc.closed)
+	}
+
+	if err := (&VoiceConnection{}).SendPCM(pcm, config); err != ErrVoiceNotReady {
+		t.Errorf("got error %v for a closed connection, want ErrVoiceNotReady", err)
+	}
+}
+
+func TestVoiceStop(t *testing.T) {
+	listener, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	conn, err := net.DialUDP("udp", nil, listener.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	v := &VoiceConnection{OpusSend: make(chan []byte, 10), close: make(chan struct{}), opusFlush: make(chan chan struct{}), speaking: true}
+	defer close(v.close)
+	go v.opusSender(conn, v.close, v.OpusSend, VoiceSampleRate, VoiceFrameSize)
+
+	// Without a voice websocket the final speaking notification fails, but
+	// only once all of the silence frames have been sent.
+	start := time.Now()
+	if err := v.Stop(); err == nil {
+		t.Error("Stop() returned no error without a voice websocket")
+	}
+	if elapsed := time.Since(start); elapsed < (opusSilenceFrameCount-1)*20*time.Millisecond {
+		t.Errorf("Stop() returned after %v, before the silence frames could be sent", elapsed)
+	}
+
+	listener.SetReadDeadline(time.Now().Add(time.Second))
+	buf := make([]byte, 128)
+	for i := 0; i < opusSilenceFrameCount; i++ {
+		if _, err := listener.Read(buf); err != nil {
+			t.Fatalf("got %d silence frames before Stop returned, want %d: %v", i, opusSilenceFrameCount, err)
+		}
+	}
+	if len(v.OpusSend) != 0 {
+		t.Errorf("%d frames still queued after Stop", len(v.OpusSend))
+	}
+
+	if err := (&VoiceConnection{}).Stop(); err != ErrVoiceNotReady {
+		t.Errorf("got error %v for a closed connection, want ErrVoiceNotReady", err)
+	}
+}
+
+type testOpusDecoder struct {
+	ssrc   byte
+	closed *int
+}
+
+func (d *testOpusDecoder) Decode(data []byte, frameSize int, fec bool) ([]int16, error) {
+	if len(data) == 0 {
+		return nil, errors.New("empty frame")
+	}
+	return []int16{int16(d.ssrc), int16(data[0])}, nil
+}
+
+func (d *testOpusDecoder) Close() error {
+	*d.closed++
+	return nil
+}
+
+func TestVoiceReceivePCM(t *testing.T) {
+	v := &VoiceConnection{OpusRecv: make(chan *Packet, 4), close: make(chan struct{})}
+	closed := 0
+	config := PCMReceiveConfig{
+		NewDecoder: func(sampleRate, channels int) (OpusDecoder, error) {
+			return &testOpusDecoder{ssrc: byte(len(v.OpusRecv)), closed: &closed}, nil
+		},
+	}
+
+	v.OpusRecv <- &Packet{SSRC: 1, Opus: []byte{10}}
+	v.OpusRecv <- &Packet{SSRC: 2, Opus: []byte{20}}
+	v.OpusRecv <- &Packet{SSRC: 1, Opus: nil}
+	v.OpusRecv <- &Packet{SSRC: 1, Opus: []byte{11}}
+	close(v.OpusRecv)
+
+	pcm := make(chan *Packet, 4)
+	if err := v.ReceivePCM(pcm, config); err != nil {
+		t.Fatal(err)
+	}
+	close(pcm)
+
+	var got [][]int16
+	for p := range pcm {
+		got = append(got, p.PCM)
+	}
+	if len(got) != 3 {
+		t.Fatalf("got %d decoded packets, want 3 without the invalid one", len(got))
+	}
+	if got[0][0] != got[2][0] || got[0][0] == got[1][0] {
+		t.Errorf("got decoded packets %v, want one decoder per SSRC", got)
+	}
+	if closed != 2 {
+		t.Errorf("closed %d decoders, want 2", closed)
+	}
+}
+
+type frameSizeOpusDecoder struct {
+	frameSize *int
+}
+
+func (d frameSizeOpusDecoder) Decode(data []byte, frameSize int, fec bool) ([]int16, error) {
+	*d.frameSize = frameSize
+	return []int16{0}, nil
+}
+
+func TestVoiceReceivePCMDecodeSettings(t *testing.T) {
+	tests := []struct {
+		sampleRate, channels                 int
+		wantSampleRate, wantChannels, wantFS int
+	}{
+		{0, 0, VoiceSampleRate, VoiceChannels, VoiceFrameSize},
+		{16000, 1, 16000, 1, 320},
+	}
+
+	for _, test := range tests {
+		v := &VoiceConnection{
+			OpusRecv:         make(chan *Packet, 1),
+			close:            make(chan struct{}),
+			DecodeSampleRate: test.sampleRate,
+			DecodeChannels:   test.channels,
+		}
+		var sampleRate, channels, frameSize int
+		config := PCMReceiveConfig{
+			NewDecoder: func(r, c int) (OpusDecoder, error) {
+				sampleRate, channels = r, c
+				return frameSizeOpusDecoder{&frameSize}, nil
+			},
+		}
+
+		v.OpusRecv <- &Packet{SSRC: 1, Opus: []byte{1}}
+		close(v.OpusRecv)
+		if err := v.ReceivePCM(make(chan *Packet, 1), config); err != nil {
+			t.Fatal(err)
+		}
+		if sampleRate != test.wantSampleRate || channels != test.wantChannels || frameSize != test.wantFS {
+			t.Errorf("decoded at %dHz with %d channels and frame size %d, want %dHz with %d channels and frame size %d",
+				sampleRate, channels, frameSize, test.wantSampleRate, test.wantChannels, test.wantFS)
+		}
+	}
+
+	v := &VoiceConnection{OpusRecv: make(chan *Packet), close: make(chan struct{}), DecodeSampleRate: 44100}
+	config := PCMReceiveConfig{NewDecoder: func(r, c int) (OpusDecoder, error) { return nil, nil }}
+	if err := v.ReceivePCM(make(chan *Packet), config); err == nil {
+		t.Error("ReceivePCM accepted a sample rate unsupported by opus")
+	}
+}
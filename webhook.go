@@ -47,4 +47,7 @@ type WebhookEdit struct {
 	Embeds          *[]*MessageEmbed        `json:"embeds,omitempty"`
 	Files           []*File                 `json:"-"`
 	AllowedMentions *MessageAllowedMentions `json:"allowed_mentions,omitempty"`
+	// Attachments of the message to retain, all others are removed.
+	// When nil, existing attachments are retained and Files are added to them.
+	Attachments *[]*MessageAttachment `json:"attachments,omitempty"`
 }
@@ -13,13 +13,18 @@ package discordgo
 import (
 	"bytes"
 	"compress/zlib"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"math/rand"
 	"net/http"
+	"sync"
 	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	"github.com/gorilla/websocket"
 )
@@ -32,10 +37,385 @@ var ErrWSAlreadyOpen = errors.New("web socket already opened")
 // that doesn't exist
 var ErrWSNotFound = errors.New("no websocket connection exists")
 
+// ErrGatewayRateLimited is returned when sending a gateway command would
+// exceed Session.GatewayCommandLimit and ShouldWaitOnGatewayRateLimit is false.
+var ErrGatewayRateLimited = errors.New("gateway command rate limit exceeded")
+
+// ErrCustomStatusLength is returned by UpdateCustomStatus when the text is
+// longer than CustomStatusMaxLength characters.
+var ErrCustomStatusLength = errors.New("custom status text must be at most 128 characters")
+
+// CustomStatusMaxLength is the maximum length of the text of a custom status.
+const CustomStatusMaxLength = 128
+
 // ErrWSShardBounds is thrown when you try to use a shard ID that is
 // more than the total shard count
 var ErrWSShardBounds = errors.New("ShardID must be less than ShardCount")
 
+// ErrLargeThresholdBounds is thrown when Identify.LargeThreshold is
+// outside of the range accepted by Discord
+var ErrLargeThresholdBounds = errors.New("Identify.LargeThreshold must be between 50 and 250")
+
+// gatewayRateLimiter tracks recently sent gateway commands.
+type gatewayRateLimiter struct {
+	sync.Mutex
+	sent []time.Time
+}
+
+// gatewayRateLimitWindow is the window the gateway command limit applies to.
+const gatewayRateLimitWindow = 60 * time.Second
+
+// reserve records a command being sent at now if fewer than limit commands
+// were sent within the window, otherwise it returns how long to wait
+// before trying again.
+func (l *gatewayRateLimiter) reserve(limit int, now time.Time) time.Duration {
+	l.Lock()
+	defer l.Unlock()
+
+	expired := 0
+	for expired < len(l.sent) && now.Sub(l.sent[expired]) >= gatewayRateLimitWindow {
+		expired++
+	}
+	l.sent = l.sent[expired:]
+
+	if len(l.sent) < limit {
+		l.sent = append(l.sent, now)
+		return 0
+	}
+
+	return l.sent[0].Add(gatewayRateLimitWindow).Sub(now)
+}
+
+// gatewayCommandWait blocks until a gateway command may be sent under
+// GatewayCommandLimit, or returns ErrGatewayRateLimited if the session
+// should not wait.
+func (s *Session) gatewayCommandWait() error {
+	if s.GatewayCommandLimit <= 0 {
+		return nil
+	}
+
+	for {
+		wait := s.gatewayLimiter.reserve(s.GatewayCommandLimit, time.Now())
+		if wait <= 0 {
+			return nil
+		}
+
+		if !s.ShouldWaitOnGatewayRateLimit {
+			return ErrGatewayRateLimited
+		}
+
+		s.log(LogInformational, "gateway command rate limited, waiting %v", wait)
+		time.Sleep(wait)
+	}
+}
+
+// ConnectionState is the state of the gateway connection of a Session.
+type ConnectionState int
+
+// Gateway connection states
+const (
+	ConnectionStateDisconnected ConnectionState = iota
+	ConnectionStateConnecting
+	ConnectionStateIdentifying
+	ConnectionStateResuming
+	ConnectionStateReady
+)
+
+// String returns a human-readable name of the connection state.
+func (c ConnectionState) String() string {
+	switch c {
+	case ConnectionStateDisconnected:
+		return "Disconnected"
+	case ConnectionStateConnecting:
+		return "Connecting"
+	case ConnectionStateIdentifying:
+		return "Identifying"
+	case ConnectionStateResuming:
+		return "Resuming"
+	case ConnectionStateReady:
+		return "Ready"
+	}
+	return fmt.Sprintf("ConnectionState(%d)", int(c))
+}
+
+// connectionStateTracker tracks the connection state of a session and
+// notifies callbacks of every change, in order.
+type connectionStateTracker struct {
+	sync.Mutex
+	state       ConnectionState
+	callbacks   []*func(ConnectionState)
+	pending     []ConnectionState
+	dispatching bool
+}
+
+// ConnectionState returns the current state of the gateway connection.
+func (s *Session) ConnectionState() ConnectionState {
+	s.connState.Lock()
+	defer s.connState.Unlock()
+
+	return s.connState.state
+}
+
+// ResumeState holds the data used to resume a gateway session.
+type ResumeState struct {
+	SessionID string
+	Sequence  int64
+	// The gateway URL for resuming, given in the READY event.
+	// When empty, the session is resumed on the main gateway.
+	GatewayURL string
+}
+
+// ResumeState returns the data the session will use to resume the current
+// gateway session when reconnecting.
+func (s *Session) ResumeState() ResumeState {
+	s.RLock()
+	defer s.RUnlock()
+
+	return ResumeState{
+		SessionID:  s.sessionID,
+		Sequence:   atomic.LoadInt64(s.sequence),
+		GatewayURL: s.resumeGatewayURL,
+	}
+}
+
+// OnConnectionStateChange registers a callback which is called every time
+// the state of the gateway connection changes, for example to report the
+// health of the connection. Callbacks are called in order of the changes,
+// from a separate goroutine, and so may use the session.
+// The return value is a function which removes the callback.
+func (s *Session) OnConnectionStateChange(callback func(state ConnectionState)) func() {
+	s.connState.Lock()
+	defer s.connState.Unlock()
+
+	cb := &callback
+	s.connState.callbacks = append(s.connState.callbacks, cb)
+
+	return func() {
+		s.connState.Lock()
+		defer s.connState.Unlock()
+
+		for i, c := range s.connState.callbacks {
+			if c == cb {
+				s.connState.callbacks = append(s.connState.callbacks[:i:i], s.connState.callbacks[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// WaitReady blocks until the gateway connection is ready, that is until READY
+// or RESUMED is received, or the context is done. It returns immediately if
+// the connection is already ready.
+func (s *Session) WaitReady(ctx context.Context) error {
+	ready := make(chan struct{}, 1)
+	remove := s.OnConnectionStateChange(func(state ConnectionState) {
+		if state == ConnectionStateReady {
+			select {
+			case ready <- struct{}{}:
+			default:
+			}
+		}
+	})
+	defer remove()
+
+	if s.ConnectionState() == ConnectionStateReady {
+		return nil
+	}
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ShardSessions gives a unified view of the sessions of multiple shards,
+// e.g. ShardSessions{shard0, shard1}.
+type ShardSessions []*Session
+
+// ShardReady reports whether the gateway connection of the session with the
+// given shard ID is ready. It returns false if there is no such session.
+func (ss ShardSessions) ShardReady(shardID int) bool {
+	for _, s := range ss {
+		if s.ShardID == shardID {
+			return s.ConnectionState() == ConnectionStateReady
+		}
+	}
+	return false
+}
+
+// WaitReady blocks until the gateway connections of all shards are ready, for
+// example before registering commands or reporting the bot as healthy. If the
+// context is done first, the error names the first shard which is not ready.
+func (ss ShardSessions) WaitReady(ctx context.Context) error {
+	for _, s := range ss {
+		if err := s.WaitReady(ctx); err != nil {
+			return fmt.Errorf("shard %d is not ready: %w", s.ShardID, err)
+		}
+	}
+	return nil
+}
+
+// setConnectionState changes the connection state, notifying callbacks if it
+// differs from the current state.
+func (s *Session) setConnectionState(state ConnectionState) {
+	t := &s.connState
+	t.Lock()
+	defer t.Unlock()
+
+	if t.state == state {
+		return
+	}
+	t.state = state
+	s.log(LogDebug, "connection state changed to %s", state)
+
+	t.pending = append(t.pending, state)
+	if !t.dispatching {
+		t.dispatching = true
+		go s.dispatchConnectionStates()
+	}
+}
+
+// dispatchConnectionStates calls the callbacks for pending state changes
+// until there are none left.
+func (s *Session) dispatchConnectionStates() {
+	t := &s.connState
+	for {
+		t.Lock()
+		if len(t.pending) == 0 {
+			t.dispatching = false
+			t.Unlock()
+			return
+		}
+		state := t.pending[0]
+		t.pending = t.pending[1:]
+		callbacks := t.callbacks
+		t.Unlock()
+
+		for _, cb := range callbacks {
+			(*cb)(state)
+		}
+	}
+}
+
+// rawGatewayHooks holds the callbacks registered with OnRawGatewayMessage
+// and OnRawGatewayCommand. count is read atomically, so frames are only
+// copied while at least one hook is registered.
+type rawGatewayHooks struct {
+	sync.RWMutex
+	count    int32
+	incoming []*func(int, string, []byte)
+	outgoing []*func(int, []byte)
+}
+
+// OnRawGatewayMessage registers a callback which is called with every
+// message received from the gateway, after decompression and before the
+// event data is decoded, for example to diagnose payloads the library
+// fails to parse. eventType is empty for messages other than dispatches.
+// raw is a copy of the message and may be retained.
+// Callbacks are called from the goroutine reading the gateway and must not
+// block it.
+// The return value is a function which removes the callback.
+func (s *Session) OnRawGatewayMessage(callback func(opcode int, eventType string, raw []byte)) func() {
+	h := &s.rawGateway
+	h.Lock()
+	defer h.Unlock()
+
+	cb := &callback
+	h.incoming = append(h.incoming, cb)
+	atomic.AddInt32(&h.count, 1)
+
+	return func() {
+		h.Lock()
+		defer h.Unlock()
+
+		for i, c := range h.incoming {
+			if c == cb {
+				h.incoming = append(h.incoming[:i:i], h.incoming[i+1:]...)
+				atomic.AddInt32(&h.count, -1)
+				break
+			}
+		}
+	}
+}
+
+// OnRawGatewayCommand registers a callback which is called with every
+// message sent to the gateway, such as identify and heartbeat payloads,
+// before it is written. raw is a copy of the message and may be retained.
+// Note that identify and resume payloads contain the token.
+// Callbacks are called while the gateway connection is locked for writing,
+// so they must not block or send gateway commands themselves.
+// The return value is a function which removes the callback.
+func (s *Session) OnRawGatewayCommand(callback func(opcode int, raw []byte)) func() {
+	h := &s.rawGateway
+	h.Lock()
+	defer h.Unlock()
+
+	cb := &callback
+	h.outgoing = append(h.outgoing, cb)
+	atomic.AddInt32(&h.count, 1)
+
+	return func() {
+		h.Lock()
+		defer h.Unlock()
+
+		for i, c := range h.outgoing {
+			if c == cb {
+				h.outgoing = append(h.outgoing[:i:i], h.outgoing[i+1:]...)
+				atomic.AddInt32(&h.count, -1)
+				break
+			}
+		}
+	}
+}
+
+// rawGatewayHooksEnabled reports whether any raw gateway hook is registered.
+func (s *Session) rawGatewayHooksEnabled() bool {
+	return atomic.LoadInt32(&s.rawGateway.count) > 0
+}
+
+// onRawGatewayMessage calls the OnRawGatewayMessage callbacks, each with
+// its own copy of raw.
+func (s *Session) onRawGatewayMessage(opcode int, eventType string, raw []byte) {
+	s.rawGateway.RLock()
+	callbacks := s.rawGateway.incoming
+	s.rawGateway.RUnlock()
+
+	for _, cb := range callbacks {
+		(*cb)(opcode, eventType, append([]byte(nil), raw...))
+	}
+}
+
+// wsWriteJSON writes v as a JSON message to conn, passing it to the
+// OnRawGatewayCommand callbacks first. The caller must hold wsMutex.
+func (s *Session) wsWriteJSON(conn *websocket.Conn, opcode int, v interface{}) error {
+	if !s.rawGatewayHooksEnabled() {
+		return conn.WriteJSON(v)
+	}
+
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	s.rawGateway.RLock()
+	callbacks := s.rawGateway.outgoing
+	s.rawGateway.RUnlock()
+
+	for _, cb := range callbacks {
+		(*cb)(opcode, append([]byte(nil), raw...))
+	}
+
+	return conn.WriteMessage(websocket.TextMessage, raw)
+}
+
+// invalidSessionWait returns the random time to wait before responding to
+// an Op 9 Invalid Session packet.
+var invalidSessionWait = func() time.Duration {
+	return time.Second + time.Duration(rand.Int63n(int64(4*time.Second)))
+}
+
 type resumePacket struct {
 	Op   int `json:"op"`
 	Data struct {
@@ -48,9 +428,16 @@ type resumePacket struct {
 // Open creates a websocket connection to Discord.
 // See: https://discord.com/developers/docs/topics/gateway#connecting
 func (s *Session) Open() error {
+	return s.open(1)
+}
+
+// open opens the websocket connection like Open, attempt is the number of
+// the attempt to connect reported in the Connect event.
+func (s *Session) open(attempt int) error {
 	s.log(LogInformational, "called")
 
 	var err error
+	start := time.Now()
 
 	// Prevent Open or other major Session functions from
 	// being called while Open is still running.
@@ -62,26 +449,45 @@ func (s *Session) Open() error {
 		return ErrWSAlreadyOpen
 	}
 
+	s.setConnectionState(ConnectionStateConnecting)
+	defer func() {
+		if err != nil {
+			s.setConnectionState(ConnectionStateDisconnected)
+		}
+	}()
+
 	// Get the gateway to use for the Websocket connection
 	if s.gateway == "" {
-		s.gateway, err = s.Gateway()
-		if err != nil {
-			return err
+		if s.GatewayURL != "" {
+			s.gateway = s.GatewayURL
+		} else {
+			s.gateway, err = s.Gateway()
+			if err != nil {
+				return err
+			}
 		}
 
 		// Add the version and encoding to the URL
-		s.gateway = s.gateway + "?v=" + APIVersion + "&encoding=json"
+		s.gateway = s.gateway + "?v=" + s.apiVersion() + "&encoding=json"
+	}
+
+	// Sessions are resumed on the gateway given in the READY event.
+	resume := s.sessionID != "" || atomic.LoadInt64(s.sequence) != 0
+	gateway := s.gateway
+	if resume && s.resumeGatewayURL != "" {
+		gateway = s.resumeGatewayURL + "?v=" + s.apiVersion() + "&encoding=json"
 	}
 
 	// Connect to the Gateway
-	s.log(LogInformational, "connecting to gateway %s", s.gateway)
+	s.log(LogInformational, "connecting to gateway %s", gateway)
 	header := http.Header{}
 	header.Add("accept-encoding", "zlib")
-	s.wsConn, _, err = s.Dialer.Dial(s.gateway, header)
+	s.wsConn, _, err = s.Dialer.Dial(gateway, header)
 	if err != nil {
-		s.log(LogError, "error connecting to gateway %s, %s", s.gateway, err)
-		s.gateway = "" // clear cached gateway
-		s.wsConn = nil // Just to be safe.
+		s.log(LogError, "error connecting to gateway %s, %s", gateway, err)
+		s.gateway = ""          // clear cached gateway
+		s.resumeGatewayURL = "" // fall back to the main gateway for resuming
+		s.wsConn = nil          // Just to be safe.
 		return err
 	}
 
@@ -123,29 +529,22 @@ func (s *Session) Open() error {
 
 	// Now we send either an Op 2 Identity if this is a brand new
 	// connection or Op 6 Resume if we are resuming an existing connection.
-	sequence := atomic.LoadInt64(s.sequence)
-	if s.sessionID == "" && sequence == 0 {
+	if !resume {
 
 		// Send Op 2 Identity Packet
+		s.setConnectionState(ConnectionStateIdentifying)
 		err = s.identify()
 		if err != nil {
-			err = fmt.Errorf("error sending identify packet to gateway, %s, %s", s.gateway, err)
+			err = fmt.Errorf("error sending identify packet to gateway, %s, %w", s.gateway, err)
 			return err
 		}
 
 	} else {
 
 		// Send Op 6 Resume Packet
-		p := resumePacket{}
-		p.Op = 6
-		p.Data.Token = s.Token
-		p.Data.SessionID = s.sessionID
-		p.Data.Sequence = sequence
-
 		s.log(LogInformational, "sending resume packet to gateway")
-		s.wsMutex.Lock()
-		err = s.wsConn.WriteJSON(p)
-		s.wsMutex.Unlock()
+		s.setConnectionState(ConnectionStateResuming)
+		err = s.resume()
 		if err != nil {
 			err = fmt.Errorf("error sending gateway resume packet, %s, %s", s.gateway, err)
 			return err
@@ -183,7 +582,11 @@ func (s *Session) Open() error {
 	s.log(LogInformational, "First Packet:\n%#v\n", e)
 
 	s.log(LogInformational, "We are now connected to Discord, emitting connect event")
-	s.handleEvent(connectEventType, &Connect{})
+	s.handleEvent(connectEventType, &Connect{
+		Attempt: attempt,
+		Resumed: e.Type == `RESUMED`,
+		Latency: time.Since(start),
+	})
 
 	// A VoiceConnections map is a hard requirement for Voice.
 	// XXX: can this be moved to when opening a voice connection?
@@ -198,7 +601,11 @@ func (s *Session) Open() error {
 	s.listening = make(chan interface{})
 
 	// Start sending heartbeats and reading messages from Discord.
-	go s.heartbeat(s.wsConn, s.listening, h.HeartbeatInterval)
+	heartbeatInterval := h.HeartbeatInterval * time.Millisecond
+	if s.HeartbeatInterval > 0 {
+		heartbeatInterval = s.HeartbeatInterval
+	}
+	go s.heartbeat(s.wsConn, s.listening, heartbeatInterval)
 	go s.listen(s.wsConn, s.listening)
 
 	s.log(LogInformational, "exiting")
@@ -229,7 +636,9 @@ func (s *Session) listen(wsConn *websocket.Conn, listening <-chan interface{}) {
 				s.log(LogWarning, "error reading from gateway %s websocket, %s", s.gateway, err)
 				// There has been an error reading, close the websocket so that
 				// OnDisconnect event is emitted.
-				err := s.Close()
+				d := disconnectFromError(err)
+				d.Reconnect = s.ShouldReconnectOnError
+				err := s.closeWithEvent(websocket.CloseNormalClosure, d)
 				if err != nil {
 					s.log(LogWarning, "error closing session connection, %s", err)
 				}
@@ -265,6 +674,14 @@ type helloOp struct {
 // FailedHeartbeatAcks is the Number of heartbeat intervals to wait until forcing a connection restart.
 const FailedHeartbeatAcks time.Duration = 5 * time.Millisecond
 
+// heartbeatTimeout returns how long to wait for a heartbeat ACK before the
+// connection is considered dead. FailedHeartbeatAcks is a count stored as a
+// number of milliseconds, so it is converted to a plain number first, which
+// keeps long heartbeat intervals from overflowing.
+func heartbeatTimeout(heartbeatInterval time.Duration) time.Duration {
+	return heartbeatInterval * time.Duration(FailedHeartbeatAcks/time.Millisecond)
+}
+
 // HeartbeatLatency returns the latency between heartbeat acknowledgement and heartbeat send.
 func (s *Session) HeartbeatLatency() time.Duration {
 
@@ -275,7 +692,7 @@ func (s *Session) HeartbeatLatency() time.Duration {
 // heartbeat sends regular heartbeats to Discord so it knows the client
 // is still connected.  If you do not send these heartbeats Discord will
 // disconnect the websocket connection after a few seconds.
-func (s *Session) heartbeat(wsConn *websocket.Conn, listening <-chan interface{}, heartbeatIntervalMsec time.Duration) {
+func (s *Session) heartbeat(wsConn *websocket.Conn, listening <-chan interface{}, heartbeatInterval time.Duration) {
 
 	s.log(LogInformational, "called")
 
@@ -284,7 +701,7 @@ func (s *Session) heartbeat(wsConn *websocket.Conn, listening <-chan interface{}
 	}
 
 	var err error
-	ticker := time.NewTicker(heartbeatIntervalMsec * time.Millisecond)
+	ticker := time.NewTicker(heartbeatInterval)
 	defer ticker.Stop()
 
 	for {
@@ -295,15 +712,25 @@ func (s *Session) heartbeat(wsConn *websocket.Conn, listening <-chan interface{}
 		s.log(LogDebug, "sending gateway websocket heartbeat seq %d", sequence)
 		s.wsMutex.Lock()
 		s.LastHeartbeatSent = time.Now().UTC()
-		err = wsConn.WriteJSON(heartbeatOp{1, sequence})
+		err = s.wsWriteJSON(wsConn, 1, heartbeatOp{1, sequence})
 		s.wsMutex.Unlock()
-		if err != nil || time.Now().UTC().Sub(last) > (heartbeatIntervalMsec*FailedHeartbeatAcks) {
+		if err != nil || time.Now().UTC().Sub(last) > heartbeatTimeout(heartbeatInterval) {
+			// The connection was closed while sending, don't reconnect it.
+			select {
+			case <-listening:
+				return
+			default:
+			}
+
+			d := &Disconnect{CloseCode: websocket.CloseAbnormalClosure, Reconnect: s.ShouldReconnectOnError}
 			if err != nil {
 				s.log(LogError, "error sending heartbeat to gateway %s, %s", s.gateway, err)
+				d.CloseReason = err.Error()
 			} else {
 				s.log(LogError, "haven't gotten a heartbeat ACK in %v, triggering a reconnection", time.Now().UTC().Sub(last))
+				d.CloseReason = "no heartbeat ACK received"
 			}
-			s.Close()
+			s.closeWithEvent(websocket.CloseNormalClosure, d)
 			s.reconnect()
 			return
 		}
@@ -389,6 +816,34 @@ func (s *Session) UpdateListeningStatus(name string) (err error) {
 	return s.UpdateStatusComplex(*newUpdateStatusData(0, ActivityTypeListening, name, ""))
 }
 
+// UpdateCustomStatus is used to set a custom status, shown like a custom
+// status set by users in the client. If text and emoji are empty the custom
+// status is removed.
+// text  : The text of the custom status, up to CustomStatusMaxLength characters.
+// emoji : The emoji shown before the text, nil for none. Only the ID, Name and Animated fields are sent.
+func (s *Session) UpdateCustomStatus(text string, emoji *Emoji) (err error) {
+	if utf8.RuneCountInString(text) > CustomStatusMaxLength {
+		return ErrCustomStatusLength
+	}
+
+	usd := UpdateStatusData{Status: "online"}
+	if text != "" || emoji != nil {
+		// Custom activities show their state, the name has to be set
+		// but is not shown.
+		activity := &Activity{
+			Name:  "Custom Status",
+			Type:  ActivityTypeCustom,
+			State: text,
+		}
+		if emoji != nil {
+			activity.Emoji = Emoji{ID: emoji.ID, Name: emoji.Name, Animated: emoji.Animated}
+		}
+		usd.Activities = []*Activity{activity}
+	}
+
+	return s.UpdateStatusComplex(usd)
+}
+
 // UpdateStatusComplex allows for sending the raw status update data untouched by discordgo.
 func (s *Session) UpdateStatusComplex(usd UpdateStatusData) (err error) {
 	// The comment does say "untouched by discordgo", but we might need to lie a bit here.
@@ -403,6 +858,10 @@ func (s *Session) UpdateStatusComplex(usd UpdateStatusData) (err error) {
 		usd.Activities = make([]*Activity, 0)
 	}
 
+	if err = s.gatewayCommandWait(); err != nil {
+		return
+	}
+
 	s.RLock()
 	defer s.RUnlock()
 	if s.wsConn == nil {
@@ -410,7 +869,7 @@ func (s *Session) UpdateStatusComplex(usd UpdateStatusData) (err error) {
 	}
 
 	s.wsMutex.Lock()
-	err = s.wsConn.WriteJSON(updateStatusOp{3, usd})
+	err = s.wsWriteJSON(s.wsConn, 3, updateStatusOp{3, usd})
 	s.wsMutex.Unlock()
 
 	return
@@ -498,6 +957,10 @@ func (s *Session) RequestGuildMembersBatchList(guildIDs []string, userIDs []stri
 func (s *Session) requestGuildMembers(data requestGuildMembersData) (err error) {
 	s.log(LogInformational, "called")
 
+	if err = s.gatewayCommandWait(); err != nil {
+		return
+	}
+
 	s.RLock()
 	defer s.RUnlock()
 	if s.wsConn == nil {
@@ -505,7 +968,7 @@ func (s *Session) requestGuildMembers(data requestGuildMembersData) (err error)
 	}
 
 	s.wsMutex.Lock()
-	err = s.wsConn.WriteJSON(requestGuildMembersOp{8, data})
+	err = s.wsWriteJSON(s.wsConn, 8, requestGuildMembersOp{8, data})
 	s.wsMutex.Unlock()
 
 	return
@@ -544,6 +1007,16 @@ func (s *Session) onEvent(messageType int, message []byte) (*Event, error) {
 		reader = z
 	}
 
+	// Keep the decompressed message for the raw gateway hooks.
+	var raw []byte
+	if s.rawGatewayHooksEnabled() {
+		if raw, err = ioutil.ReadAll(reader); err != nil {
+			s.log(LogError, "error reading websocket message, %s", err)
+			return nil, err
+		}
+		reader = bytes.NewReader(raw)
+	}
+
 	// Decode the event into an Event struct.
 	var e *Event
 	decoder := json.NewDecoder(reader)
@@ -552,6 +1025,10 @@ func (s *Session) onEvent(messageType int, message []byte) (*Event, error) {
 		return e, err
 	}
 
+	if raw != nil {
+		s.onRawGatewayMessage(e.Operation, e.Type, raw)
+	}
+
 	s.log(LogDebug, "Op: %d, Seq: %d, Type: %s, Data: %s\n\n", e.Operation, e.Sequence, e.Type, string(e.RawData))
 
 	// Ping request.
@@ -559,7 +1036,7 @@ func (s *Session) onEvent(messageType int, message []byte) (*Event, error) {
 	if e.Operation == 1 {
 		s.log(LogInformational, "sending heartbeat in response to Op1")
 		s.wsMutex.Lock()
-		err = s.wsConn.WriteJSON(heartbeatOp{1, atomic.LoadInt64(s.sequence)})
+		err = s.wsWriteJSON(s.wsConn, 1, heartbeatOp{1, atomic.LoadInt64(s.sequence)})
 		s.wsMutex.Unlock()
 		if err != nil {
 			s.log(LogError, "error sending heartbeat in response to Op1")
@@ -573,23 +1050,26 @@ func (s *Session) onEvent(messageType int, message []byte) (*Event, error) {
 	// Must immediately disconnect from gateway and reconnect to new gateway.
 	if e.Operation == 7 {
 		s.log(LogInformational, "Closing and reconnecting in response to Op7")
-		s.CloseWithCode(websocket.CloseServiceRestart)
+		s.closeWithEvent(websocket.CloseServiceRestart, &Disconnect{
+			CloseReason: "reconnect requested by Discord",
+			Reconnect:   s.ShouldReconnectOnError,
+		})
 		s.reconnect()
 		return e, nil
 	}
 
 	// Invalid Session
-	// Must respond with a Identify packet.
+	// Must respond by resuming the session if it is resumable, otherwise
+	// with an Identify packet, after waiting 1 to 5 seconds.
 	if e.Operation == 9 {
 
-		s.log(LogInformational, "sending identify packet to gateway in response to Op9")
-
-		err = s.identify()
-		if err != nil {
-			s.log(LogWarning, "error sending gateway identify packet, %s, %s", s.gateway, err)
-			return e, err
+		var resumable bool
+		if err = json.Unmarshal(e.RawData, &resumable); err != nil {
+			s.log(LogWarning, "error unmarshalling invalid session packet, %s", err)
 		}
 
+		// Wait off the listen goroutine, so heartbeat ACKs are still read.
+		go s.onInvalidSession(s.wsConn, resumable)
 		return e, nil
 	}
 
@@ -617,6 +1097,12 @@ func (s *Session) onEvent(messageType int, message []byte) (*Event, error) {
 	// Store the message sequence
 	atomic.StoreInt64(s.sequence, e.Sequence)
 
+	s.countEvent(e.Type)
+
+	if e.Type == "READY" || e.Type == "RESUMED" {
+		s.setConnectionState(ConnectionStateReady)
+	}
+
 	// Map event to registered event handlers and pass it along to any registered handlers.
 	if eh, ok := registeredInterfaceProviders[e.Type]; ok {
 		e.Struct = eh.New()
@@ -633,7 +1119,18 @@ func (s *Session) onEvent(messageType int, message []byte) (*Event, error) {
 		// it's better to pass along what we received than nothing at all.
 		// TODO: Think about that decision :)
 		// Either way, READY events must fire, even with errors.
+		s.logUnknownEnums(e.Struct)
 		s.handleEvent(e.Type, e.Struct)
+
+		if d, ok := e.Struct.(*GuildDelete); ok && d.Guild != nil && d.Unavailable {
+			s.handleEvent(guildUnavailableEventType, &GuildUnavailable{GuildID: d.ID})
+		}
+		if s.guildJoined(e.Struct) {
+			s.handleEvent(guildJoinEventType, &GuildJoin{e.Struct.(*GuildCreate).Guild})
+		}
+		if l := s.guildsLoaded(e.Struct); l != nil {
+			s.handleEvent(guildsLoadedEventType, l)
+		}
 	} else {
 		s.log(LogWarning, "unknown event: Op: %d, Seq: %d, Type: %s, Data: %s", e.Operation, e.Sequence, e.Type, string(e.RawData))
 	}
@@ -644,6 +1141,136 @@ func (s *Session) onEvent(messageType int, message []byte) (*Event, error) {
 	return e, nil
 }
 
+// guildJoined keeps track of the guilds of the current user and reports
+// whether an event is the GuildCreate of a guild the user joined, rather than
+// of a guild listed in READY which became available.
+func (s *Session) guildJoined(i interface{}) bool {
+	switch t := i.(type) {
+	case *Ready:
+		s.knownGuilds = make(map[string]bool, len(t.Guilds))
+		for _, g := range t.Guilds {
+			s.knownGuilds[g.ID] = true
+		}
+	case *GuildCreate:
+		if t.Guild == nil || s.knownGuilds == nil {
+			return false
+		}
+		joined := !s.knownGuilds[t.ID]
+		s.knownGuilds[t.ID] = true
+		return joined
+	case *GuildDelete:
+		if t.Guild != nil && !t.Unavailable {
+			delete(s.knownGuilds, t.ID)
+		}
+	}
+	return false
+}
+
+// guildsLoaded keeps track of the guilds listed in READY which have not been
+// received yet and returns a GuildsLoaded event once the last one is.
+func (s *Session) guildsLoaded(i interface{}) *GuildsLoaded {
+	switch t := i.(type) {
+	case *Ready:
+		s.readyAt = time.Now()
+		s.pendingGuilds = make(map[string]bool, len(t.Guilds))
+		for _, g := range t.Guilds {
+			s.pendingGuilds[g.ID] = true
+		}
+	case *GuildCreate:
+		if t.Guild == nil || !s.pendingGuilds[t.ID] {
+			return nil
+		}
+		delete(s.pendingGuilds, t.ID)
+	case *GuildDelete:
+		if t.Guild == nil || t.Unavailable || !s.pendingGuilds[t.ID] {
+			return nil
+		}
+		delete(s.pendingGuilds, t.ID)
+	default:
+		return nil
+	}
+
+	if s.pendingGuilds == nil || len(s.pendingGuilds) > 0 {
+		return nil
+	}
+	s.pendingGuilds = nil
+	return &GuildsLoaded{GuildCount: len(s.knownGuilds), Duration: time.Since(s.readyAt)}
+}
+
+// logUnknownEnums logs a warning, or calls OnUnknownEnum, the first time a
+// channel or message type missing from this package is received in an
+// event, as it is likely new and may not be handled by the application.
+func (s *Session) logUnknownEnums(i interface{}) {
+	var channels []*Channel
+	var message *Message
+	switch t := i.(type) {
+	case *ChannelCreate:
+		channels = []*Channel{t.Channel}
+	case *ChannelUpdate:
+		channels = []*Channel{t.Channel}
+	case *ThreadCreate:
+		channels = []*Channel{t.Channel}
+	case *ThreadUpdate:
+		channels = []*Channel{t.Channel}
+	case *GuildCreate:
+		if t.Guild != nil {
+			channels = append(t.Channels[:len(t.Channels):len(t.Channels)], t.Threads...)
+		}
+	case *ThreadListSync:
+		channels = t.Threads
+	case *MessageCreate:
+		message = t.Message
+	case *MessageUpdate:
+		message = t.Message
+	}
+
+	for _, c := range channels {
+		if c != nil && !c.Type.IsKnown() {
+			s.logUnknownEnum("ChannelType", int(c.Type))
+		}
+	}
+	if message != nil && !message.Type.IsKnown() {
+		s.logUnknownEnum("MessageType", int(message.Type))
+	}
+}
+
+func (s *Session) logUnknownEnum(name string, value int) {
+	key := fmt.Sprintf("%s(%d)", name, value)
+	if _, logged := s.unknownEnums.LoadOrStore(key, true); logged {
+		return
+	}
+
+	if s.OnUnknownEnum != nil {
+		s.OnUnknownEnum(name, value)
+		return
+	}
+	s.log(LogWarning, "received unknown %s %d, it may be new and unsupported", name, value)
+}
+
+// countEvent increments the dispatch counter for the given event type.
+func (s *Session) countEvent(t string) {
+	s.eventStatsMu.Lock()
+	if s.eventStats == nil {
+		s.eventStats = make(map[string]uint64)
+	}
+	s.eventStats[t]++
+	s.eventStatsMu.Unlock()
+}
+
+// EventStats returns the number of gateway events dispatched to this session
+// so far, keyed by event type (e.g. "MESSAGE_CREATE").
+// The returned map is a copy and may be freely modified.
+func (s *Session) EventStats() map[string]uint64 {
+	s.eventStatsMu.Lock()
+	defer s.eventStatsMu.Unlock()
+
+	stats := make(map[string]uint64, len(s.eventStats))
+	for t, n := range s.eventStats {
+		stats[t] = n
+	}
+	return stats
+}
+
 // ------------------------------------------------------------------------------------------------
 // Code related to voice connections that initiate over the data websocket
 // ------------------------------------------------------------------------------------------------
@@ -661,6 +1288,8 @@ type voiceChannelJoinOp struct {
 }
 
 // ChannelVoiceJoin joins the session user to a voice channel.
+// It waits up to VoiceJoinTimeout for the voice connection to be ready,
+// returning an error wrapping ErrVoiceJoinTimeout otherwise.
 //
 //    gID     : Guild ID of the channel to join.
 //    cID     : Channel ID of the channel to join.
@@ -694,8 +1323,11 @@ func (s *Session) ChannelVoiceJoin(gID, cID string, mute, deaf bool) (voice *Voi
 		return
 	}
 
-	// doesn't exactly work perfect yet.. TODO
-	err = voice.waitUntilConnected()
+	timeout := s.VoiceJoinTimeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	err = voice.waitUntilConnected(timeout)
 	if err != nil {
 		s.log(LogWarning, "error waiting for voice to connect, %s", err)
 		voice.Close()
@@ -717,17 +1349,37 @@ func (s *Session) ChannelVoiceJoinManual(gID, cID string, mute, deaf bool) (err
 
 	s.log(LogInformational, "called")
 
-	var channelID *string
-	if cID == "" {
-		channelID = nil
-	} else {
-		channelID = &cID
+	return s.UpdateVoiceState(gID, cID, mute, deaf)
+}
+
+// UpdateVoiceState sends a voice state update to the gateway, joining, moving
+// or leaving a voice channel without touching the VoiceConnections of the
+// session. The VoiceStateUpdate and VoiceServerUpdate events which follow are
+// dispatched to handlers as usual, so that they can be passed to an external
+// voice implementation such as Lavalink.
+// guildID   : Guild ID of the channel to join.
+// channelID : Channel ID of the channel to join, leave empty to disconnect.
+// selfMute  : If true, you will be set to muted.
+// selfDeaf  : If true, you will be set to deafened.
+func (s *Session) UpdateVoiceState(guildID, channelID string, selfMute, selfDeaf bool) (err error) {
+	var cID *string
+	if channelID != "" {
+		cID = &channelID
+	}
+
+	if err = s.gatewayCommandWait(); err != nil {
+		return
 	}
 
-	// Send the request to Discord that we want to join the voice channel
-	data := voiceChannelJoinOp{4, voiceChannelJoinData{&gID, channelID, mute, deaf}}
+	s.RLock()
+	defer s.RUnlock()
+	if s.wsConn == nil {
+		return ErrWSNotFound
+	}
+
+	data := voiceChannelJoinOp{4, voiceChannelJoinData{&guildID, cID, selfMute, selfDeaf}}
 	s.wsMutex.Lock()
-	err = s.wsConn.WriteJSON(data)
+	err = s.wsWriteJSON(s.wsConn, data.Op, data)
 	s.wsMutex.Unlock()
 	return
 }
@@ -749,7 +1401,7 @@ func (s *Session) onVoiceStateUpdate(st *VoiceStateUpdate) {
 	}
 
 	// We only care about events that are about us.
-	if s.State.User.ID != st.UserID {
+	if s.State == nil || s.State.User == nil || s.State.User.ID != st.UserID {
 		return
 	}
 
@@ -830,16 +1482,187 @@ func (s *Session) identify() error {
 		s.Identify.Shard = &[2]int{s.ShardID, s.ShardCount}
 	}
 
+	if s.Identify.LargeThreshold != 0 && (s.Identify.LargeThreshold < 50 || s.Identify.LargeThreshold > 250) {
+		return ErrLargeThresholdBounds
+	}
+
+	for _, warning := range s.intentWarnings() {
+		s.log(LogWarning, "%s", warning)
+	}
+
+	if s.CheckSessionStartLimit {
+		if err := s.reserveSessionStart(); err != nil {
+			return err
+		}
+	} else {
+		s.sessionStartLimit.consume()
+	}
+
 	// Send Identify packet to Discord
 	op := identifyOp{2, s.Identify}
 	s.log(LogDebug, "Identify Packet: \n%#v", op)
 	s.wsMutex.Lock()
-	err := s.wsConn.WriteJSON(op)
+	err := s.wsWriteJSON(s.wsConn, op.Op, op)
+	s.wsMutex.Unlock()
+
+	return err
+}
+
+// SessionStartLimitError is returned when identifying with
+// CheckSessionStartLimit set and the session start limit is used up.
+type SessionStartLimitError struct {
+	// The time at which the limit is reset.
+	ResetAt time.Time
+}
+
+func (e *SessionStartLimitError) Error() string {
+	return "session start limit exhausted, resets at " + e.ResetAt.Format(time.RFC3339)
+}
+
+// sessionStartLimit tracks the session start limit returned by GatewayBot,
+// counting the identifies made since.
+type sessionStartLimit struct {
+	sync.Mutex
+	known   bool
+	info    SessionInformation
+	resetAt time.Time
+}
+
+func (l *sessionStartLimit) set(info SessionInformation) {
+	l.Lock()
+	defer l.Unlock()
+
+	l.known = true
+	l.info = info
+	l.resetAt = time.Now().Add(time.Duration(info.ResetAfter) * time.Millisecond)
+}
+
+// get returns the current limit, ok is false if it isn't known or has been reset since.
+func (l *sessionStartLimit) get() (info SessionInformation, resetAt time.Time, ok bool) {
+	l.Lock()
+	defer l.Unlock()
+
+	if !l.known || !time.Now().Before(l.resetAt) {
+		return SessionInformation{}, time.Time{}, false
+	}
+	info = l.info
+	info.ResetAfter = int(time.Until(l.resetAt) / time.Millisecond)
+	return info, l.resetAt, true
+}
+
+// consume counts an identify, returning false if the limit is known to be used up.
+func (l *sessionStartLimit) consume() bool {
+	l.Lock()
+	defer l.Unlock()
+
+	if !l.known || !time.Now().Before(l.resetAt) {
+		return true
+	}
+	if l.info.Remaining <= 0 {
+		return false
+	}
+	l.info.Remaining--
+	return true
+}
+
+// SessionStartLimit returns the session start limit last returned by
+// GatewayBot, with Remaining reduced by the identifies made since, and
+// ResetAfter counting down from that time. ok is false if GatewayBot hasn't
+// been called, or the limit has been reset since it was.
+func (s *Session) SessionStartLimit() (limit SessionInformation, ok bool) {
+	limit, _, ok = s.sessionStartLimit.get()
+	return
+}
+
+// reserveSessionStart counts an identify against the session start limit,
+// fetching the limit with GatewayBot when it isn't known.
+func (s *Session) reserveSessionStart() error {
+	if _, _, ok := s.sessionStartLimit.get(); !ok {
+		if _, err := s.GatewayBot(); err != nil {
+			return fmt.Errorf("error getting session start limit, %w", err)
+		}
+	}
+
+	if !s.sessionStartLimit.consume() {
+		_, resetAt, _ := s.sessionStartLimit.get()
+		return &SessionStartLimitError{ResetAt: resetAt}
+	}
+	return nil
+}
+
+// resume sends an Op 6 Resume packet to resume the current session.
+func (s *Session) resume() error {
+	p := resumePacket{}
+	p.Op = 6
+	p.Data.Token = s.Token
+	p.Data.SessionID = s.sessionID
+	p.Data.Sequence = atomic.LoadInt64(s.sequence)
+
+	s.wsMutex.Lock()
+	err := s.wsWriteJSON(s.wsConn, p.Op, p)
 	s.wsMutex.Unlock()
 
 	return err
 }
 
+// intentWarnings returns warnings about state tracking which is enabled
+// without the intents required for it, as the state would stay empty.
+func (s *Session) intentWarnings() (warnings []string) {
+	if !s.StateEnabled || s.State == nil {
+		return
+	}
+
+	intents := s.Identify.Intents
+	if intents&IntentGuilds == 0 {
+		warnings = append(warnings, "StateEnabled is true but IntentGuilds is not set, guilds, channels and roles will not be tracked by the state")
+	}
+	if s.State.MaxMessageCount > 0 && intents&(IntentGuildMessages|IntentDirectMessages) == 0 {
+		warnings = append(warnings, "State.MaxMessageCount is set but neither IntentGuildMessages nor IntentDirectMessages are set, messages will not be tracked by the state")
+	}
+	return
+}
+
+// onInvalidSession responds to an Op 9 Invalid Session received on wsConn,
+// after waiting the time required by Discord. A resumable session is resumed
+// on a new connection to the resume gateway, as the current connection can't
+// be used to resume it, otherwise a new session is identified.
+func (s *Session) onInvalidSession(wsConn *websocket.Conn, resumable bool) {
+	time.Sleep(invalidSessionWait())
+
+	s.Lock()
+	if s.wsConn != wsConn {
+		// The connection was closed or replaced while waiting.
+		s.Unlock()
+		return
+	}
+	if !resumable {
+		// The session can't be resumed anymore, so start a new one.
+		s.sessionID = ""
+		s.resumeGatewayURL = ""
+		atomic.StoreInt64(s.sequence, 0)
+	}
+	s.Unlock()
+
+	if resumable {
+		s.log(LogInformational, "reconnecting to resume the session in response to Op9")
+
+		// Closing with a code other than 1000 or 1001 keeps the session resumable.
+		s.closeWithEvent(websocket.CloseServiceRestart, &Disconnect{
+			CloseReason: "session invalidated by Discord",
+			Reconnect:   s.ShouldReconnectOnError,
+		})
+		s.reconnect()
+		return
+	}
+
+	s.log(LogInformational, "sending identify packet to gateway in response to Op9")
+
+	s.setConnectionState(ConnectionStateIdentifying)
+	if err := s.identify(); err != nil {
+		s.log(LogWarning, "error sending gateway identify packet, %s, %s", s.gateway, err)
+	}
+}
+
 func (s *Session) reconnect() {
 
 	s.log(LogInformational, "called")
@@ -850,30 +1673,14 @@ func (s *Session) reconnect() {
 
 		wait := time.Duration(1)
 
-		for {
+		for attempt := 1; ; attempt++ {
 			s.log(LogInformational, "trying to reconnect to gateway")
 
-			err = s.Open()
+			err = s.open(attempt)
 			if err == nil {
 				s.log(LogInformational, "successfully reconnected to gateway")
 
-				// I'm not sure if this is actually needed.
-				// if the gw reconnect works properly, voice should stay alive
-				// However, there seems to be cases where something "weird"
-				// happens.  So we're doing this for now just to improve
-				// stability in those edge cases.
-				s.RLock()
-				defer s.RUnlock()
-				for _, v := range s.VoiceConnections {
-
-					s.log(LogInformational, "reconnecting voice connection to guild %s", v.GuildID)
-					go v.reconnect()
-
-					// This is here just to prevent violently spamming the
-					// voice reconnects
-					time.Sleep(1 * time.Second)
-
-				}
+				s.reconnectVoiceConnections()
 				return
 			}
 
@@ -895,6 +1702,64 @@ func (s *Session) reconnect() {
 	}
 }
 
+// reconnectVoiceConnections reconnects all voice connections after the
+// gateway connection has been reopened.
+func (s *Session) reconnectVoiceConnections() {
+	// I'm not sure if this is actually needed.
+	// if the gw reconnect works properly, voice should stay alive
+	// However, there seems to be cases where something "weird"
+	// happens.  So we're doing this for now just to improve
+	// stability in those edge cases.
+	s.RLock()
+	defer s.RUnlock()
+	for _, v := range s.VoiceConnections {
+
+		s.log(LogInformational, "reconnecting voice connection to guild %s", v.GuildID)
+		go v.reconnect()
+
+		// This is here just to prevent violently spamming the
+		// voice reconnects
+		time.Sleep(1 * time.Second)
+
+	}
+}
+
+// Reconnect closes the gateway connection, if it is open, and opens it
+// again, resuming the session when possible. Unlike the reconnects made
+// when ShouldReconnectOnError is true, it is attempted only once and the
+// error is returned, so that supervisors can implement their own policy.
+func (s *Session) Reconnect() error {
+	s.RLock()
+	open := s.wsConn != nil
+	s.RUnlock()
+
+	if open {
+		// Closing with a code other than 1000 or 1001 keeps the session resumable.
+		err := s.closeWithEvent(websocket.CloseServiceRestart, &Disconnect{Reconnect: true})
+		if err != nil {
+			return err
+		}
+	}
+
+	err := s.Open()
+	if err != nil {
+		return err
+	}
+
+	s.reconnectVoiceConnections()
+	return nil
+}
+
+// disconnectFromError returns the Disconnect event for a connection which
+// failed with err, including the close code and reason sent by Discord.
+func disconnectFromError(err error) *Disconnect {
+	var closeErr *websocket.CloseError
+	if errors.As(err, &closeErr) {
+		return &Disconnect{CloseCode: closeErr.Code, CloseReason: closeErr.Text}
+	}
+	return &Disconnect{CloseCode: websocket.CloseAbnormalClosure, CloseReason: err.Error()}
+}
+
 // Close closes a websocket and stops all listening/heartbeat goroutines.
 // TODO: Add support for Voice WS/UDP
 func (s *Session) Close() error {
@@ -902,9 +1767,17 @@ func (s *Session) Close() error {
 }
 
 // CloseWithCode closes a websocket using the provided closeCode and stops all
-// listening/heartbeat goroutines.
+// listening/heartbeat goroutines, and the event handler goroutines started
+// for MaxConcurrentHandlers once they have handled the Disconnect event.
 // TODO: Add support for Voice WS/UDP connections
 func (s *Session) CloseWithCode(closeCode int) (err error) {
+	return s.closeWithEvent(closeCode, &Disconnect{})
+}
+
+// closeWithEvent closes the websocket like CloseWithCode and emits d as the
+// Disconnect event. Unless d.Reconnect is set, the event handler goroutines
+// are stopped once they have handled it.
+func (s *Session) closeWithEvent(closeCode int, d *Disconnect) (err error) {
 
 	s.log(LogInformational, "called")
 	s.Lock()
@@ -946,8 +1819,14 @@ func (s *Session) CloseWithCode(closeCode int) (err error) {
 
 	s.Unlock()
 
+	s.setConnectionState(ConnectionStateDisconnected)
+
 	s.log(LogInformational, "emit disconnect event")
-	s.handleEvent(disconnectEventType, &Disconnect{})
+	s.handleEvent(disconnectEventType, d)
+
+	if !d.Reconnect {
+		s.handlerPool.stop()
+	}
 
 	return
 }